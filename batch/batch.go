@@ -0,0 +1,182 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is one line of a batch input file.
+type Job struct {
+	ID      string         `json:"id"`
+	Prompt  string         `json:"prompt"`
+	Model   string         `json:"model,omitempty"`
+	System  string         `json:"system,omitempty"`
+	Context []string       `json:"context,omitempty"` // Files attached to the prompt
+	Options map[string]any `json:"options,omitempty"` // Generation options, e.g. temperature
+}
+
+// Result is one line of a batch output file. The file is only appended
+// to, so a prompt that failed and was retried has a line for each attempt;
+// the last line for an ID is its outcome.
+type Result struct {
+	ID          string    `json:"id"`
+	Model       string    `json:"model"`
+	Prompt      string    `json:"prompt"`
+	Response    string    `json:"response,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// RunFunc runs a single job and returns the model used and its response.
+type RunFunc func(ctx context.Context, job Job) (model, response string, err error)
+
+// ProgressFunc is called after each job finishes.
+type ProgressFunc func(done, total int, result Result)
+
+// maxLineSize is the longest input or output line accepted
+const maxLineSize = 16 * 1024 * 1024
+
+// ReadJobs parses a JSONL input file. Blank lines are skipped and jobs
+// without an ID are named after their line number.
+func ReadJobs(path string) ([]Job, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var jobs []Job
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(line), &job); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if strings.TrimSpace(job.Prompt) == "" {
+			return nil, fmt.Errorf("%s:%d: missing prompt", path, n)
+		}
+		if job.ID == "" {
+			job.ID = fmt.Sprintf("line-%d", n)
+		}
+		if first, ok := seen[job.ID]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate id '%s' (first used on line %d)", path, n, job.ID, first)
+		}
+		seen[job.ID] = n
+		jobs = append(jobs, job)
+	}
+	return jobs, scanner.Err()
+}
+
+// CompletedIDs returns the IDs that already have a successful result in
+// an output file. A missing file has none.
+func CompletedIDs(path string) (map[string]bool, error) {
+	completed := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var result Result
+		// A line cut short by an interruption is simply run again
+		if json.Unmarshal(scanner.Bytes(), &result) != nil {
+			continue
+		}
+		if result.Error == "" {
+			completed[result.ID] = true
+		}
+	}
+	return completed, scanner.Err()
+}
+
+// Run executes the jobs with at most concurrency running at a time and
+// writes each result to out as a JSON line as soon as it finishes.
+// Cancelling ctx stops new jobs from starting; results already written
+// stay complete so the batch can be resumed.
+func Run(ctx context.Context, jobs []Job, concurrency int, out io.Writer, run RunFunc, progress ProgressFunc) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		done     int
+		writeErr error
+		wg       sync.WaitGroup
+	)
+	encoder := json.NewEncoder(out)
+	sem := make(chan struct{}, concurrency)
+
+	for _, job := range jobs {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			model, response, err := run(ctx, job)
+			if ctx.Err() != nil {
+				return // Interrupted jobs are left for the next run
+			}
+
+			result := Result{
+				ID:          job.ID,
+				Model:       model,
+				Prompt:      job.Prompt,
+				Response:    response,
+				DurationMS:  time.Since(start).Milliseconds(),
+				CompletedAt: time.Now(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err := encoder.Encode(result); err != nil && writeErr == nil {
+				writeErr = err
+			}
+			done++
+			if progress != nil {
+				progress(done, len(jobs), result)
+			}
+		}(job)
+	}
+
+	wg.Wait()
+	if writeErr != nil {
+		return fmt.Errorf("failed to write results: %w", writeErr)
+	}
+	return ctx.Err()
+}
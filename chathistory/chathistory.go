@@ -1,21 +1,35 @@
 package chathistory
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/hariharen9/lamacli/config"
 )
 
 // ChatSession represents a saved chat session
 type ChatSession struct {
+	Version   int       `json:"version"`
 	ID        string    `json:"id"`
 	Title     string    `json:"title"`
+	Renamed   bool      `json:"renamed,omitempty"` // Title set by the user, kept by SummarizeSession
+	Summary   string    `json:"summary,omitempty"` // One paragraph written by the titles model
+	Tags      []string  `json:"tags,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Folder    string    `json:"folder,omitempty"` // Slash-separated path, empty for none
 	Model     string    `json:"model"`
-	History   []string  `json:"history"`
+	History   []string  `json:"history"`            // Branch shown, alternating user and assistant
+	Messages  []Message `json:"messages,omitempty"` // Every branch
+	Current   string    `json:"current,omitempty"`  // Last message of History
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -23,120 +37,360 @@ type ChatSession struct {
 // ChatHistoryManager manages chat history persistence
 type ChatHistoryManager struct {
 	historyDir string
+	backend    string
+	store      Store
+
+	mu     sync.Mutex
+	issues []Issue // Problems found by the last ListSessions
 }
 
-// NewChatHistoryManager creates a new chat history manager
+// NewChatHistoryManager creates a new chat history manager for the backend
+// set by the history_backend config key
 func NewChatHistoryManager() (*ChatHistoryManager, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return NewChatHistoryManagerWithBackend(cfg.HistoryBackend)
+}
+
+// NewChatHistoryManagerWithBackend creates a chat history manager that
+// stores sessions with a backend, such as BackendJSON
+func NewChatHistoryManagerWithBackend(backend string) (*ChatHistoryManager, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
 	}
-	
+
 	historyDir := filepath.Join(homeDir, ".lamacli", "chat_history")
-	
+
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(historyDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create chat history directory: %w", err)
 	}
-	
+
+	store, err := openStore(backend, historyDir)
+	if err != nil {
+		return nil, err
+	}
+	if backend == "" {
+		backend = BackendJSON
+	}
+
 	return &ChatHistoryManager{
 		historyDir: historyDir,
+		backend:    backend,
+		store:      store,
 	}, nil
 }
 
-// SaveSession saves a chat session to disk
+// Backend returns the name of the backend storing the sessions
+func (chm *ChatHistoryManager) Backend() string {
+	return chm.backend
+}
+
+// ErrInvalidSessionID is returned for session IDs given by callers that
+// could name something outside the history, such as "../x"
+var ErrInvalidSessionID = errors.New("invalid session ID")
+
+// validSessionID matches the session IDs that are safe to store under
+var validSessionID = regexp.MustCompile(`^[\w-][\w.-]*$`)
+
+// checkSessionID rejects session IDs that could escape the store
+func checkSessionID(sessionID string) error {
+	if !validSessionID.MatchString(sessionID) {
+		return fmt.Errorf("%w '%s'", ErrInvalidSessionID, sessionID)
+	}
+	return nil
+}
+
+// ErrConflict is returned when saving a session that another lamacli
+// instance changed since it was loaded, and saving would drop its turns.
+var ErrConflict = errors.New("session was changed by another lamacli instance")
+
+// SaveSession saves a chat session to disk. A session that already exists
+// on disk is only overwritten when it is unchanged since the session was
+// loaded or its history is a prefix of the session's; otherwise ErrConflict
+// is returned. The stored title, summary, tags, pin and folder are kept, as
+// they are changed only through RenameSession and the like.
 func (chm *ChatHistoryManager) SaveSession(session *ChatSession) error {
+	unlock, err := chm.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if session.ID == "" {
-		session.ID = generateSessionID()
+		session.ID = NewSessionID()
+	} else {
+		if err := checkSessionID(session.ID); err != nil {
+			return err
+		}
+		stored, err := chm.checkConflict(session)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			session.Title, session.Renamed, session.Summary = stored.Title, stored.Renamed, stored.Summary
+			session.Tags = stored.Tags
+			session.Pinned, session.Folder = stored.Pinned, stored.Folder
+		}
 	}
-	
+
 	// Generate title from first user message if not set
 	if session.Title == "" {
 		session.Title = chm.generateSessionTitle(session.History)
 	}
-	
+
 	session.UpdatedAt = time.Now()
 	if session.CreatedAt.IsZero() {
 		session.CreatedAt = session.UpdatedAt
 	}
-	
-	filename := fmt.Sprintf("%s.json", session.ID)
-	filepath := filepath.Join(chm.historyDir, filename)
-	
+
+	if err := chm.writeSession(session); err != nil {
+		return err
+	}
+	chm.indexSession(session)
+	return nil
+}
+
+// SaveSessionCopy saves a session under a new ID, for when saving it in
+// place failed with ErrConflict
+func (chm *ChatHistoryManager) SaveSessionCopy(session *ChatSession) error {
+	session.ID = NewSessionID()
+	if session.Title != "" {
+		session.Title += " (copy)"
+	}
+	session.CreatedAt = time.Time{}
+	return chm.SaveSession(session)
+}
+
+// checkConflict reports whether saving a session would overwrite changes
+// made on disk since it was loaded, and returns the stored session if any
+func (chm *ChatHistoryManager) checkConflict(session *ChatSession) (*ChatSession, error) {
+	stored, _, err := chm.readSession(session.ID)
+	if errors.Is(err, ErrNewerVersion) {
+		return nil, err
+	}
+	if err != nil {
+		// Missing and corrupt files hold nothing to lose
+		return nil, nil
+	}
+
+	if stored.UpdatedAt.Equal(session.UpdatedAt) || isPrefix(stored.History, session.History) {
+		return stored, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrConflict, session.ID)
+}
+
+// isPrefix reports whether history starts with prefix
+func isPrefix(prefix, history []string) bool {
+	if len(prefix) > len(history) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != history[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lock takes the store's lock, waiting for other lamacli instances to
+// release it, and returns a function that releases it
+func (chm *ChatHistoryManager) lock() (func(), error) {
+	return chm.store.Lock()
+}
+
+// writeSession stores a session as it is, in the current schema version.
+// The caller must hold the lock.
+func (chm *ChatHistoryManager) writeSession(session *ChatSession) error {
+	session.Version = SchemaVersion
+	session.SyncTree()
+
 	data, err := json.MarshalIndent(session, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal session: %w", err)
 	}
-	
-	if err := os.WriteFile(filepath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write session file: %w", err)
-	}
-	
-	return nil
+	return chm.store.Write(session, data)
 }
 
-// LoadSession loads a chat session from disk
+// LoadSession loads a chat session from disk. Files in an older schema
+// version are upgraded and written back.
 func (chm *ChatHistoryManager) LoadSession(sessionID string) (*ChatSession, error) {
-	filename := fmt.Sprintf("%s.json", sessionID)
-	filepath := filepath.Join(chm.historyDir, filename)
-	
-	data, err := os.ReadFile(filepath)
+	if err := checkSessionID(sessionID); err != nil {
+		return nil, err
+	}
+	session, version, err := chm.readSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if version < SchemaVersion {
+		unlock, err := chm.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		// Read again in case another instance wrote the file meanwhile
+		if session, version, err = chm.readSession(sessionID); err != nil {
+			return nil, err
+		}
+		if version < SchemaVersion {
+			if err := chm.writeSession(session); err != nil {
+				return nil, fmt.Errorf("failed to upgrade session: %w", err)
+			}
+		}
+	}
+
+	return session, nil
+}
+
+// readSession reads and decodes a stored session, returning the schema
+// version it was stored in
+func (chm *ChatHistoryManager) readSession(sessionID string) (*ChatSession, int, error) {
+	data, err := chm.store.Read(sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read session file: %w", err)
+		return nil, 0, err
 	}
-	
-	var session ChatSession
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+
+	session, version, err := decodeSession(data)
+	if errors.Is(err, ErrNewerVersion) {
+		return nil, version, err
+	}
+	if err != nil {
+		return nil, version, fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	if session.ID == "" {
+		session.ID = sessionID
 	}
-	
-	return &session, nil
+
+	return session, version, nil
 }
 
-// ListSessions returns all available chat sessions, sorted by update time (newest first)
+// LoadSessionRef loads a session by ID, or the most recently updated
+// session when ref is "last"
+func (chm *ChatHistoryManager) LoadSessionRef(ref string) (*ChatSession, error) {
+	if ref != "last" {
+		return chm.LoadSession(ref)
+	}
+
+	page, err := chm.ListSessionInfo(Query{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Sessions) == 0 {
+		return nil, fmt.Errorf("no saved sessions found")
+	}
+	return chm.LoadSession(page.Sessions[0].ID)
+}
+
+// ListSessions returns all available chat sessions, sorted by update time
+// (newest first). Corrupt sessions are moved to the quarantine folder; they
+// and any others that could not be loaded are reported by Issues.
 func (chm *ChatHistoryManager) ListSessions() ([]*ChatSession, error) {
-	files, err := os.ReadDir(chm.historyDir)
+	entries, err := chm.store.Entries()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read history directory: %w", err)
+		return nil, err
 	}
-	
+
+	chm.mu.Lock()
+	chm.issues = nil
+	chm.mu.Unlock()
+
 	var sessions []*ChatSession
-	
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
-			sessionID := strings.TrimSuffix(file.Name(), ".json")
-			session, err := chm.LoadSession(sessionID)
-			if err != nil {
-				// Skip corrupted files
-				continue
+
+	for _, entry := range entries {
+		session, err := chm.LoadSession(entry.ID)
+		if err != nil {
+			issue := Issue{File: chm.store.Name(entry.ID), Problem: err.Error(), Fixable: errors.Is(err, errCorrupt)}
+			if errors.Is(err, errCorrupt) {
+				if target, qerr := chm.quarantineCorrupt(entry.ID); qerr == nil {
+					issue.Problem += fmt.Sprintf(" (moved to %s)", target)
+					issue.Fixed = true
+				}
 			}
-			sessions = append(sessions, session)
+			chm.report(issue)
+			continue
 		}
+		sessions = append(sessions, session)
 	}
-	
+
 	// Sort by update time (newest first)
 	sort.Slice(sessions, func(i, j int) bool {
 		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
 	})
-	
+
 	return sessions, nil
 }
 
-// DeleteSession deletes a chat session from disk
+// ListSessionInfo returns a page of the metadata of the sessions matching
+// a query. Stores that index metadata answer without reading any
+// messages; otherwise every session is loaded as by ListSessions.
+func (chm *ChatHistoryManager) ListSessionInfo(query Query) (*Page, error) {
+	if lister, ok := chm.store.(Lister); ok {
+		return lister.List(query)
+	}
+
+	sessions, err := chm.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = session.Info()
+	}
+	return query.run(infos), nil
+}
+
+// DeleteSession deletes a stored chat session
 func (chm *ChatHistoryManager) DeleteSession(sessionID string) error {
-	filename := fmt.Sprintf("%s.json", sessionID)
-	filepath := filepath.Join(chm.historyDir, filename)
-	
-	if err := os.Remove(filepath); err != nil {
-		return fmt.Errorf("failed to delete session file: %w", err)
+	if err := checkSessionID(sessionID); err != nil {
+		return err
+	}
+	unlock, err := chm.lock()
+	if err != nil {
+		return err
 	}
-	
+	defer unlock()
+
+	if err := chm.store.Delete(sessionID); err != nil {
+		return err
+	}
+
+	chm.unindexSession(sessionID)
 	return nil
 }
 
-// generateSessionID generates a unique session ID
-func generateSessionID() string {
-	return fmt.Sprintf("session_%d", time.Now().Unix())
+// crockford is the Base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewSessionID returns a new unique session ID. The ID is a ULID, so IDs
+// sort by creation time and sessions created in the same instant, even by
+// different processes, do not collide.
+func NewSessionID() string {
+	var id [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	rand.Read(id[6:])
+
+	// Encode the 128 bits as 26 characters of 5 bits, the first holding
+	// only 3
+	encoded := make([]byte, 26)
+	for i := range encoded {
+		value := 0
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			value <<= 1
+			if bit >= 0 {
+				value |= int(id[bit/8]>>(7-bit%8)) & 1
+			}
+		}
+		encoded[i] = crockford[value]
+	}
+	return "session_" + string(encoded)
 }
 
 // generateSessionTitle generates a title from the chat history
@@ -144,32 +398,16 @@ func (chm *ChatHistoryManager) generateSessionTitle(history []string) string {
 	// Find the first non-empty user message
 	for i := 0; i < len(history); i += 2 {
 		if history[i] != "" {
-			// Take first 50 characters as title
-			title := strings.TrimSpace(history[i])
-			if len(title) > 50 {
-				title = title[:47] + "..."
+			// Take first 50 characters as title, naming attached files
+			// rather than quoting their contents
+			title := strings.Join(strings.Fields(collapseFiles(history[i])), " ")
+			if runes := []rune(title); len(runes) > 50 {
+				title = string(runes[:47]) + "..."
 			}
 			return title
 		}
 	}
-	
+
 	// Fallback title
 	return fmt.Sprintf("Chat Session %s", time.Now().Format("Jan 2, 2006"))
 }
-
-// GetSessionSummary returns a brief summary of the session
-func (session *ChatSession) GetSessionSummary() string {
-	messageCount := len(session.History) / 2
-	timeAgo := time.Since(session.UpdatedAt)
-	
-	var timeStr string
-	if timeAgo < time.Hour {
-		timeStr = fmt.Sprintf("%d minutes ago", int(timeAgo.Minutes()))
-	} else if timeAgo < 24*time.Hour {
-		timeStr = fmt.Sprintf("%d hours ago", int(timeAgo.Hours()))
-	} else {
-		timeStr = fmt.Sprintf("%d days ago", int(timeAgo.Hours()/24))
-	}
-	
-	return fmt.Sprintf("%s (%d messages, %s)", session.Title, messageCount, timeStr)
-}
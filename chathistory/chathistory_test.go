@@ -0,0 +1,150 @@
+package chathistory
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a manager keeping its sessions in a temporary
+// directory with a backend
+func newTestManager(t *testing.T, backend string) *ChatHistoryManager {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := openStore(backend, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ChatHistoryManager{historyDir: dir, backend: backend, store: store}
+}
+
+func TestIsPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, history []string
+		want            bool
+	}{
+		{nil, nil, true},
+		{nil, []string{"a"}, true},
+		{[]string{"a", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, []string{"a", "b", "c", "d"}, true},
+		{[]string{"a", "b", "c"}, []string{"a", "b"}, false},
+		{[]string{"a", "x"}, []string{"a", "b", "c"}, false},
+		{[]string{"a"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := isPrefix(tt.prefix, tt.history); got != tt.want {
+			t.Errorf("isPrefix(%q, %q) = %v, want %v", tt.prefix, tt.history, got, tt.want)
+		}
+	}
+}
+
+func TestSaveSessionConflict(t *testing.T) {
+	stored := []string{"q1", "a1", "q2", "a2"}
+	tests := []struct {
+		name      string
+		history   []string
+		unchanged bool // Saved with the stored UpdatedAt
+		conflict  bool
+	}{
+		{name: "unchanged since loaded", history: []string{"q1", "edited"}, unchanged: true},
+		{name: "extends the stored history", history: []string{"q1", "a1", "q2", "a2", "q3", "a3"}},
+		{name: "same history", history: stored},
+		{name: "diverges", history: []string{"q1", "a1", "other", "reply"}, conflict: true},
+		{name: "drops stored turns", history: []string{"q1", "a1"}, conflict: true},
+	}
+	for _, backend := range Backends {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				chm := newTestManager(t, backend)
+				original := &ChatSession{Title: "T", History: stored}
+				if err := chm.SaveSession(original); err != nil {
+					t.Fatal(err)
+				}
+
+				session := &ChatSession{ID: original.ID, Title: "T", History: tt.history, CreatedAt: original.CreatedAt}
+				session.UpdatedAt = original.UpdatedAt.Add(-time.Minute)
+				if tt.unchanged {
+					session.UpdatedAt = original.UpdatedAt
+				}
+				err := chm.SaveSession(session)
+				if tt.conflict {
+					if !errors.Is(err, ErrConflict) {
+						t.Fatalf("SaveSession() error = %v, want ErrConflict", err)
+					}
+					loaded, err := chm.LoadSession(original.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !isPrefix(stored, loaded.History) || len(loaded.History) != len(stored) {
+						t.Errorf("stored history after a conflict = %q", loaded.History)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("SaveSession() error = %v", err)
+				}
+				loaded, err := chm.LoadSession(original.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !isPrefix(tt.history, loaded.History) || len(loaded.History) != len(tt.history) {
+					t.Errorf("stored history = %q, want %q", loaded.History, tt.history)
+				}
+			})
+		}
+	}
+}
+
+func TestSaveSessionKeepsStoredMetadata(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			chm := newTestManager(t, backend)
+			session := &ChatSession{History: []string{"hello there", "hi"}}
+			if err := chm.SaveSession(session); err != nil {
+				t.Fatal(err)
+			}
+			if session.Title != "hello there" {
+				t.Errorf("generated title = %q", session.Title)
+			}
+			if err := chm.RenameSession(session.ID, "Greetings"); err != nil {
+				t.Fatal(err)
+			}
+
+			// A save from a copy loaded before the rename keeps the new title
+			session.History = append(session.History, "bye", "see you")
+			if err := chm.SaveSession(session); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := chm.LoadSession(session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded.Title != "Greetings" || !loaded.Renamed {
+				t.Errorf("title = %q, renamed %v; want the rename kept", loaded.Title, loaded.Renamed)
+			}
+			if len(loaded.History) != 4 {
+				t.Errorf("history = %q", loaded.History)
+			}
+		})
+	}
+}
+
+func TestInvalidSessionIDs(t *testing.T) {
+	for _, backend := range Backends {
+		chm := newTestManager(t, backend)
+		for _, id := range []string{"../outside", "a/b", "..", ".hidden", "", "x\x00y"} {
+			if _, err := chm.LoadSession(id); !errors.Is(err, ErrInvalidSessionID) {
+				t.Errorf("%s: LoadSession(%q) error = %v", backend, id, err)
+			}
+			if err := chm.DeleteSession(id); !errors.Is(err, ErrInvalidSessionID) {
+				t.Errorf("%s: DeleteSession(%q) error = %v", backend, id, err)
+			}
+			if id == "" {
+				continue // Saving assigns a new ID
+			}
+			if err := chm.SaveSession(&ChatSession{ID: id, History: []string{"hi", "hello"}}); !errors.Is(err, ErrInvalidSessionID) {
+				t.Errorf("%s: SaveSession(%q) error = %v", backend, id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package chathistory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// quarantineDir is the folder, inside the history directory, that corrupt
+// session files are moved to
+const quarantineDir = "quarantine"
+
+// Issue is a problem found with a session file.
+type Issue struct {
+	File    string `json:"file"`
+	Problem string `json:"problem"`
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed"`
+}
+
+// Report is the result of checking the session store.
+type Report struct {
+	Checked     int      `json:"checked"`
+	Issues      []Issue  `json:"issues"`
+	Quarantined []string `json:"quarantined"` // Files in the quarantine folder
+}
+
+// Issues returns the problems found while loading sessions with this
+// manager, such as corrupt files that were quarantined.
+func (chm *ChatHistoryManager) Issues() []Issue {
+	chm.mu.Lock()
+	defer chm.mu.Unlock()
+	return append([]Issue{}, chm.issues...)
+}
+
+func (chm *ChatHistoryManager) report(issue Issue) {
+	chm.mu.Lock()
+	defer chm.mu.Unlock()
+	chm.issues = append(chm.issues, issue)
+}
+
+// QuarantinePath returns the folder corrupt session files are moved to.
+func (chm *ChatHistoryManager) QuarantinePath() string {
+	return filepath.Join(chm.historyDir, quarantineDir)
+}
+
+// quarantineCorrupt quarantines a session if it is still corrupt once the
+// lock is held
+func (chm *ChatHistoryManager) quarantineCorrupt(sessionID string) (string, error) {
+	unlock, err := chm.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, _, err := chm.readSession(sessionID); !errors.Is(err, errCorrupt) {
+		return "", fmt.Errorf("%s is no longer corrupt", sessionID)
+	}
+	return chm.quarantine(sessionID)
+}
+
+// quarantine moves a corrupt session out of the store into a file, keeping
+// it for inspection, and returns the file's path. The caller must hold the
+// lock.
+func (chm *ChatHistoryManager) quarantine(sessionID string) (string, error) {
+	data, err := chm.store.Read(sessionID)
+	if err != nil {
+		return "", err
+	}
+	dir := chm.QuarantinePath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	stamp := time.Now().Format("20060102-150405")
+	target := filepath.Join(dir, fmt.Sprintf("%s.%s.json", sessionID, stamp))
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %w", chm.store.Name(sessionID), err)
+	}
+	if err := chm.store.Delete(sessionID); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("failed to quarantine %s: %w", chm.store.Name(sessionID), err)
+	}
+	chm.unindexSession(sessionID)
+	return target, nil
+}
+
+// Doctor checks every stored session. Corrupt sessions are quarantined, old
+// versions migrated and missing IDs, titles and timestamps filled in, but
+// only when fix is set; otherwise the problems are just reported.
+func (chm *ChatHistoryManager) Doctor(fix bool) (*Report, error) {
+	if fix {
+		unlock, err := chm.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	entries, err := chm.store.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Issues: []Issue{}, Quarantined: []string{}}
+	for _, entry := range entries {
+		report.Checked++
+		report.Issues = append(report.Issues, chm.check(entry, fix)...)
+	}
+
+	quarantined, err := os.ReadDir(chm.QuarantinePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range quarantined {
+		report.Quarantined = append(report.Quarantined, file.Name())
+	}
+	sort.Strings(report.Quarantined)
+	return report, nil
+}
+
+// check verifies one stored session, repairing it when fix is set
+func (chm *ChatHistoryManager) check(entry Entry, fix bool) []Issue {
+	name := chm.store.Name(entry.ID)
+	data, err := chm.store.Read(entry.ID)
+	if err != nil {
+		return []Issue{{File: name, Problem: err.Error()}}
+	}
+
+	session, version, err := decodeSession(data)
+	if errors.Is(err, ErrNewerVersion) {
+		return []Issue{{File: name, Problem: err.Error()}}
+	}
+	if err != nil {
+		issue := Issue{File: name, Problem: fmt.Sprintf("corrupt: %v", err), Fixable: true}
+		if fix {
+			if target, qerr := chm.quarantine(entry.ID); qerr != nil {
+				issue.Problem += fmt.Sprintf(" (%v)", qerr)
+			} else {
+				issue.Problem += fmt.Sprintf(" (moved to %s)", target)
+				issue.Fixed = true
+			}
+		}
+		return []Issue{issue}
+	}
+
+	var problems []string
+	if version < SchemaVersion {
+		problems = append(problems, fmt.Sprintf("schema version %d needs migrating to %d", version, SchemaVersion))
+	}
+	if session.ID != entry.ID {
+		problems = append(problems, fmt.Sprintf("ID %q does not match the file name", session.ID))
+		session.ID = entry.ID
+	}
+	if session.Title == "" {
+		problems = append(problems, "missing title")
+		session.Title = chm.generateSessionTitle(session.History)
+	}
+	if !session.treeMatches() {
+		problems = append(problems, "history does not match the message tree")
+	}
+	if session.UpdatedAt.IsZero() {
+		problems = append(problems, "missing updated_at")
+		session.UpdatedAt = entry.Modified
+	}
+	if session.CreatedAt.IsZero() {
+		problems = append(problems, "missing created_at")
+		session.CreatedAt = session.UpdatedAt
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	fixed := false
+	var writeErr error
+	if fix {
+		writeErr = chm.writeSession(session)
+		fixed = writeErr == nil
+	}
+	issues := make([]Issue, len(problems))
+	for i, problem := range problems {
+		if writeErr != nil {
+			problem += fmt.Sprintf(" (%v)", writeErr)
+		}
+		issues[i] = Issue{File: name, Problem: problem, Fixable: true, Fixed: fixed}
+	}
+	return issues
+}
@@ -0,0 +1,122 @@
+package chathistory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImportResult is the outcome of importing one session.
+type ImportResult struct {
+	Session   *ChatSession // Session as imported
+	Duplicate *ChatSession // Saved session that already holds the conversation
+	Err       error        // Why the session was not imported
+}
+
+// Imported reports whether the session was (or, in a dry run, would be)
+// imported.
+func (r ImportResult) Imported() bool {
+	return r.Duplicate == nil && r.Err == nil
+}
+
+// ImportSessions saves sessions converted from other tools under new IDs,
+// keeping their timestamps. Sessions whose conversation is already saved,
+// possibly continued since, are skipped as duplicates, as are repeats
+// within sessions. With dryRun nothing is written and no IDs are assigned.
+func (chm *ChatHistoryManager) ImportSessions(sessions []*ChatSession, dryRun bool) ([]ImportResult, error) {
+	unlock, err := chm.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	saved, err := chm.storedSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, len(sessions))
+	for i, session := range sessions {
+		results[i].Session = session
+		if len(conversation(session.History)) == 0 {
+			results[i].Err = fmt.Errorf("no messages")
+			continue
+		}
+		if session.Title == "" {
+			session.Title = chm.generateSessionTitle(session.History)
+		}
+		if duplicate := findDuplicate(saved, session); duplicate != nil {
+			results[i].Duplicate = duplicate
+			continue
+		}
+
+		if session.UpdatedAt.IsZero() {
+			session.UpdatedAt = time.Now()
+		}
+		if session.CreatedAt.IsZero() || session.CreatedAt.After(session.UpdatedAt) {
+			session.CreatedAt = session.UpdatedAt
+		}
+
+		if !dryRun {
+			session.ID = NewSessionID()
+			if err := chm.writeSession(session); err != nil {
+				results[i].Err = err
+				continue
+			}
+			chm.indexSession(session)
+		}
+		saved = append(saved, session)
+	}
+	return results, nil
+}
+
+// storedSessions reads every readable session. Unlike ListSessions it
+// neither upgrades nor quarantines sessions, so it is safe under the lock.
+func (chm *ChatHistoryManager) storedSessions() ([]*ChatSession, error) {
+	entries, err := chm.store.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*ChatSession
+	for _, entry := range entries {
+		if session, _, err := chm.readSession(entry.ID); err == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+// findDuplicate returns the session among saved whose conversation starts
+// with the session's
+func findDuplicate(saved []*ChatSession, session *ChatSession) *ChatSession {
+	messages := conversation(session.History)
+	for _, candidate := range saved {
+		if isPrefix(messages, conversation(candidate.History)) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// conversation returns the non-empty messages of a history, tagged with
+// their role and with whitespace collapsed, so that conversations compare
+// equal however they were stored. The TUI's welcome greeting is left out.
+func conversation(history []string) []string {
+	var messages []string
+	for i, message := range history {
+		if i == 1 && history[0] == "" {
+			continue // Welcome message
+		}
+		message = strings.Join(strings.Fields(message), " ")
+		if message == "" {
+			continue
+		}
+		role := "user: "
+		if i%2 == 1 {
+			role = "assistant: "
+		}
+		messages = append(messages, role+message)
+	}
+	return messages
+}
@@ -0,0 +1,105 @@
+package chathistory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindDuplicate(t *testing.T) {
+	saved := []*ChatSession{
+		{ID: "a", History: []string{"How do I list files?", "Use ls.", "And hidden ones?", "Use ls -a."}},
+		{ID: "b", History: []string{"", "Welcome!", "Hello", "Hi there"}},
+	}
+	tests := []struct {
+		name    string
+		history []string
+		want    string // ID of the duplicate, empty for none
+	}{
+		{"same conversation", []string{"How do I list files?", "Use ls.", "And hidden ones?", "Use ls -a."}, "a"},
+		{"continued since", []string{"How do I list files?", "Use ls."}, "a"},
+		{"whitespace differs", []string{"How do I  list\nfiles?", " Use ls. "}, "a"},
+		{"welcome message ignored", []string{"Hello", "Hi there"}, "b"},
+		{"different answer", []string{"How do I list files?", "Use dir."}, ""},
+		{"roles swapped", []string{"Use ls.", "How do I list files?"}, ""},
+		{"longer than saved", []string{"Hello", "Hi there", "Bye", "Bye!"}, ""},
+	}
+	for _, tt := range tests {
+		got := findDuplicate(saved, &ChatSession{History: tt.history})
+		gotID := ""
+		if got != nil {
+			gotID = got.ID
+		}
+		if gotID != tt.want {
+			t.Errorf("%s: findDuplicate() = %q, want %q", tt.name, gotID, tt.want)
+		}
+	}
+}
+
+func TestImportSessions(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			chm := newTestManager(t, backend)
+			created := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+			updated := created.Add(time.Hour)
+			imported := func() []*ChatSession {
+				return []*ChatSession{
+					{Title: "Listing files", History: []string{"How do I list files?", "Use ls."}, CreatedAt: created, UpdatedAt: updated},
+					{History: []string{"Undated", "Answer"}},
+					{History: []string{"", "Only a greeting"}},
+				}
+			}
+
+			results, err := chm.ImportSessions(imported(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !results[0].Imported() || !results[1].Imported() || results[2].Imported() {
+				t.Fatalf("imported %v, %v, %v; want the first two", results[0].Imported(), results[1].Imported(), results[2].Imported())
+			}
+
+			// Timestamps are kept, and filled in when missing
+			session, err := chm.LoadSession(results[0].Session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !session.CreatedAt.Equal(created) || !session.UpdatedAt.Equal(updated) {
+				t.Errorf("created, updated = %v, %v; want %v, %v", session.CreatedAt, session.UpdatedAt, created, updated)
+			}
+			undated := results[1].Session
+			if undated.UpdatedAt.IsZero() || !undated.CreatedAt.Equal(undated.UpdatedAt) {
+				t.Errorf("undated session got created %v, updated %v", undated.CreatedAt, undated.UpdatedAt)
+			}
+			if undated.Title == "" {
+				t.Error("untitled session was not given a title")
+			}
+
+			// Importing the same file again finds the saved sessions
+			again, err := chm.ImportSessions(imported(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, result := range again[:2] {
+				if result.Duplicate == nil || result.Duplicate.ID != results[i].Session.ID {
+					t.Errorf("re-import of session %d was not reported as a duplicate of %s", i, results[i].Session.ID)
+				}
+			}
+			if entries, err := chm.store.Entries(); err != nil || len(entries) != 2 {
+				t.Errorf("store holds %d sessions after re-import, want 2", len(entries))
+			}
+
+			// Repeats within one import are duplicates too, and a dry run
+			// writes nothing
+			repeated := &ChatSession{History: []string{"New question", "New answer"}}
+			dry, err := chm.ImportSessions([]*ChatSession{repeated, {History: repeated.History}}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dry[0].Imported() || dry[1].Duplicate != repeated || repeated.ID != "" {
+				t.Errorf("dry run results %+v, %+v", dry[0], dry[1])
+			}
+			if entries, err := chm.store.Entries(); err != nil || len(entries) != 2 {
+				t.Errorf("store holds %d sessions after a dry run, want 2", len(entries))
+			}
+		})
+	}
+}
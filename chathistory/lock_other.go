@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package chathistory
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking; writes are still
+// atomic, but concurrent instances are not serialised
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package chathistory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SchemaVersion is the version of the session file format written by this
+// build. Files without a version field are version 0.
+const SchemaVersion = 4
+
+// ErrNewerVersion is returned for session files written by a newer
+// lamacli, which are left untouched.
+var ErrNewerVersion = errors.New("written by a newer version of lamacli")
+
+// errCorrupt wraps the errors of session files that cannot be decoded
+var errCorrupt = errors.New("corrupt session file")
+
+// migrations[i] upgrades a decoded session file from version i to i+1.
+// Migrations work on the raw JSON object so they can handle fields the
+// current ChatSession no longer has.
+var migrations = []func(raw map[string]any) error{
+	migrateV0,
+	migrateV1,
+	migrateV2,
+	migrateV3,
+}
+
+// migrateV0 upgrades files from before the version field was added. Their
+// history may be null and created_at may be missing.
+func migrateV0(raw map[string]any) error {
+	switch history := raw["history"].(type) {
+	case nil:
+		raw["history"] = []any{}
+	case []any:
+		for i, message := range history {
+			if _, ok := message.(string); !ok {
+				return fmt.Errorf("history[%d] is not a string", i)
+			}
+		}
+	default:
+		return fmt.Errorf("history is not a list")
+	}
+
+	if _, ok := raw["created_at"]; !ok {
+		if updated, ok := raw["updated_at"]; ok {
+			raw["created_at"] = updated
+		}
+	}
+	return nil
+}
+
+// migrateV1 adds the message tree, holding the history as its only branch
+func migrateV1(raw map[string]any) error {
+	history, _ := raw["history"].([]any)
+	messages := make([]any, len(history))
+	parent := ""
+	for i, content := range history {
+		id := messageID(i + 1)
+		messages[i] = map[string]any{"id": id, "parent": parent, "role": messageRole(i), "content": content}
+		parent = id
+	}
+	raw["messages"] = messages
+	raw["current"] = parent
+	return nil
+}
+
+// migrateV2 upgrades files from before tags, pinning and folders, which
+// need no changes: the version is raised so that older builds, which would
+// drop the new fields when saving, leave the files alone
+func migrateV2(raw map[string]any) error {
+	return nil
+}
+
+// migrateV3 upgrades files from before generated summaries, which need no
+// changes beyond the version, for the same reason as migrateV2
+func migrateV3(raw map[string]any) error {
+	return nil
+}
+
+// decodeSession parses a session file, upgrading it to SchemaVersion. It
+// also returns the version the file was written with.
+func decodeSession(data []byte) (*ChatSession, int, error) {
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, 0, err
+	}
+	raw, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("not a session object")
+	}
+
+	version := 0
+	if v, ok := raw["version"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return nil, 0, fmt.Errorf("invalid version %v", v)
+		}
+		version = int(n)
+	}
+	if version > SchemaVersion {
+		return nil, version, fmt.Errorf("%w (version %d)", ErrNewerVersion, version)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return nil, version, fmt.Errorf("migrating from version %d: %w", v, err)
+		}
+	}
+	raw["version"] = SchemaVersion
+
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, version, err
+	}
+	var session ChatSession
+	if err := json.Unmarshal(upgraded, &session); err != nil {
+		return nil, version, err
+	}
+	return &session, version, nil
+}
+
+// DecodeSession parses the JSON of a session file, such as one exported by
+// `lamacli history export`, upgrading it to the current schema version.
+func DecodeSession(data []byte) (*ChatSession, error) {
+	session, _, err := decodeSession(data)
+	return session, err
+}
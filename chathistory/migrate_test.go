@@ -0,0 +1,104 @@
+package chathistory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeSession(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	tree := []Message{
+		{ID: "m1", Role: "user", Content: "hi"},
+		{ID: "m2", Parent: "m1", Role: "assistant", Content: "hello"},
+	}
+
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		want        ChatSession
+	}{
+		{
+			name:        "version 0",
+			data:        `{"id":"a","title":"T","model":"llama3","history":["hi","hello"],"created_at":"2024-05-01T09:00:00Z","updated_at":"2024-05-02T09:00:00Z"}`,
+			wantVersion: 0,
+			want:        ChatSession{ID: "a", Title: "T", Model: "llama3", History: []string{"hi", "hello"}, Messages: tree, Current: "m2", CreatedAt: created, UpdatedAt: updated},
+		},
+		{
+			name:        "version 0 with null history",
+			data:        `{"id":"a","title":"T","history":null,"updated_at":"2024-05-02T09:00:00Z"}`,
+			wantVersion: 0,
+			want:        ChatSession{ID: "a", Title: "T", History: []string{}, Messages: []Message{}, CreatedAt: updated, UpdatedAt: updated},
+		},
+		{
+			name:        "version 1",
+			data:        `{"version":1,"id":"a","title":"T","history":["hi","hello"],"created_at":"2024-05-01T09:00:00Z","updated_at":"2024-05-02T09:00:00Z"}`,
+			wantVersion: 1,
+			want:        ChatSession{ID: "a", Title: "T", History: []string{"hi", "hello"}, Messages: tree, Current: "m2", CreatedAt: created, UpdatedAt: updated},
+		},
+		{
+			name: "version 2",
+			data: `{"version":2,"id":"a","title":"T","history":["hi","hello"],"created_at":"2024-05-01T09:00:00Z","updated_at":"2024-05-02T09:00:00Z",` +
+				`"messages":[{"id":"m1","role":"user","content":"hi"},{"id":"m2","parent":"m1","role":"assistant","content":"hello"}],"current":"m2"}`,
+			wantVersion: 2,
+			want:        ChatSession{ID: "a", Title: "T", History: []string{"hi", "hello"}, Messages: tree, Current: "m2", CreatedAt: created, UpdatedAt: updated},
+		},
+		{
+			name:        "version 3",
+			data:        `{"version":3,"id":"a","title":"T","tags":["go"],"pinned":true,"folder":"work","history":[],"created_at":"2024-05-01T09:00:00Z","updated_at":"2024-05-02T09:00:00Z"}`,
+			wantVersion: 3,
+			want:        ChatSession{ID: "a", Title: "T", Tags: []string{"go"}, Pinned: true, Folder: "work", History: []string{}, CreatedAt: created, UpdatedAt: updated},
+		},
+		{
+			name:        "current version",
+			data:        `{"version":4,"id":"a","title":"T","renamed":true,"summary":"S","history":[],"created_at":"2024-05-01T09:00:00Z","updated_at":"2024-05-02T09:00:00Z"}`,
+			wantVersion: 4,
+			want:        ChatSession{ID: "a", Title: "T", Renamed: true, Summary: "S", History: []string{}, CreatedAt: created, UpdatedAt: updated},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, version, err := decodeSession([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", version, tt.wantVersion)
+			}
+			tt.want.Version = SchemaVersion
+			if !reflect.DeepEqual(*session, tt.want) {
+				t.Errorf("session = %+v\nwant %+v", *session, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSessionErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		newer bool
+	}{
+		{name: "not JSON", data: `{"id":`},
+		{name: "not an object", data: `["hi"]`},
+		{name: "fractional version", data: `{"version":1.5,"history":[]}`},
+		{name: "negative version", data: `{"version":-1,"history":[]}`},
+		{name: "history not a list", data: `{"history":"hi"}`},
+		{name: "message not a string", data: `{"history":["hi",3]}`},
+		{name: "newer version", data: `{"version":99,"history":[]}`, newer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeSession([]byte(tt.data))
+			if err == nil {
+				t.Fatal("decodeSession() succeeded")
+			}
+			if errors.Is(err, ErrNewerVersion) != tt.newer {
+				t.Errorf("error = %v, ErrNewerVersion %v", err, tt.newer)
+			}
+		})
+	}
+}
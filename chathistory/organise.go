@@ -0,0 +1,193 @@
+package chathistory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// updateSession changes a saved session's details under the lock, without
+// touching its history or update time, and returns the updated session
+func (chm *ChatHistoryManager) updateSession(ref string, update func(*ChatSession) error) (*ChatSession, error) {
+	// Resolve "last" first: listing takes the lock itself
+	found, err := chm.LoadSessionRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := chm.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	session, _, err := chm.readSession(found.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(session); err != nil {
+		return nil, err
+	}
+	if err := chm.writeSession(session); err != nil {
+		return nil, err
+	}
+	chm.indexSession(session)
+	return session, nil
+}
+
+// RenameSession changes the title of a saved session
+func (chm *ChatHistoryManager) RenameSession(sessionID, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("title must not be empty")
+	}
+	_, err := chm.updateSession(sessionID, func(session *ChatSession) error {
+		session.Title = title
+		session.Renamed = true
+		return nil
+	})
+	return err
+}
+
+// TagSession adds tags to a saved session and removes others, returning
+// the session's tags afterwards.
+func (chm *ChatHistoryManager) TagSession(sessionID string, add, remove []string) ([]string, error) {
+	session, err := chm.updateSession(sessionID, func(session *ChatSession) error {
+		session.Tags = mergeTags(session.Tags, add, remove)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session.Tags, nil
+}
+
+// SetSessionTags replaces the tags of a saved session, returning them as
+// stored.
+func (chm *ChatHistoryManager) SetSessionTags(sessionID string, tags []string) ([]string, error) {
+	session, err := chm.updateSession(sessionID, func(session *ChatSession) error {
+		session.Tags = mergeTags(nil, tags, nil)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session.Tags, nil
+}
+
+// mergeTags returns tags with others added and removed, normalized and
+// sorted
+func mergeTags(tags, add, remove []string) []string {
+	set := make(map[string]bool)
+	for _, tag := range append(append([]string{}, tags...), add...) {
+		if tag = NormalizeTag(tag); tag != "" {
+			set[tag] = true
+		}
+	}
+	for _, tag := range remove {
+		delete(set, NormalizeTag(tag))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return sortedKeys(set)
+}
+
+// PinSession pins a saved session, listing it first, or unpins it
+func (chm *ChatHistoryManager) PinSession(sessionID string, pinned bool) error {
+	_, err := chm.updateSession(sessionID, func(session *ChatSession) error {
+		session.Pinned = pinned
+		return nil
+	})
+	return err
+}
+
+// MoveSession moves a saved session into a folder, or out of any folder
+// when folder is empty or "/", and returns the folder it ended up in.
+func (chm *ChatHistoryManager) MoveSession(sessionID, folder string) (string, error) {
+	folder = NormalizeFolder(folder)
+	_, err := chm.updateSession(sessionID, func(session *ChatSession) error {
+		session.Folder = folder
+		return nil
+	})
+	return folder, err
+}
+
+// NormalizeTag returns a tag in its stored form: lowercase, without a
+// leading '#' and with spaces turned into dashes.
+func NormalizeTag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	return strings.ToLower(strings.Join(strings.Fields(tag), "-"))
+}
+
+// ParseTags splits a list of tags separated by commas or spaces.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if tag = NormalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NormalizeFolder returns a folder path in its stored form, such as
+// "work/api", without empty or surrounding slashes.
+func NormalizeFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// HasTag reports whether the session has a tag.
+func (info SessionInfo) HasTag(tag string) bool {
+	tag = NormalizeTag(tag)
+	for _, t := range info.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// InFolder reports whether the session is in a folder or one of its
+// subfolders.
+func (info SessionInfo) InFolder(folder string) bool {
+	folder = NormalizeFolder(folder)
+	return info.Folder == folder || strings.HasPrefix(info.Folder, folder+"/")
+}
+
+// AllTags returns the tags used by any of the sessions, sorted.
+func AllTags(sessions []SessionInfo) []string {
+	tags := make(map[string]bool)
+	for _, session := range sessions {
+		for _, tag := range session.Tags {
+			tags[tag] = true
+		}
+	}
+	return sortedKeys(tags)
+}
+
+// AllFolders returns the folders holding any of the sessions, sorted.
+func AllFolders(sessions []SessionInfo) []string {
+	folders := make(map[string]bool)
+	for _, session := range sessions {
+		if session.Folder != "" {
+			folders[session.Folder] = true
+		}
+	}
+	return sortedKeys(folders)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,515 @@
+package chathistory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// indexVersion changes whenever tokenizing or the index layout does, which
+// rebuilds the index
+const indexVersion = 2
+
+// Ranking parameters. Results are ranked with BM25, treating each message
+// and each title as a document.
+const (
+	bm25K1       = 1.2
+	bm25B        = 0.75
+	titleBoost   = 2.0 // Titles summarize a session, so they weigh more
+	prefixWeight = 0.5 // Weight of a term only matched by prefix
+	minPrefixLen = 3   // Shorter query terms only match whole terms
+	snippetWidth = 60  // Characters of context on each side of a match
+)
+
+// ErrEmptyQuery is returned for search queries without any searchable words
+var ErrEmptyQuery = errors.New("search query has no words of two or more characters")
+
+// SearchResult is a single message matching a search query
+type SearchResult struct {
+	Session      *ChatSession
+	MessageIndex int      // Index into Session.History, or -1 for a title match
+	Snippet      string   // Text surrounding the match
+	Highlights   [][2]int // Byte ranges of the matched terms in Snippet
+	Score        float64
+}
+
+// Highlight returns the snippet with each matched term passed through mark
+func (r SearchResult) Highlight(mark func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, h := range r.Highlights {
+		b.WriteString(r.Snippet[last:h[0]])
+		b.WriteString(mark(r.Snippet[h[0]:h[1]]))
+		last = h[1]
+	}
+	b.WriteString(r.Snippet[last:])
+	return b.String()
+}
+
+// searchIndex is an inverted index from terms to the messages containing
+// them. Each session's entry is stored on its own, so saving a session
+// rewrites only its entry; the terms are gathered when the index is loaded.
+// Sessions are re-indexed when saved and whenever their file changed behind
+// the index's back.
+type searchIndex struct {
+	Sessions map[string]*indexedSession
+	Terms    map[string][]posting
+	stored   []string // IDs of every stored entry, usable or not
+}
+
+// indexedSession is the stored index entry of a session
+type indexedSession struct {
+	Version   int                    `json:"version"`
+	ModTime   time.Time              `json:"mod_time"`   // Of the session file when indexed
+	UpdatedAt time.Time              `json:"updated_at"` // For ranking ties
+	Documents map[int]map[string]int `json:"documents"`  // Term counts per message, -1 for the title
+
+	lengths map[int]int // Terms per message
+}
+
+// posting is an occurrence of a term in a message
+type posting struct {
+	Session string
+	Message int
+	Count   int
+}
+
+// document identifies a message, or with message -1 the title, of a session
+type document struct {
+	session string
+	message int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		Sessions: make(map[string]*indexedSession),
+		Terms:    make(map[string][]posting),
+	}
+}
+
+// newIndexEntry counts the terms of a session's title and messages
+func newIndexEntry(session *ChatSession, modTime time.Time) *indexedSession {
+	entry := &indexedSession{
+		Version:   indexVersion,
+		ModTime:   modTime,
+		UpdatedAt: session.UpdatedAt,
+		Documents: make(map[int]map[string]int),
+	}
+	addDocument := func(message int, text string) {
+		counts := make(map[string]int)
+		for _, token := range tokenize(text) {
+			counts[token.term]++
+		}
+		if len(counts) > 0 {
+			entry.Documents[message] = counts
+		}
+	}
+
+	// The summary counts as part of the title
+	title := session.Summary
+	if !generatedTitle(session) {
+		title = session.Title + " " + title
+	}
+	addDocument(-1, title)
+	for i, message := range session.History {
+		addDocument(i, message)
+	}
+	return entry
+}
+
+// add indexes a session, replacing anything indexed for it before, and
+// returns its entry
+func (idx *searchIndex) add(session *ChatSession, modTime time.Time) *indexedSession {
+	entry := newIndexEntry(session, modTime)
+	idx.put(session.ID, entry)
+	return entry
+}
+
+// put adds a session's entry to the terms, replacing anything indexed for
+// it before
+func (idx *searchIndex) put(sessionID string, entry *indexedSession) {
+	idx.remove(sessionID)
+
+	entry.lengths = make(map[int]int)
+	for message, counts := range entry.Documents {
+		for term, count := range counts {
+			idx.Terms[term] = append(idx.Terms[term], posting{Session: sessionID, Message: message, Count: count})
+			entry.lengths[message] += count
+		}
+	}
+	idx.Sessions[sessionID] = entry
+}
+
+// generatedTitle reports whether a session's title was made from its first
+// message, and so would only duplicate that message's matches
+func generatedTitle(session *ChatSession) bool {
+	for i := 0; i < len(session.History); i += 2 {
+		if session.History[i] == "" {
+			continue
+		}
+		first := strings.TrimSpace(session.History[i])
+		return session.Title == first ||
+			strings.HasSuffix(session.Title, "...") && strings.HasPrefix(first, strings.TrimSuffix(session.Title, "..."))
+	}
+	return false
+}
+
+// remove drops a session from the index
+func (idx *searchIndex) remove(sessionID string) {
+	entry, ok := idx.Sessions[sessionID]
+	if !ok {
+		return
+	}
+	for _, counts := range entry.Documents {
+		for term := range counts {
+			postings := idx.Terms[term][:0]
+			for _, p := range idx.Terms[term] {
+				if p.Session != sessionID {
+					postings = append(postings, p)
+				}
+			}
+			if len(postings) == 0 {
+				delete(idx.Terms, term)
+			} else {
+				idx.Terms[term] = postings
+			}
+		}
+	}
+	delete(idx.Sessions, sessionID)
+}
+
+// loadIndex reads the stored index entries. Entries that are unreadable or
+// from another index version are left out, so their sessions are indexed
+// again.
+func (chm *ChatHistoryManager) loadIndex() *searchIndex {
+	idx := newSearchIndex()
+	stored, err := chm.store.ReadIndex()
+	if err != nil {
+		return idx
+	}
+	for sessionID, data := range stored {
+		idx.stored = append(idx.stored, sessionID)
+		entry := &indexedSession{}
+		if err := json.Unmarshal(data, entry); err != nil || entry.Version != indexVersion || entry.Documents == nil {
+			continue
+		}
+		idx.put(sessionID, entry)
+	}
+	return idx
+}
+
+// saveEntry stores the index entry of a session
+func (chm *ChatHistoryManager) saveEntry(sessionID string, entry *indexedSession) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return chm.store.WriteIndex(sessionID, data)
+}
+
+// indexSession updates the index after a session was written. The caller
+// must hold the lock. Failures are ignored: the next search notices the
+// session changed and indexes it then.
+func (chm *ChatHistoryManager) indexSession(session *ChatSession) {
+	// Take the time from the store, as refreshIndex does, so the entry is
+	// not seen as stale
+	modified, err := chm.store.Modified(session.ID)
+	if err != nil {
+		return
+	}
+	chm.saveEntry(session.ID, newIndexEntry(session, modified))
+}
+
+// unindexSession drops a deleted session from the index. The caller must
+// hold the lock.
+func (chm *ChatHistoryManager) unindexSession(sessionID string) {
+	chm.store.DeleteIndex(sessionID)
+}
+
+// refreshIndex brings the index up to date with the stored sessions,
+// indexing files written by older versions, other tools or repairs
+func (chm *ChatHistoryManager) refreshIndex() (*searchIndex, error) {
+	unlock, err := chm.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := chm.store.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := chm.loadIndex()
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		present[entry.ID] = true
+		if indexed, ok := idx.Sessions[entry.ID]; ok && indexed.ModTime.Equal(entry.Modified) {
+			continue
+		}
+
+		session, _, err := chm.readSession(entry.ID)
+		if err != nil {
+			// Unreadable sessions are reported by ListSessions, not searched
+			delete(present, entry.ID)
+			continue
+		}
+		if err := chm.saveEntry(entry.ID, idx.add(session, entry.Modified)); err != nil {
+			return nil, fmt.Errorf("failed to save search index: %w", err)
+		}
+	}
+	for _, sessionID := range idx.stored {
+		if present[sessionID] {
+			continue
+		}
+		idx.remove(sessionID)
+		if err := chm.store.DeleteIndex(sessionID); err != nil {
+			return nil, fmt.Errorf("failed to save search index: %w", err)
+		}
+	}
+	return idx, nil
+}
+
+// SearchSessions performs a full-text search over the titles and messages
+// of all sessions. Every query term must match, either whole or, for terms
+// of three or more characters, as a prefix. Results are ranked by
+// relevance, best first.
+func (chm *ChatHistoryManager) SearchSessions(query string) ([]SearchResult, error) {
+	queryTerms := uniqueTerms(query)
+	if len(queryTerms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	idx, err := chm.refreshIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	documents, totalLength := 0, 0
+	for _, entry := range idx.Sessions {
+		for _, length := range entry.lengths {
+			documents++
+			totalLength += length
+		}
+	}
+	if documents == 0 {
+		return nil, nil
+	}
+	avgLength := float64(totalLength) / float64(documents)
+
+	var allTerms []string
+	for term := range idx.Terms {
+		allTerms = append(allTerms, term)
+	}
+	sort.Strings(allTerms)
+
+	scores := make(map[document]float64)
+	matched := make(map[document]int)
+	for _, queryTerm := range queryTerms {
+		best := make(map[document]float64)
+		for _, term := range expandTerm(queryTerm, allTerms) {
+			weight := 1.0
+			if term != queryTerm {
+				weight = prefixWeight
+			}
+			postings := idx.Terms[term]
+			df := float64(len(postings))
+			idf := math.Log(1 + (float64(documents)-df+0.5)/(df+0.5))
+			for _, p := range postings {
+				doc := document{p.Session, p.Message}
+				tf := float64(p.Count)
+				length := float64(idx.Sessions[p.Session].lengths[p.Message])
+				score := weight * idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLength))
+				if p.Message == -1 {
+					score *= titleBoost
+				}
+				best[doc] = max(best[doc], score)
+			}
+		}
+		for doc, score := range best {
+			scores[doc] += score
+			matched[doc]++
+		}
+	}
+
+	var docs []document
+	for doc := range scores {
+		if matched[doc] == len(queryTerms) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		a, b := docs[i], docs[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if a.session != b.session {
+			return idx.Sessions[a.session].UpdatedAt.After(idx.Sessions[b.session].UpdatedAt)
+		}
+		return a.message < b.message
+	})
+
+	sessions := make(map[string]*ChatSession)
+	var results []SearchResult
+	for _, doc := range docs {
+		session, ok := sessions[doc.session]
+		if !ok {
+			if session, err = chm.LoadSession(doc.session); err != nil {
+				continue
+			}
+			sessions[doc.session] = session
+		}
+
+		text := session.Title
+		if doc.message >= 0 {
+			if doc.message >= len(session.History) {
+				continue
+			}
+			text = session.History[doc.message]
+		}
+		snippet, highlights := matchSnippet(text, queryTerms)
+		results = append(results, SearchResult{
+			Session:      session,
+			MessageIndex: doc.message,
+			Snippet:      snippet,
+			Highlights:   highlights,
+			Score:        scores[doc],
+		})
+	}
+	return results, nil
+}
+
+// expandTerm returns the indexed terms a query term matches: itself and,
+// if long enough, every term it is a prefix of
+func expandTerm(queryTerm string, sortedTerms []string) []string {
+	if utf8.RuneCountInString(queryTerm) < minPrefixLen {
+		return []string{queryTerm}
+	}
+	var terms []string
+	for i := sort.SearchStrings(sortedTerms, queryTerm); i < len(sortedTerms) && strings.HasPrefix(sortedTerms[i], queryTerm); i++ {
+		terms = append(terms, sortedTerms[i])
+	}
+	return terms
+}
+
+// termMatches reports whether an indexed term matches a query term
+func termMatches(term, queryTerm string) bool {
+	if utf8.RuneCountInString(queryTerm) < minPrefixLen {
+		return term == queryTerm
+	}
+	return strings.HasPrefix(term, queryTerm)
+}
+
+// matchSnippet returns the text around the first matched term, collapsed
+// onto a single line, and the ranges of the matched terms within it
+func matchSnippet(text string, queryTerms []string) (string, [][2]int) {
+	text = strings.Join(strings.Fields(text), " ")
+
+	var matches []token
+	for _, t := range tokenize(text) {
+		for _, queryTerm := range queryTerms {
+			if termMatches(t.term, queryTerm) {
+				matches = append(matches, t)
+				break
+			}
+		}
+	}
+
+	from, to := 0, len(text)
+	if len(matches) > 0 {
+		from = max(0, matches[0].start-snippetWidth)
+		to = min(len(text), matches[0].end+snippetWidth)
+	} else {
+		to = min(len(text), 2*snippetWidth)
+	}
+	// Avoid cutting multi-byte characters in half
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	snippet := text[from:to]
+	offset := -from
+	if from > 0 {
+		snippet = "..." + snippet
+		offset += 3
+	}
+	if to < len(text) {
+		snippet += "..."
+	}
+
+	var highlights [][2]int
+	for _, m := range matches {
+		if m.start >= from && m.end <= to {
+			highlights = append(highlights, [2]int{m.start + offset, m.end + offset})
+		}
+	}
+	return snippet, highlights
+}
+
+// token is a normalized term and where it appears in the text
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into normalized terms: lowercase runs of letters and
+// digits, ignoring single characters and very long runs such as encoded
+// data. A plural "s" is dropped so "rewrites" matches "rewrite".
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if term := normalizeTerm(text[start:end]); term != "" {
+			tokens = append(tokens, token{term: term, start: start, end: end})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+func normalizeTerm(word string) string {
+	n := utf8.RuneCountInString(word)
+	if n < 2 || n > 40 {
+		return ""
+	}
+	term := strings.ToLower(word)
+	if n > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+		term = term[:len(term)-1]
+	}
+	return term
+}
+
+// uniqueTerms returns the distinct terms of a query
+func uniqueTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
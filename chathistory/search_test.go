@@ -0,0 +1,224 @@
+package chathistory
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// postingsOf returns the sessions and messages indexed under a term
+func postingsOf(idx *searchIndex, term string) []document {
+	var docs []document
+	for _, p := range idx.Terms[term] {
+		docs = append(docs, document{p.Session, p.Message})
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].session != docs[j].session {
+			return docs[i].session < docs[j].session
+		}
+		return docs[i].message < docs[j].message
+	})
+	return docs
+}
+
+func TestSearchIndexAddRemove(t *testing.T) {
+	idx := newSearchIndex()
+	idx.add(&ChatSession{ID: "a", Title: "Docker networking", History: []string{"How do bridges work?", "Bridges connect containers."}}, time.Time{})
+	idx.add(&ChatSession{ID: "b", Title: "Go generics", History: []string{"Explain generics", "Generics add type parameters."}}, time.Time{})
+
+	tests := []struct {
+		term string
+		want []document
+	}{
+		{"docker", []document{{"a", -1}}},
+		{"bridge", []document{{"a", 0}, {"a", 1}}}, // Plural folded
+		{"generic", []document{{"b", -1}, {"b", 0}, {"b", 1}}},
+		{"do", []document{{"a", 0}}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		if got := postingsOf(idx, tt.term); !equalDocuments(got, tt.want) {
+			t.Errorf("postings of %q = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+
+	// Re-adding replaces what was indexed before
+	idx.add(&ChatSession{ID: "a", Title: "Docker volumes", History: []string{"Mount a volume"}}, time.Time{})
+	if got := postingsOf(idx, "bridge"); got != nil {
+		t.Errorf("postings of a replaced term = %v", got)
+	}
+	if got := postingsOf(idx, "volume"); !equalDocuments(got, []document{{"a", -1}, {"a", 0}}) {
+		t.Errorf("postings of an added term = %v", got)
+	}
+
+	idx.remove("a")
+	if _, ok := idx.Sessions["a"]; ok {
+		t.Error("removed session is still indexed")
+	}
+	for term, postings := range idx.Terms {
+		for _, p := range postings {
+			if p.Session == "a" {
+				t.Errorf("term %q still lists the removed session", term)
+			}
+		}
+	}
+	if _, ok := idx.Terms["docker"]; ok {
+		t.Error("term only the removed session had is still indexed")
+	}
+	if got := postingsOf(idx, "generic"); len(got) != 3 {
+		t.Errorf("removing a session changed another's postings: %v", got)
+	}
+	idx.remove("a") // Removing twice is harmless
+}
+
+func equalDocuments(a, b []document) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSearchIndexStores(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			chm := newTestManager(t, backend)
+			saved := &ChatSession{Title: "Kubernetes", History: []string{"Scale a deployment", "Use kubectl scale."}}
+			if err := chm.SaveSession(saved); err != nil {
+				t.Fatal(err)
+			}
+
+			// Saving indexes the session with the store's time, so a
+			// refresh sees it as current
+			entry, ok := chm.loadIndex().Sessions[saved.ID]
+			if !ok {
+				t.Fatal("saved session is not indexed")
+			}
+			modified, err := chm.store.Modified(saved.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !entry.ModTime.Equal(modified) {
+				t.Errorf("indexed time %v, store time %v", entry.ModTime, modified)
+			}
+			marker := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			entry.UpdatedAt = marker
+			if err := chm.saveEntry(saved.ID, entry); err != nil {
+				t.Fatal(err)
+			}
+			idx, err := chm.refreshIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !idx.Sessions[saved.ID].UpdatedAt.Equal(marker) {
+				t.Error("refresh re-indexed a session indexed when saved")
+			}
+
+			// Sessions written and deleted behind the index's back are
+			// picked up by the next refresh
+			behind := &ChatSession{ID: NewSessionID(), Title: "Postgres", History: []string{"Vacuum tables", "Run VACUUM."}, UpdatedAt: time.Now()}
+			if err := chm.writeSession(behind); err != nil {
+				t.Fatal(err)
+			}
+			if err := chm.store.Delete(saved.ID); err != nil {
+				t.Fatal(err)
+			}
+			idx, err = chm.refreshIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := idx.Sessions[behind.ID]; !ok {
+				t.Error("refresh did not index a new session")
+			}
+			if _, ok := idx.Sessions[saved.ID]; ok {
+				t.Error("refresh kept a deleted session")
+			}
+			if stored, ok := chm.loadIndex().Sessions[behind.ID]; !ok || stored.ModTime.IsZero() {
+				t.Error("refresh did not save the index")
+			}
+
+			results, err := chm.SearchSessions("vacuum")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 || results[0].Session.ID != behind.ID {
+				t.Errorf("search found %d results, want the session written behind the index", len(results))
+			}
+			if results, _ := chm.SearchSessions("kubectl"); len(results) != 0 {
+				t.Errorf("search found %d results in a deleted session", len(results))
+			}
+
+			if err := chm.DeleteSession(behind.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := chm.loadIndex().Sessions[behind.ID]; ok {
+				t.Error("DeleteSession left the session indexed")
+			}
+		})
+	}
+}
+
+func TestSearchIndexEntriesPerSession(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			chm := newTestManager(t, backend)
+			a := &ChatSession{Title: "Rust lifetimes", History: []string{"Explain borrowing", "References borrow values."}}
+			b := &ChatSession{Title: "Shell scripts", History: []string{"Loop over files", "Use a for loop."}}
+			for _, session := range []*ChatSession{a, b} {
+				if err := chm.SaveSession(session); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := chm.store.ReadIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(before) != 2 {
+				t.Fatalf("index holds %d entries, want one per session", len(before))
+			}
+
+			// Saving and deleting a session leave the other's entry alone
+			a.History = append(a.History, "What about 'static?", "It lives for the whole program.")
+			if err := chm.SaveSession(a); err != nil {
+				t.Fatal(err)
+			}
+			after, err := chm.store.ReadIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(after[b.ID]) != string(before[b.ID]) {
+				t.Error("saving a session rewrote another session's entry")
+			}
+			if string(after[a.ID]) == string(before[a.ID]) {
+				t.Error("saving a session did not update its entry")
+			}
+
+			if err := chm.DeleteSession(a.ID); err != nil {
+				t.Fatal(err)
+			}
+			after, err = chm.store.ReadIndex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := after[a.ID]; ok || len(after) != 1 || string(after[b.ID]) != string(before[b.ID]) {
+				t.Errorf("after deleting a session the index holds %d entries", len(after))
+			}
+
+			// Entries from another index version are re-indexed
+			if err := chm.store.WriteIndex(b.ID, []byte(`{"version":1}`)); err != nil {
+				t.Fatal(err)
+			}
+			results, err := chm.SearchSessions("loop")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 || results[0].Session.ID != b.ID {
+				t.Errorf("search found %d results after an outdated entry", len(results))
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package chathistory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Storage backends, as named by the history_backend config key
+const (
+	BackendJSON = "json" // One JSON file per session
+	BackendBolt = "bolt" // An embedded bbolt database with indexed metadata
+)
+
+// Backends lists the storage backends.
+var Backends = []string{BackendJSON, BackendBolt}
+
+// Store keeps the encoded sessions of a ChatHistoryManager. The manager
+// decodes, migrates and checks them; stores only keep the bytes.
+type Store interface {
+	// Lock serialises writes between lamacli instances, waiting for the
+	// others, and returns a function that releases it
+	Lock() (unlock func(), err error)
+	// Read returns an encoded session. Errors for missing sessions wrap
+	// fs.ErrNotExist.
+	Read(id string) ([]byte, error)
+	// Write stores an encoded session, given decoded for indexing
+	Write(session *ChatSession, data []byte) error
+	Delete(id string) error
+	// Entries lists every stored session, whether or not it decodes
+	Entries() ([]Entry, error)
+	// Modified returns when a session was last written
+	Modified(id string) (time.Time, error)
+	// ReadIndex returns the search index entry of every session, by ID.
+	// WriteIndex and DeleteIndex change the entry of one session only.
+	ReadIndex() (map[string][]byte, error)
+	WriteIndex(id string, data []byte) error
+	DeleteIndex(id string) error
+	// Name returns how reports refer to a stored session
+	Name(id string) string
+}
+
+// Lister is implemented by stores that index session metadata, so that
+// sessions can be listed without reading their messages.
+type Lister interface {
+	List(query Query) (*Page, error)
+}
+
+// Entry is a stored session.
+type Entry struct {
+	ID       string
+	Modified time.Time
+}
+
+// openStore opens the store of a backend in the history directory
+func openStore(backend, historyDir string) (Store, error) {
+	switch backend {
+	case BackendJSON, "":
+		return newJSONStore(historyDir), nil
+	case BackendBolt:
+		return newBoltStore(historyDir), nil
+	default:
+		return nil, fmt.Errorf("unknown history backend '%s'. Please use %s", backend, strings.Join(Backends, " or "))
+	}
+}
+
+// SessionInfo is the metadata of a session, which lists show without its
+// messages.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Summary   string    `json:"summary"`
+	Pinned    bool      `json:"pinned"`
+	Folder    string    `json:"folder"`
+	Tags      []string  `json:"tags"`
+	Model     string    `json:"model"`
+	Messages  int       `json:"messages"` // Exchanges in the branch shown
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Info returns the metadata of the session.
+func (session *ChatSession) Info() SessionInfo {
+	return SessionInfo{
+		ID:        session.ID,
+		Title:     session.Title,
+		Summary:   session.Summary,
+		Pinned:    session.Pinned,
+		Folder:    session.Folder,
+		Tags:      append([]string{}, session.Tags...),
+		Model:     session.Model,
+		Messages:  len(session.History) / 2,
+		CreatedAt: session.CreatedAt,
+		UpdatedAt: session.UpdatedAt,
+	}
+}
+
+// GetSessionSummary returns a brief summary of the session
+func (session SessionInfo) GetSessionSummary() string {
+	messageCount := session.Messages
+	timeAgo := time.Since(session.UpdatedAt)
+
+	var timeStr string
+	if timeAgo < time.Hour {
+		timeStr = fmt.Sprintf("%d minutes ago", int(timeAgo.Minutes()))
+	} else if timeAgo < 24*time.Hour {
+		timeStr = fmt.Sprintf("%d hours ago", int(timeAgo.Hours()))
+	} else {
+		timeStr = fmt.Sprintf("%d days ago", int(timeAgo.Hours()/24))
+	}
+
+	return fmt.Sprintf("%s (%d messages, %s)", session.Title, messageCount, timeStr)
+}
+
+// Query selects a page of sessions by their metadata. Zero values match
+// every session.
+type Query struct {
+	Tag         string
+	Folder      string // Includes subfolders
+	Pinned      bool   // Only pinned sessions
+	PinnedFirst bool   // List pinned sessions before the others
+	Offset      int
+	Limit       int // 0 for no limit
+}
+
+// Page is the result of a Query.
+type Page struct {
+	Sessions []SessionInfo
+	Total    int // Sessions matching the query, on every page
+}
+
+// matches reports whether a session matches the query's filters
+func (query Query) matches(info SessionInfo) bool {
+	return (query.Tag == "" || info.HasTag(query.Tag)) &&
+		(query.Folder == "" || info.InFolder(query.Folder)) &&
+		(!query.Pinned || info.Pinned)
+}
+
+// before reports whether a lists before b: newest first, pinned sessions
+// first with PinnedFirst
+func (query Query) before(a, b SessionInfo) bool {
+	if query.PinnedFirst && a.Pinned != b.Pinned {
+		return a.Pinned
+	}
+	if !a.UpdatedAt.Equal(b.UpdatedAt) {
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// page returns the part of the matching sessions the query asks for
+func (query Query) page(matching []SessionInfo) *Page {
+	page := &Page{Sessions: []SessionInfo{}, Total: len(matching)}
+	if query.Offset >= len(matching) {
+		return page
+	}
+	matching = matching[max(0, query.Offset):]
+	if query.Limit > 0 && len(matching) > query.Limit {
+		matching = matching[:query.Limit]
+	}
+	page.Sessions = matching
+	return page
+}
+
+// run answers the query from every session's metadata
+func (query Query) run(infos []SessionInfo) *Page {
+	var matching []SessionInfo
+	for _, info := range infos {
+		if query.matches(info) {
+			matching = append(matching, info)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return query.before(matching[i], matching[j])
+	})
+	return query.page(matching)
+}
+
+// CopyTo copies every readable session into another manager's store,
+// keeping their IDs, and returns how many were copied. Sessions the target
+// holds but this store does not, such as ones deleted since an earlier
+// copy, are deleted so the two match. Sessions that cannot be read are
+// left alone and reported by Issues. Use it to move the history between
+// backends; the sessions stay in this store.
+func (chm *ChatHistoryManager) CopyTo(target *ChatHistoryManager) (int, error) {
+	unlock, err := chm.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	unlockTarget, err := target.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlockTarget()
+
+	entries, err := chm.store.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	chm.mu.Lock()
+	chm.issues = nil
+	chm.mu.Unlock()
+
+	copied := 0
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		present[entry.ID] = true
+		session, _, err := chm.readSession(entry.ID)
+		if err != nil {
+			chm.report(Issue{File: chm.store.Name(entry.ID), Problem: err.Error()})
+			continue
+		}
+		if err := target.writeSession(session); err != nil {
+			return copied, fmt.Errorf("failed to copy %s: %w", entry.ID, err)
+		}
+		target.indexSession(session)
+		copied++
+	}
+
+	stale, err := target.store.Entries()
+	if err != nil {
+		return copied, err
+	}
+	for _, entry := range stale {
+		if present[entry.ID] {
+			continue
+		}
+		if err := target.store.Delete(entry.ID); err != nil {
+			return copied, err
+		}
+		target.unindexSession(entry.ID)
+	}
+	return copied, nil
+}
@@ -0,0 +1,384 @@
+package chathistory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltName is the database file in the history directory
+const boltName = "sessions.db"
+
+// boltTimeout bounds the wait for another lamacli instance to close the
+// database
+const boltTimeout = 30 * time.Second
+
+var (
+	sessionsBucket = []byte("sessions") // ID to encoded session
+	infoBucket     = []byte("info")     // ID to boltInfo
+	updatedBucket  = []byte("updated")  // timeKey of every session
+	pinnedBucket   = []byte("pinned")   // timeKey of pinned sessions
+	tagsBucket     = []byte("tags")     // Tag, 0 and ID
+	foldersBucket  = []byte("folders")  // Folder, 0 and ID
+	metaBucket     = []byte("meta")     // Database-wide values
+	searchBucket   = []byte("search")   // ID to the session's search index entry
+)
+
+// legacyIndexKey is the key in the meta bucket of the single search index
+// older versions kept
+var legacyIndexKey = []byte("search_index")
+
+// boltInfo is the metadata stored for a session
+type boltInfo struct {
+	SessionInfo
+	Modified time.Time `json:"modified"`
+}
+
+// boltStore keeps sessions in a bbolt database, with their metadata
+// indexed by update time, pin, tag and folder. bbolt locks the file while
+// it is open, so the database is only opened for the duration of each
+// operation or Lock.
+type boltStore struct {
+	path string
+
+	mu     sync.Mutex
+	db     *bolt.DB
+	users  int        // Operations and locks using db
+	writer sync.Mutex // Held by Lock
+}
+
+func newBoltStore(dir string) *boltStore {
+	return &boltStore{path: filepath.Join(dir, boltName)}
+}
+
+// acquire opens the database, or shares it when it is already open
+func (s *boltStore) acquire() (*bolt.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: boltTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", s.path, err)
+		}
+		if err := createBuckets(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", s.path, err)
+		}
+		s.db = db
+	}
+	s.users++
+	return s.db, nil
+}
+
+// release closes the database once nothing uses it
+func (s *boltStore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users--
+	if s.users == 0 {
+		s.db.Close()
+		s.db = nil
+	}
+}
+
+// createBuckets creates the buckets of a new database, or those added
+// since an older version created it
+func createBuckets(db *bolt.DB) error {
+	var missing bool
+	db.View(func(tx *bolt.Tx) error {
+		missing = tx.Bucket(searchBucket) == nil
+		return nil
+	})
+	if !missing {
+		return nil
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, infoBucket, updatedBucket, pinnedBucket, tagsBucket, foldersBucket, metaBucket, searchBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Delete(legacyIndexKey)
+	})
+}
+
+func (s *boltStore) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+	return db.View(fn)
+}
+
+func (s *boltStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+	return db.Update(fn)
+}
+
+// Lock keeps the database open, and so locked against other lamacli
+// instances, until released
+func (s *boltStore) Lock() (func(), error) {
+	s.writer.Lock()
+	if _, err := s.acquire(); err != nil {
+		s.writer.Unlock()
+		return nil, fmt.Errorf("failed to lock chat history: %w", err)
+	}
+	return func() {
+		s.release()
+		s.writer.Unlock()
+	}, nil
+}
+
+func (s *boltStore) Read(id string) ([]byte, error) {
+	var data []byte
+	err := s.view(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(sessionsBucket).Get([]byte(id))
+		if stored == nil {
+			return fmt.Errorf("session %s not found: %w", id, fs.ErrNotExist)
+		}
+		data = bytes.Clone(stored)
+		return nil
+	})
+	return data, err
+}
+
+func (s *boltStore) Write(session *ChatSession, data []byte) error {
+	info := boltInfo{SessionInfo: session.Info(), Modified: time.Now()}
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		if old, ok := readInfo(tx, session.ID); ok {
+			if err := unindexInfo(tx, old); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(sessionsBucket).Put([]byte(session.ID), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(infoBucket).Put([]byte(session.ID), encoded); err != nil {
+			return err
+		}
+		return indexInfo(tx, info.SessionInfo)
+	})
+}
+
+func (s *boltStore) Delete(id string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(sessionsBucket).Get([]byte(id)) == nil {
+			return fmt.Errorf("session %s not found: %w", id, fs.ErrNotExist)
+		}
+		if old, ok := readInfo(tx, id); ok {
+			if err := unindexInfo(tx, old); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(infoBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	})
+}
+
+func (s *boltStore) Entries() ([]Entry, error) {
+	var entries []Entry
+	err := s.view(func(tx *bolt.Tx) error {
+		infos := tx.Bucket(infoBucket)
+		return tx.Bucket(sessionsBucket).ForEach(func(id, _ []byte) error {
+			var info boltInfo
+			json.Unmarshal(infos.Get(id), &info)
+			entries = append(entries, Entry{ID: string(id), Modified: info.Modified})
+			return nil
+		})
+	})
+	return entries, err
+}
+
+func (s *boltStore) Modified(id string) (time.Time, error) {
+	var modified time.Time
+	err := s.view(func(tx *bolt.Tx) error {
+		info, ok := readInfo(tx, id)
+		if !ok {
+			return fmt.Errorf("session %s not found: %w", id, fs.ErrNotExist)
+		}
+		modified = info.Modified
+		return nil
+	})
+	return modified, err
+}
+
+func (s *boltStore) ReadIndex() (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchBucket).ForEach(func(id, data []byte) error {
+			entries[string(id)] = bytes.Clone(data)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+func (s *boltStore) WriteIndex(id string, data []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchBucket).Put([]byte(id), data)
+	})
+}
+
+func (s *boltStore) DeleteIndex(id string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchBucket).Delete([]byte(id))
+	})
+}
+
+// Name returns the session's ID, as sessions have no file of their own
+func (s *boltStore) Name(id string) string {
+	return id
+}
+
+// List answers a query from the indexes, reading the metadata of only the
+// sessions on the page unless it filters by tag or folder
+func (s *boltStore) List(query Query) (*Page, error) {
+	var page *Page
+	err := s.view(func(tx *bolt.Tx) error {
+		if query.Tag == "" && query.Folder == "" {
+			page = scanByTime(tx, query)
+			return nil
+		}
+
+		// Narrow the sessions down with the tag or folder index, then
+		// filter and sort their metadata
+		bucket, prefix := tx.Bucket(tagsBucket), []byte(NormalizeTag(query.Tag)+"\x00")
+		if query.Tag == "" {
+			bucket, prefix = tx.Bucket(foldersBucket), []byte(NormalizeFolder(query.Folder))
+		}
+		var candidates []SessionInfo
+		seen := make(map[string]bool)
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			id := string(k[bytes.IndexByte(k, 0)+1:])
+			if info, ok := readInfo(tx, id); ok && !seen[id] {
+				seen[id] = true
+				candidates = append(candidates, info.SessionInfo)
+			}
+		}
+		page = query.run(candidates)
+		return nil
+	})
+	return page, err
+}
+
+// scanByTime pages through the sessions newest first, pinned ones first
+// when asked
+func scanByTime(tx *bolt.Tx, query Query) *Page {
+	updated, pinned := tx.Bucket(updatedBucket), tx.Bucket(pinnedBucket)
+	page := &Page{Sessions: []SessionInfo{}, Total: updated.Stats().KeyN}
+	if query.Pinned {
+		page.Total = pinned.Stats().KeyN
+	}
+
+	offset := query.Offset
+	// visit adds the sessions of a time index to the page, newest first,
+	// and reports whether the page has room for more
+	visit := func(bucket *bolt.Bucket, skip func(key []byte) bool) bool {
+		c := bucket.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if skip != nil && skip(k) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			if query.Limit > 0 && len(page.Sessions) >= query.Limit {
+				return false
+			}
+			if info, ok := readInfo(tx, string(k[8:])); ok {
+				page.Sessions = append(page.Sessions, info.SessionInfo)
+			}
+		}
+		return true
+	}
+
+	switch {
+	case query.Pinned:
+		visit(pinned, nil)
+	case query.PinnedFirst:
+		if visit(pinned, nil) {
+			visit(updated, func(key []byte) bool { return pinned.Get(key) != nil })
+		}
+	default:
+		visit(updated, nil)
+	}
+	return page
+}
+
+// readInfo reads the stored metadata of a session
+func readInfo(tx *bolt.Tx, id string) (boltInfo, bool) {
+	var info boltInfo
+	data := tx.Bucket(infoBucket).Get([]byte(id))
+	if data == nil || json.Unmarshal(data, &info) != nil {
+		return info, false
+	}
+	return info, true
+}
+
+// indexInfo adds a session's metadata to the indexes
+func indexInfo(tx *bolt.Tx, info SessionInfo) error {
+	return forIndexKeys(info, func(bucket, key []byte) error {
+		return tx.Bucket(bucket).Put(key, nil)
+	})
+}
+
+// unindexInfo removes a session's metadata from the indexes
+func unindexInfo(tx *bolt.Tx, info boltInfo) error {
+	return forIndexKeys(info.SessionInfo, func(bucket, key []byte) error {
+		return tx.Bucket(bucket).Delete(key)
+	})
+}
+
+// forIndexKeys calls fn with the key of each index entry of a session
+func forIndexKeys(info SessionInfo, fn func(bucket, key []byte) error) error {
+	key := timeKey(info.UpdatedAt, info.ID)
+	if err := fn(updatedBucket, key); err != nil {
+		return err
+	}
+	if info.Pinned {
+		if err := fn(pinnedBucket, key); err != nil {
+			return err
+		}
+	}
+	for _, tag := range info.Tags {
+		if err := fn(tagsBucket, []byte(tag+"\x00"+info.ID)); err != nil {
+			return err
+		}
+	}
+	if info.Folder != "" {
+		return fn(foldersBucket, []byte(info.Folder+"\x00"+info.ID))
+	}
+	return nil
+}
+
+// timeKey returns a key that sorts by time, then ID
+func timeKey(t time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return append(key, id...)
+}
@@ -0,0 +1,168 @@
+package chathistory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockName is the file in the history directory whose lock serialises
+// writes between lamacli instances
+const lockName = ".lock"
+
+// indexDir is the directory in the history directory holding the search
+// index, one file per session. legacyIndexName is the single index file
+// older versions kept.
+const (
+	indexDir        = "search-index"
+	indexExt        = ".idx"
+	legacyIndexName = "search.idx"
+)
+
+// jsonStore keeps each session in a JSON file named after its ID
+type jsonStore struct {
+	dir string
+}
+
+func newJSONStore(dir string) *jsonStore {
+	return &jsonStore{dir: dir}
+}
+
+// Lock takes the history directory lock
+func (s *jsonStore) Lock() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, lockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chat history lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock chat history: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (s *jsonStore) Read(id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, s.Name(id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	return data, nil
+}
+
+func (s *jsonStore) Write(session *ChatSession, data []byte) error {
+	if err := s.writeFile(s.Name(session.ID), data); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStore) Delete(id string) error {
+	if err := os.Remove(filepath.Join(s.dir, s.Name(id))); err != nil {
+		return fmt.Errorf("failed to delete session file: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStore) Entries() ([]Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue // Deleted meanwhile
+		}
+		entries = append(entries, Entry{ID: strings.TrimSuffix(file.Name(), ".json"), Modified: info.ModTime()})
+	}
+	return entries, nil
+}
+
+func (s *jsonStore) Modified(id string) (time.Time, error) {
+	info, err := os.Stat(filepath.Join(s.dir, s.Name(id)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (s *jsonStore) ReadIndex() (map[string][]byte, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, indexDir))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string][]byte)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), indexExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, indexDir, file.Name()))
+		if err != nil {
+			continue // Deleted meanwhile
+		}
+		entries[strings.TrimSuffix(file.Name(), indexExt)] = data
+	}
+	return entries, nil
+}
+
+func (s *jsonStore) WriteIndex(id string, data []byte) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, indexDir), 0755); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(s.dir, legacyIndexName)) // Superseded
+	return s.writeFile(filepath.Join(indexDir, id+indexExt), data)
+}
+
+func (s *jsonStore) DeleteIndex(id string) error {
+	err := os.Remove(filepath.Join(s.dir, indexDir, id+indexExt))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Name returns the session's file name
+func (s *jsonStore) Name(id string) string {
+	return id + ".json"
+}
+
+// writeFile writes a file in the history directory through a temporary
+// file renamed into place, so readers never see a partial write. The name
+// may lead into a subdirectory.
+func (s *jsonStore) writeFile(name string, data []byte) error {
+	dir, base := filepath.Split(filepath.Join(s.dir, name))
+	tmp, err := os.CreateTemp(dir, "."+base+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
@@ -0,0 +1,123 @@
+package chathistory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Completer is the part of the Ollama client that writes titles and
+// summaries
+type Completer interface {
+	CompleteJSON(ctx context.Context, modelName, systemPrompt string, history []string, schema json.RawMessage) (string, error)
+}
+
+// summarySchema is the JSON schema the titles model's response must follow
+var summarySchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string"},
+    "summary": {"type": "string"}
+  },
+  "required": ["title", "summary"]
+}`)
+
+// summarySystemPrompt instructs the titles model
+const summarySystemPrompt = "You name and summarize saved chat conversations. You are given a transcript. " +
+	"Reply with a title of at most six words, without quotes or a trailing full stop, and a summary of one short " +
+	"paragraph saying what the user wanted and what the answer was. Attached files are shown by name only."
+
+// Limits on the transcript sent to the titles model: characters per
+// message, and bytes in all
+const (
+	summaryMessageLimit = 1500
+	summaryLimit        = 8000
+)
+
+// filePattern matches a file attached to a prompt with '@'
+var filePattern = regexp.MustCompile(`(?s)--- Start of File: (.+?) ---\n.*?\n?--- End of File ---`)
+
+// collapseFiles replaces the contents of attached files with their names
+func collapseFiles(text string) string {
+	return filePattern.ReplaceAllString(text, "📄 $1")
+}
+
+// summaryPrompt returns the transcript of a session for the titles model
+func summaryPrompt(history []string) string {
+	var transcript strings.Builder
+	for i, message := range history {
+		if i == 1 && history[0] == "" {
+			continue // Welcome message
+		}
+		message = strings.TrimSpace(collapseFiles(message))
+		if message == "" {
+			continue
+		}
+		if runes := []rune(message); len(runes) > summaryMessageLimit {
+			message = string(runes[:summaryMessageLimit]) + "..."
+		}
+		role := "User"
+		if i%2 == 1 {
+			role = "Assistant"
+		}
+		entry := fmt.Sprintf("%s: %s\n\n", role, message)
+		if transcript.Len()+len(entry) > summaryLimit {
+			break
+		}
+		transcript.WriteString(entry)
+	}
+	return strings.TrimSpace(transcript.String())
+}
+
+// parseSummary reads the titles model's response
+func parseSummary(response string) (title, summary string, err error) {
+	var parsed struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return "", "", fmt.Errorf("model returned an invalid summary: %w", err)
+	}
+	title = strings.Trim(strings.Join(strings.Fields(parsed.Title), " "), `"'.`)
+	summary = strings.Join(strings.Fields(parsed.Summary), " ")
+	if title == "" || summary == "" {
+		return "", "", fmt.Errorf("model returned an empty title or summary")
+	}
+	if len([]rune(title)) > 60 {
+		title = string([]rune(title)[:57]) + "..."
+	}
+	return title, summary, nil
+}
+
+// SummarizeSession asks a model for a title and summary of a saved session
+// and stores them, returning the updated session. A title the user set
+// with RenameSession is kept.
+func (chm *ChatHistoryManager) SummarizeSession(ctx context.Context, client Completer, model, ref string) (*ChatSession, error) {
+	session, err := chm.LoadSessionRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	prompt := summaryPrompt(session.History)
+	if prompt == "" {
+		return nil, fmt.Errorf("session %s has no messages to summarize", session.ID)
+	}
+
+	response, err := client.CompleteJSON(ctx, model, summarySystemPrompt, []string{prompt}, summarySchema)
+	if err != nil {
+		return nil, err
+	}
+	title, summary, err := parseSummary(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return chm.updateSession(session.ID, func(session *ChatSession) error {
+		if !session.Renamed {
+			session.Title = title
+		}
+		session.Summary = summary
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+package chathistory
+
+import "fmt"
+
+// Message is a message of a session's conversation tree. Editing an earlier
+// message starts a new branch from its parent, so the original
+// continuation is kept as a sibling.
+type Message struct {
+	ID      string `json:"id"`
+	Parent  string `json:"parent,omitempty"` // Empty for the first message
+	Role    string `json:"role"`             // "user" or "assistant"
+	Content string `json:"content"`
+	Model   string `json:"model,omitempty"` // Model that wrote a reply, when known
+}
+
+// messageID returns the ID of the nth message added to a tree
+func messageID(n int) string {
+	return fmt.Sprintf("m%d", n)
+}
+
+// messageRole returns the role of the message at a history index
+func messageRole(index int) string {
+	if index%2 == 0 {
+		return "user"
+	}
+	return "assistant"
+}
+
+// SyncTree records History, the branch being shown, in the message tree.
+// Where History departs from the tree a new branch is added; a reply that
+// was still empty is filled in instead. Saving a session syncs it.
+func (s *ChatSession) SyncTree() {
+	parent := ""
+	for i, content := range s.History {
+		id := s.child(parent, content)
+		if id == "" {
+			id = messageID(len(s.Messages) + 1)
+			s.Messages = append(s.Messages, Message{ID: id, Parent: parent, Role: messageRole(i), Content: content})
+		}
+		parent = id
+	}
+	s.Current = parent
+}
+
+// child returns the ID of the message under parent with the given content,
+// filling in an empty childless message if there is none
+func (s *ChatSession) child(parent, content string) string {
+	empty := -1
+	for i, message := range s.Messages {
+		if message.Parent != parent {
+			continue
+		}
+		if message.Content == content {
+			return message.ID
+		}
+		if message.Content == "" && len(s.children(message.ID)) == 0 {
+			empty = i
+		}
+	}
+	if empty < 0 {
+		return ""
+	}
+	s.Messages[empty].Content = content
+	return s.Messages[empty].ID
+}
+
+// children returns the IDs of the replies to a message, oldest first
+func (s *ChatSession) children(id string) []string {
+	var ids []string
+	for _, message := range s.Messages {
+		if message.Parent == id {
+			ids = append(ids, message.ID)
+		}
+	}
+	return ids
+}
+
+// path returns the messages from the first to Current
+func (s *ChatSession) path() []Message {
+	byID := make(map[string]Message, len(s.Messages))
+	for _, message := range s.Messages {
+		byID[message.ID] = message
+	}
+
+	var path []Message
+	for id := s.Current; id != "" && len(path) <= len(s.Messages); {
+		message, ok := byID[id]
+		if !ok {
+			break
+		}
+		path = append([]Message{message}, path...)
+		id = message.Parent
+	}
+	return path
+}
+
+// treeMatches reports whether History is the branch of the tree ending at
+// Current
+func (s *ChatSession) treeMatches() bool {
+	path := s.path()
+	if len(path) != len(s.History) {
+		return false
+	}
+	for i, message := range path {
+		if message.Content != s.History[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MessageAt returns the message at a history index of the branch shown.
+func (s *ChatSession) MessageAt(index int) (Message, bool) {
+	path := s.path()
+	if index < 0 || index >= len(path) {
+		return Message{}, false
+	}
+	return path[index], true
+}
+
+// SetMessageModel records the model that wrote the message at a history
+// index of the branch shown.
+func (s *ChatSession) SetMessageModel(index int, model string) {
+	message, ok := s.MessageAt(index)
+	if !ok {
+		return
+	}
+	for i := range s.Messages {
+		if s.Messages[i].ID == message.ID {
+			s.Messages[i].Model = model
+		}
+	}
+}
+
+// Branch returns the position of the message at a history index among the
+// alternatives sharing its parent, counting from 1, and their number. Both
+// are 0 when the message is not in the tree yet.
+func (s *ChatSession) Branch(index int) (int, int) {
+	path := s.path()
+	if index < 0 || index >= len(path) {
+		return 0, 0
+	}
+	siblings := s.children(path[index].Parent)
+	for i, id := range siblings {
+		if id == path[index].ID {
+			return i + 1, len(siblings)
+		}
+	}
+	return 0, 0
+}
+
+// SwitchBranch shows the alternative delta places away from the message at
+// a history index, continuing with its latest replies. It reports whether
+// there was such an alternative.
+func (s *ChatSession) SwitchBranch(index, delta int) bool {
+	s.SyncTree()
+	position, count := s.Branch(index)
+	if position == 0 || position+delta < 1 || position+delta > count {
+		return false
+	}
+
+	id := s.children(s.path()[index].Parent)[position+delta-1]
+	for {
+		replies := s.children(id)
+		if len(replies) == 0 {
+			break
+		}
+		id = replies[len(replies)-1]
+	}
+
+	s.Current = id
+	path := s.path()
+	s.History = make([]string, len(path))
+	for i, message := range path {
+		s.History[i] = message.Content
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hariharen9/lamacli/batch"
+)
+
+// batchOptions holds options for the batch command
+type batchOptions struct {
+	Input        string
+	Output       string
+	Model        string
+	Profile      string
+	SystemPrompt string
+	Concurrency  int
+}
+
+// handleBatchCommand runs every prompt in a JSONL file and appends the
+// results to another, skipping prompts that already have a result
+func handleBatchCommand(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	options := &batchOptions{}
+	flags.StringVar(&options.Input, "input", "", "JSONL file of prompts")
+	flags.StringVar(&options.Output, "output", "", "JSONL file results are appended to")
+	flags.StringVar(&options.Model, "model", "", "Model for prompts that don't name one")
+	flags.StringVar(&options.Profile, "profile", "", "Use a named config profile")
+	flags.StringVar(&options.SystemPrompt, "system", "", "System prompt for prompts that don't set one")
+	flags.IntVar(&options.Concurrency, "concurrency", 2, "Number of prompts run at the same time")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if options.Input == "" || options.Output == "" {
+		return fmt.Errorf("batch requires --input and --output files")
+	}
+	if options.Concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	jobs, err := batch.ReadJobs(options.Input)
+	if err != nil {
+		return err
+	}
+	completed, err := batch.CompletedIDs(options.Output)
+	if err != nil {
+		return err
+	}
+
+	var pending []batch.Job
+	for _, job := range jobs {
+		if !completed[job.ID] {
+			pending = append(pending, job)
+		}
+	}
+	if skipped := len(jobs) - len(pending); skipped > 0 {
+		fmt.Fprintf(os.Stderr, "⏭️  Skipping %d completed prompt(s)\n", skipped)
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(os.Stderr, "✅ All prompts are already completed.")
+		return nil
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	defaultModel := options.Model
+	if defaultModel == "" {
+		defaultModel = cfg.ModelFor("batch")
+	}
+	if defaultModel == "" {
+		defaultModel = getDefaultModel(llmClient)
+	}
+	defaultSystem := options.SystemPrompt
+	if defaultSystem == "" {
+		defaultSystem = cfg.DefaultPrompt()
+	}
+
+	out, err := os.OpenFile(options.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer out.Close()
+
+	// Ctrl+C stops the batch; completed results are kept for resuming
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	run := func(ctx context.Context, job batch.Job) (string, string, error) {
+		model := job.Model
+		if model == "" {
+			model = defaultModel
+		}
+		system := job.System
+		if system == "" {
+			system = defaultSystem
+		}
+		prompt, err := batchPrompt(job, cfg.ContextLimit)
+		if err != nil {
+			return model, "", err
+		}
+
+		client := llmClient
+		if len(job.Options) > 0 {
+			client = llmClient.WithOptions(job.Options)
+		}
+		response, err := client.Complete(ctx, model, system, []string{prompt})
+		return model, response, err
+	}
+
+	failed := 0
+	start := time.Now()
+	fmt.Fprintf(os.Stderr, "🚀 Running %d prompt(s) with concurrency %d\n", len(pending), options.Concurrency)
+	err = batch.Run(ctx, pending, options.Concurrency, out, run, func(done, total int, result batch.Result) {
+		status := "✅"
+		if result.Error != "" {
+			status = "❌"
+			failed++
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s %s (%s, %.1fs)\n", done, total, status, result.ID, result.Model,
+			float64(result.DurationMS)/1000)
+	})
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("interrupted. Run the same command again to resume")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "🏁 Done in %s: %d succeeded, %d failed\n",
+		time.Since(start).Round(time.Second), len(pending)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d prompt(s) failed. Run the same command again to retry them", failed)
+	}
+	return nil
+}
+
+// batchPrompt attaches the job's context files to its prompt
+func batchPrompt(job batch.Job, limit int) (string, error) {
+	if len(job.Context) == 0 {
+		return job.Prompt, nil
+	}
+
+	var b strings.Builder
+	for _, path := range job.Context {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read context file: %w", err)
+		}
+		fmt.Fprintf(&b, "\n--- File: %s ---\n%s\n", path, data)
+	}
+
+	attached := b.String()
+	if len(attached) > limit {
+		// Back up to the start of a character rather than split one
+		for limit > 0 && !utf8.RuneStart(attached[limit]) {
+			limit--
+		}
+		attached = attached[:limit] + "\n... (context truncated)"
+	}
+	return fmt.Sprintf("%s\n\nContext:\n%s", job.Prompt, attached), nil
+}
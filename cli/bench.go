@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hariharen9/lamacli/llm"
+)
+
+// benchPrompts are the standard prompts, covering short answers, code and
+// longer explanations
+var benchPrompts = []string{
+	"Explain what a hash map is in two sentences.",
+	"Write a Go function that reverses a string, with a short doc comment.",
+	"List five common git commands and what each one does.",
+	"Summarise the causes of the French Revolution in one paragraph.",
+	"Write a bash one-liner that finds the ten largest files in a directory tree.",
+}
+
+// benchOptions keep runs comparable between models
+var benchOptions = map[string]any{"temperature": 0, "num_predict": 256}
+
+// benchResult summarises the runs of one model
+type benchResult struct {
+	Model              string  `json:"model"`
+	Runs               int     `json:"runs"`
+	Errors             int     `json:"errors"`
+	LoadMS             int64   `json:"load_ms"`
+	TTFTMS             int64   `json:"ttft_ms"` // Average time to first token
+	TokensPerSec       float64 `json:"tokens_per_sec"`
+	PromptTokensPerSec float64 `json:"prompt_tokens_per_sec"`
+	PeakContext        int     `json:"peak_context"` // Most prompt plus output tokens in one run
+	Error              string  `json:"error,omitempty"`
+}
+
+// handleBenchCommand measures load time, time to first token and
+// throughput of installed models
+func handleBenchCommand(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var models stringList
+	flags.Var(&models, "models", "Models to benchmark, comma-separated or repeated (default: all installed)")
+	promptFile := flags.String("prompt-file", "", "File with one prompt per line instead of the standard prompts")
+	runs := flags.Int("runs", len(benchPrompts), "Number of prompts run per model")
+	jsonOutput := flags.Bool("json", false, "Print results as JSON")
+	profile := flags.String("profile", "", "Use a named config profile")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+
+	prompts := benchPrompts
+	if *promptFile != "" {
+		data, err := os.ReadFile(*promptFile)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		prompts = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				prompts = append(prompts, line)
+			}
+		}
+		if len(prompts) == 0 {
+			return fmt.Errorf("prompt file %s has no prompts", *promptFile)
+		}
+	}
+
+	cfg, err := loadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, value := range models {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		if names, err = llmClient.ListModels(); err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no models installed. Pull one with 'ollama pull <model>'")
+		}
+	}
+
+	ctx := context.Background()
+	client := llmClient.WithOptions(benchOptions)
+	var results []benchResult
+	for _, model := range names {
+		fmt.Fprintf(os.Stderr, "⏱️  Benchmarking %s ", model)
+		result := benchModel(ctx, client, model, prompts, *runs)
+		fmt.Fprintln(os.Stderr)
+		results = append(results, result)
+	}
+
+	if *jsonOutput {
+		return printJSON(results)
+	}
+	printBenchTable(results)
+	return nil
+}
+
+// benchModel unloads the model so its load time can be measured, warms it
+// up and then runs the prompts
+func benchModel(ctx context.Context, client *llm.OllamaClient, model string, prompts []string, runs int) benchResult {
+	result := benchResult{Model: model}
+
+	// Ignore unload errors: the model may simply not be loaded
+	client.Unload(ctx, model)
+
+	_, warm, err := client.CompleteWithStats(ctx, model, "", []string{"Hi"})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.LoadMS = warm.Load.Milliseconds()
+
+	var ttft time.Duration
+	var outputTokens, promptTokens int
+	var outputTime, promptTime time.Duration
+	for i := 0; i < runs; i++ {
+		_, stats, err := client.CompleteWithStats(ctx, model, "", []string{prompts[i%len(prompts)]})
+		if err != nil {
+			result.Errors++
+			result.Error = err.Error()
+			fmt.Fprint(os.Stderr, "x")
+			continue
+		}
+		fmt.Fprint(os.Stderr, ".")
+
+		result.Runs++
+		ttft += stats.FirstToken
+		outputTokens += stats.OutputTokens
+		outputTime += stats.OutputDuration
+		promptTokens += stats.PromptTokens
+		promptTime += stats.PromptDuration
+		result.PeakContext = max(result.PeakContext, stats.PromptTokens+stats.OutputTokens)
+	}
+
+	if result.Runs > 0 {
+		result.TTFTMS = (ttft / time.Duration(result.Runs)).Milliseconds()
+	}
+	if outputTime > 0 {
+		result.TokensPerSec = float64(outputTokens) / outputTime.Seconds()
+	}
+	if promptTime > 0 {
+		result.PromptTokensPerSec = float64(promptTokens) / promptTime.Seconds()
+	}
+	return result
+}
+
+// printBenchTable prints the results as a table
+func printBenchTable(results []benchResult) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tLOAD\tTTFT\tTOK/S\tPROMPT TOK/S\tPEAK CTX\tRUNS")
+	for _, r := range results {
+		if r.Runs == 0 {
+			fmt.Fprintf(w, "%s\t❌ %s\n", r.Model, truncate(r.Error, 60))
+			continue
+		}
+		runs := fmt.Sprintf("%d", r.Runs)
+		if r.Errors > 0 {
+			runs += fmt.Sprintf(" (%d failed)", r.Errors)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t%.1f\t%d\t%s\n",
+			r.Model,
+			time.Duration(r.LoadMS)*time.Millisecond,
+			time.Duration(r.TTFTMS)*time.Millisecond,
+			r.TokensPerSec,
+			r.PromptTokensPerSec,
+			r.PeakContext,
+			runs,
+		)
+	}
+	w.Flush()
+}
@@ -1,17 +1,19 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/hariharen9/lamacli/fileops"
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/config"
+	"github.com/hariharen9/lamacli/filecontext"
 	"github.com/hariharen9/lamacli/llm"
 )
 
@@ -45,17 +47,48 @@ const (
 	CommandSuggest Command = "suggest"
 	CommandExplain Command = "explain"
 	CommandModels  Command = "models"
+	CommandConfig  Command = "config"
+	CommandHistory Command = "history"
+	CommandCommit  Command = "commit"
+	CommandHooks   Command = "hooks"
+	CommandReview  Command = "review"
+	CommandFix     Command = "fix"
+	CommandUndo    Command = "undo"
+	CommandBatch   Command = "batch"
+	CommandEval    Command = "eval"
+	CommandBench   Command = "bench"
+	CommandServe   Command = "serve"
+	CommandMCP     Command = "mcp"
 	CommandVersion Command = "version"
 	CommandHelp    Command = "help"
 )
 
+// stringList is a flag that can be repeated to collect several values
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 // CommandOptions holds options for CLI commands
 type CommandOptions struct {
 	Model        string
 	Context      string
-	Include      string
+	Include      stringList
+	Exclude      stringList
+	MaxTokens    int
+	Strategy     string
 	SystemPrompt string
+	Profile      string
+	Session      string
+	NewSession   bool
 	StreamMode   bool
+	Apply        bool
 }
 
 // Version information
@@ -81,6 +114,30 @@ func ProcessCLICommand(args []string) error {
 		return nil
 	case CommandModels:
 		return handleModelsCommand(args[2:])
+	case CommandConfig:
+		return handleConfigCommand(args[2:])
+	case CommandHistory:
+		return handleHistoryCommand(args[2:])
+	case CommandCommit:
+		return handleCommitCommand(args[2:])
+	case CommandHooks:
+		return handleHooksCommand(args[2:])
+	case CommandReview:
+		return handleReviewCommand(args[2:])
+	case CommandFix:
+		return handleFixCommand(args[2:])
+	case CommandUndo:
+		return handleUndoCommand(args[2:])
+	case CommandBatch:
+		return handleBatchCommand(args[2:])
+	case CommandEval:
+		return handleEvalCommand(args[2:])
+	case CommandBench:
+		return handleBenchCommand(args[2:])
+	case CommandServe:
+		return handleServeCommand(args[2:])
+	case CommandMCP:
+		return handleMCPCommand(args[2:])
 	case CommandAsk, CommandSuggest, CommandExplain:
 		return handleLLMCommand(command, args[2:])
 	default:
@@ -99,6 +156,30 @@ func parseCommand(cmdStr string) Command {
 		return CommandExplain
 	case "models", "m":
 		return CommandModels
+	case "config", "c":
+		return CommandConfig
+	case "history", "hist":
+		return CommandHistory
+	case "commit":
+		return CommandCommit
+	case "hooks":
+		return CommandHooks
+	case "review", "r":
+		return CommandReview
+	case "fix", "f":
+		return CommandFix
+	case "undo":
+		return CommandUndo
+	case "batch":
+		return CommandBatch
+	case "eval":
+		return CommandEval
+	case "bench":
+		return CommandBench
+	case "serve":
+		return CommandServe
+	case "mcp":
+		return CommandMCP
 	case "version", "v":
 		return CommandVersion
 	case "help", "h":
@@ -110,12 +191,6 @@ func parseCommand(cmdStr string) Command {
 
 // handleLLMCommand processes ask, suggest, and explain commands
 func handleLLMCommand(command Command, args []string) error {
-	// Initialize Ollama client
-	llmClient, err := llm.NewOllamaClient()
-	if err != nil {
-		return fmt.Errorf("failed to initialize Ollama client: %w", err)
-	}
-
 	// Parse flags and options
 	options, prompt, err := parseCommandFlags(args)
 	if err != nil {
@@ -126,8 +201,48 @@ func handleLLMCommand(command Command, args []string) error {
 		return fmt.Errorf("prompt is required for %s command", command)
 	}
 
-	// Get default model if not specified
+	if options.Session != "" && options.NewSession {
+		return fmt.Errorf("--session and --new-session cannot be used together")
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+
+	// Load the session to continue, or start a new one
+	var historyManager *chathistory.ChatHistoryManager
+	var session *chathistory.ChatSession
+	if options.Session != "" || options.NewSession {
+		historyManager, err = chathistory.NewChatHistoryManager()
+		if err != nil {
+			return fmt.Errorf("failed to open chat history: %w", err)
+		}
+		if options.Session != "" {
+			session, err = historyManager.LoadSessionRef(options.Session)
+			if err != nil {
+				return err
+			}
+		} else {
+			session = &chathistory.ChatSession{}
+		}
+	}
+
+	// Initialize Ollama client
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get default model if not specified, preferring the session's model
+	// and then the configured one
 	model := options.Model
+	if model == "" && session != nil {
+		model = session.Model
+	}
+	if model == "" {
+		model = cfg.ModelFor(string(command))
+	}
 	if model == "" {
 		model = getDefaultModel(llmClient)
 	}
@@ -135,18 +250,66 @@ func handleLLMCommand(command Command, args []string) error {
 	// Build context if specified
 	contextContent := ""
 	if options.Context != "" {
-		contextContent, err = buildContext(options.Context, options.Include)
+		contextContent, err = buildContext(options, cfg)
 		if err != nil {
 			return fmt.Errorf("failed to build context: %w", err)
 		}
 	}
 
 	// Prepare system prompt based on command
-	systemPrompt := buildSystemPrompt(command, options.SystemPrompt)
+	systemPrompt := buildSystemPrompt(command, options.SystemPrompt, cfg)
+
+	// Combine prompt with context
+	finalPrompt := prompt
+	if contextContent != "" {
+		finalPrompt = fmt.Sprintf("%s\n\nContext:\n%s", prompt, contextContent)
+	}
+
+	// Create a history slice for the chat, continuing the session if any
+	history := []string{finalPrompt}
+	if session != nil {
+		history = append(append([]string{}, session.History...), finalPrompt)
+	}
 
 	// Check if streaming mode is enabled (default is false - use Markdown rendering)
 	streamMode := options.StreamMode
+	fullResponse := streamResponse(llmClient, model, systemPrompt, history, streamMode)
+	if !streamMode {
+		// Print the full response with Markdown formatting
+		printFormattedResponse(command, fullResponse, model, cfg.RenderWidth)
+	}
 
+	// Save the new turn back to the session, unless generation failed
+	if session != nil && !strings.HasPrefix(fullResponse, "Error: ") {
+		session.Model = model
+		session.History = append(session.History, finalPrompt, fullResponse)
+		err := historyManager.SaveSession(session)
+		if errors.Is(err, chathistory.ErrConflict) {
+			// Another instance added turns meanwhile; keep both
+			previous := session.ID
+			err = historyManager.SaveSessionCopy(session)
+			if err == nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Session %s was changed elsewhere, so this turn was saved as a copy\n", previous)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "💾 Saved to session %s\n", session.ID)
+	}
+
+	if options.Apply && !strings.HasPrefix(fullResponse, "Error: ") {
+		if _, err := applyResponsePatch(fullResponse, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamResponse sends the history to the model and returns the full
+// response. In stream mode chunks are printed as they arrive; otherwise a
+// "Thinking..." spinner is shown until the response is complete.
+func streamResponse(llmClient *llm.OllamaClient, model, systemPrompt string, history []string, streamMode bool) string {
 	// Create a spinner model
 	s := spinner.New()
 	s.Spinner = spinner.Dot
@@ -173,15 +336,6 @@ func handleLLMCommand(command Command, args []string) error {
 	// Create a channel for streaming responses
 	responseChan := make(chan string)
 
-	// Combine prompt with context
-	finalPrompt := prompt
-	if contextContent != "" {
-		finalPrompt = fmt.Sprintf("%s\n\nContext:\n%s", prompt, contextContent)
-	}
-
-	// Create a history slice for the chat
-	history := []string{finalPrompt}
-
 	// Start streaming response in a goroutine
 	go func() {
 		llmClient.GenerateResponseStream(model, systemPrompt, history, responseChan)
@@ -224,11 +378,8 @@ func handleLLMCommand(command Command, args []string) error {
 
 		// Print a newline after collection is complete
 		fmt.Println()
-
-		// Print the full response with Markdown formatting
-		printFormattedResponse(command, fullResponse, model)
 	}
-	return nil
+	return fullResponse
 }
 
 // parseCommandFlags parses command line flags and returns options and prompt
@@ -239,9 +390,16 @@ func parseCommandFlags(args []string) (*CommandOptions, string, error) {
 	options := &CommandOptions{}
 	flags.StringVar(&options.Model, "model", "", "Override default model")
 	flags.StringVar(&options.Context, "context", "", "Include directory context")
-	flags.StringVar(&options.Include, "include", "", "File pattern to include in context")
+	flags.Var(&options.Include, "include", "Glob of files to include in context (repeatable)")
+	flags.Var(&options.Exclude, "exclude", "Glob of files to exclude from context (repeatable)")
+	flags.IntVar(&options.MaxTokens, "max-tokens", 0, "Token budget for context")
+	flags.StringVar(&options.Strategy, "strategy", "", "Context priority: path, smallest or recent")
 	flags.StringVar(&options.SystemPrompt, "system", "", "Custom system prompt")
+	flags.StringVar(&options.Profile, "profile", "", "Use a named config profile")
+	flags.StringVar(&options.Session, "session", "", "Continue a saved session (ID or 'last')")
+	flags.BoolVar(&options.NewSession, "new-session", false, "Save the conversation as a new session")
 	flags.BoolVar(&options.StreamMode, "stream", false, "Stream output without Markdown rendering")
+	flags.BoolVar(&options.Apply, "apply", false, "Preview and apply file changes from the response")
 
 	err := flags.Parse(args)
 	if err != nil {
@@ -255,6 +413,59 @@ func parseCommandFlags(args []string) (*CommandOptions, string, error) {
 	return options, prompt, nil
 }
 
+// parseFlags parses flags that may appear before, between or after
+// positional arguments, returning the positional arguments in order
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	flags.Usage = func() {} // Suppress default usage
+
+	// Everything after a "--" terminator is positional
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadConfig loads the config file, switching to the given profile if one
+// is specified
+func loadConfig(profile string) (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		if err := cfg.UseProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// newLLMClient creates an Ollama client for the config's active profile
+func newLLMClient(cfg *config.Config) (*llm.OllamaClient, error) {
+	llmClient, err := llm.NewOllamaClientWithHost(cfg.OllamaHost())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Ollama client: %w", err)
+	}
+	llmClient.SetOptions(cfg.GenerationOptions())
+	return llmClient, nil
+}
+
 // getDefaultModel gets the first available model as default
 func getDefaultModel(llmClient *llm.OllamaClient) string {
 	models, err := llmClient.ListModels()
@@ -264,57 +475,37 @@ func getDefaultModel(llmClient *llm.OllamaClient) string {
 	return models[0]
 }
 
-// buildContext builds context from directory and file patterns
-func buildContext(contextPath, includePattern string) (string, error) {
-	var contextBuilder strings.Builder
-
-	// Handle relative paths
-	if contextPath == "." {
-		contextPath, _ = os.Getwd()
+// buildContext builds context from a directory, honouring ignore files
+// and the include/exclude patterns, and prints a summary to stderr
+func buildContext(options *CommandOptions, cfg *config.Config) (string, error) {
+	strategy, err := filecontext.ParseStrategy(options.Strategy)
+	if err != nil {
+		return "", err
 	}
 
-	// Walk through directory
-	err := filepath.Walk(contextPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories and hidden files
-		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
-			return nil
-		}
-
-		// Filter by include pattern if specified
-		if includePattern != "" {
-			matched, _ := filepath.Match(includePattern, info.Name())
-			if !matched {
-				return nil
-			}
-		}
-
-		// Read file content
-		content, err := fileops.ReadFile(path)
-		if err != nil {
-			return nil // Skip files that can't be read
-		}
-
-		// Add to context
-		relPath, _ := filepath.Rel(contextPath, path)
-		contextBuilder.WriteString(fmt.Sprintf("\n--- File: %s ---\n%s\n", relPath, string(content)))
-
-		// Limit context size to prevent overwhelming the LLM
-		if contextBuilder.Len() > 10000 {
-			return filepath.SkipDir
-		}
+	// Default the budget to the configured context byte limit
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = cfg.ContextLimit / 4
+	}
 
-		return nil
+	result, err := filecontext.Build(filecontext.Options{
+		Root:      options.Context,
+		Include:   options.Include,
+		Exclude:   options.Exclude,
+		MaxTokens: maxTokens,
+		Strategy:  strategy,
 	})
+	if err != nil {
+		return "", err
+	}
 
-	return contextBuilder.String(), err
+	result.WriteSummary(os.Stderr)
+	return result.Content, nil
 }
 
 // buildSystemPrompt creates appropriate system prompt based on command
-func buildSystemPrompt(command Command, customPrompt string) string {
+func buildSystemPrompt(command Command, customPrompt string, cfg *config.Config) string {
 	if customPrompt != "" {
 		return customPrompt
 	}
@@ -325,6 +516,9 @@ func buildSystemPrompt(command Command, customPrompt string) string {
 	case CommandExplain:
 		return "You are a helpful technical assistant. When asked to explain a command, provide clear, detailed explanations of what the command does, its options, and usage examples."
 	case CommandAsk:
+		if prompt := cfg.ConfiguredPrompt(); prompt != "" {
+			return prompt
+		}
 		return "You are a helpful assistant. Provide clear, accurate, and helpful responses to questions."
 	default:
 		return "You are a helpful assistant."
@@ -332,11 +526,11 @@ func buildSystemPrompt(command Command, customPrompt string) string {
 }
 
 // printFormattedResponse prints the response with appropriate formatting and markdown rendering
-func printFormattedResponse(command Command, response, model string) {
+func printFormattedResponse(command Command, response, model string, width int) {
 	// Create a glamour renderer for markdown
 	renderer, err := glamour.NewTermRenderer(
 		glamour.WithAutoStyle(),
-		glamour.WithWordWrap(100),
+		glamour.WithWordWrap(width),
 	)
 
 	// Fallback to plain text if renderer creation fails
@@ -348,6 +542,10 @@ func printFormattedResponse(command Command, response, model string) {
 			fmt.Printf("\n📖 Command Explanation (using %s):\n%s\n\n", model, response)
 		case CommandAsk:
 			fmt.Printf("\n💭 Response (using %s):\n%s\n\n", model, response)
+		case CommandReview:
+			fmt.Printf("\n🔍 Review (using %s):\n%s\n\n", model, response)
+		case CommandFix:
+			fmt.Printf("\n🩺 Diagnosis (using %s):\n%s\n\n", model, response)
 		default:
 			fmt.Printf("\n%s\n\n", response)
 		}
@@ -368,6 +566,10 @@ func printFormattedResponse(command Command, response, model string) {
 		fmt.Printf("\n📖 Command Explanation (using %s):\n%s\n", model, renderedResponse)
 	case CommandAsk:
 		fmt.Printf("\n💭 Response (using %s):\n%s\n", model, renderedResponse)
+	case CommandReview:
+		fmt.Printf("\n🔍 Review (using %s):\n%s\n", model, renderedResponse)
+	case CommandFix:
+		fmt.Printf("\n🩺 Diagnosis (using %s):\n%s\n", model, renderedResponse)
 	default:
 		fmt.Printf("\n%s\n", renderedResponse)
 	}
@@ -375,10 +577,15 @@ func printFormattedResponse(command Command, response, model string) {
 
 // handleModelsCommand handles showing available models
 func handleModelsCommand(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
 	// For now, just print available models
-	llmClient, err := llm.NewOllamaClient()
+	llmClient, err := newLLMClient(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to initialize Ollama client: %w", err)
+		return err
 	}
 
 	models, err := llmClient.ListModels()
@@ -386,9 +593,14 @@ func handleModelsCommand(args []string) error {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
 
+	defaultModel := cfg.ModelFor("")
+	if defaultModel == "" && len(models) > 0 {
+		defaultModel = models[0]
+	}
+
 	fmt.Println("\n🤖 Available Models:")
-	for i, model := range models {
-		if i == 0 {
+	for _, model := range models {
+		if model == defaultModel {
 			fmt.Printf("  • %s (default)\n", model)
 		} else {
 			fmt.Printf("  • %s\n", model)
@@ -406,7 +618,7 @@ func printVersion() {
 
 // printHelp prints comprehensive help information
 func printHelp() {
-	fmt.Println(`
+	fmt.Print(`
 🦙 LamaCLI - Your Terminal AI Assistant
 
 USAGE:
@@ -418,15 +630,74 @@ COMMANDS:
   suggest, s  Get command suggestions  
   explain, e  Explain a command
   models, m   Show available models
+  config, c   Show or change configuration (get|set|list|edit|path)
+  history     Manage saved chat sessions:
+                list [--json] [--limit N]     List sessions, pinned first
+                     [--offset N]             Skip N sessions, for the next page
+                     [--tag T] [--folder F]   Only sessions with a tag or in a folder
+                show <id> [--json]            Show a conversation
+                export <id> [-o file]         Export a session (--format markdown|html|json|text)
+                import <file>... [--dry-run]  Import OpenAI, Open WebUI or Markdown chats
+                delete <id>...                Delete sessions
+                search <query> [--limit N]    Search all messages, best matches first
+                rename <id> <title>           Rename a session
+                summarize <id>... [--missing] Regenerate titles and summaries (models.titles)
+                tag <id> <tag>... [--remove]  Add or remove tags (--clear removes all)
+                pin|unpin <id>...             Pin sessions to the top of the list
+                mv <id>... <folder>           Move sessions into a folder (/ for none)
+                doctor [--fix] [--json]       Check and repair session files
+                migrate <json|bolt>           Copy sessions to another storage backend
+  commit      Draft a commit message for the staged changes and commit
+                --style conventional|plain    Message style (default: conventional)
+                --max-length N                Subject line limit (default: 72)
+                --dry-run                     Print the message without committing
+  hooks       Manage the prepare-commit-msg git hook:
+                install [--force] [--style S] Pre-fill commit messages with a draft
+                uninstall                     Remove the hook
+  review, r   Review changes and report findings by file and line
+                [files...]                    Limit the review to these files
+                --staged                      Review the staged changes
+                --base <branch>               Review the branch against a base
+                --format text|json|sarif      Output format (default: text)
+  fix, f      Run a command and propose a patch if it fails
+                -- <command> [args...]        Command to run and re-run after the fix
+                --yes                         Apply the patch without asking
+  undo        Restore the files changed by the last applied patch
+                [id]                          Undo a specific patch
+                --list                        List patches that can be undone
+  batch       Run every prompt in a JSONL file, resuming where it left off
+                --input <file>                Prompts: {"id", "prompt", "model", "system", "context", "options"}
+                --output <file>               Results are appended here as JSONL
+                --concurrency N               Prompts run at the same time (default: 2)
+  eval        Run a YAML suite of prompt test cases and print a pass/fail matrix
+                <suite.yaml>                  Cases with contains, regex, json_schema,
+                                              max_latency and judge assertions
+                --model <name>                Models to evaluate, repeatable
+  bench       Measure load time, time to first token and tokens/sec of models
+                --models a,b                  Models to benchmark (default: all installed)
+                --prompt-file <file>          One prompt per line instead of the standard set
+                --runs N                      Prompts run per model (default: 5)
+                --json                        Print results as JSON
+  serve       Serve ask, suggest, explain, sessions and templates over HTTP
+                --addr <host:port>            Address to listen on (default: 127.0.0.1:11435)
+                --token <token>               Bearer token (default: $LAMACLI_TOKEN or random)
+  mcp list    Show configured MCP servers with their tools and resources [--json]
   version, v  Show version information
   help, h     Show this help message
 
 OPTIONS:
   --model     Override default model (e.g., --model=llama3.2:1b)
   --context   Include directory context (e.g., --context=.)
-  --include   File pattern for context (e.g., --include=*.md)
+  --include   Glob of files for context, repeatable (e.g., --include='**/*.go')
+  --exclude   Glob of files to leave out of context, repeatable
+  --max-tokens  Token budget for context (default: context_limit / 4)
+  --strategy  Context priority when over budget: path, smallest or recent
   --system    Custom system prompt
+  --profile   Use a named config profile (or set LAMACLI_PROFILE)
+  --session   Continue a saved session by ID, or 'last' for the most recent
+  --new-session  Start a new saved session with this prompt
   --stream    Stream output without Markdown rendering
+  --apply     Preview and apply diffs or file blocks from the response
 
 EXAMPLES:
   lamacli ask "How do I list files in Linux?"
@@ -439,9 +710,41 @@ EXAMPLES:
   lamacli e --model=qwen2.5-coder "docker compose up -d"
   
   lamacli ask --context=. --include="*.md" "Summarize this project"
+  lamacli ask --new-session "How do nginx rewrites work?"
+  lamacli ask --session=last "And how do I test them?"
   lamacli models
+  lamacli config set models.ask qwen2.5-coder:1.5b
+  lamacli config list
+  lamacli history list
+  lamacli history search "nginx rewrite"
+  lamacli history export last --format html -o chat.html
+  lamacli history import conversations.json
+  lamacli history summarize --missing
+  lamacli history tag last rust async
+  lamacli history mv last work/api
+  lamacli history doctor --fix
+  lamacli history migrate bolt
+  lamacli commit --style=plain --dry-run
+  lamacli hooks install
+  lamacli review --base=main --format=sarif > review.sarif
+  lamacli fix -- go test ./...
+  lamacli ask --context=. --apply "Add a --verbose flag to main.go"
+  lamacli undo
+  lamacli batch --input prompts.jsonl --output results.jsonl --concurrency=4
+  lamacli eval prompts/suite.yaml --model=llama3.2:3b --model=qwen2.5-coder:1.5b
+  lamacli bench --models=llama3.2:1b,llama3.2:3b --json
+  lamacli serve --addr=127.0.0.1:9000
+  lamacli mcp list
   lamacli version
 
+CONFIG:
+  Settings are read from ~/.lamacli/config.toml ($XDG_CONFIG_HOME/lamacli/config.toml
+  when XDG_CONFIG_HOME is set, or the path in LAMACLI_CONFIG).
+  Profiles bundle host, model, system prompt and options, e.g.
+    lamacli config set profiles.work.host gpu-box:11434
+    lamacli --profile work ask "..."
+
 NOTE: Run 'lamacli' without arguments to start the interactive mode.
+
 `)
 }
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/huh"
+	"github.com/hariharen9/lamacli/git"
+)
+
+// Commit message styles
+const (
+	commitStyleConventional = "conventional"
+	commitStylePlain        = "plain"
+)
+
+// commitOptions holds options for the commit command
+type commitOptions struct {
+	Model     string
+	Profile   string
+	Style     string
+	MaxLength int
+	DryRun    bool
+	Hook      string // Message file passed by the prepare-commit-msg hook
+}
+
+// handleCommitCommand drafts a commit message for the staged changes and
+// commits with it once the user accepts
+func handleCommitCommand(args []string) error {
+	flags := flag.NewFlagSet("commit", flag.ContinueOnError)
+	options := &commitOptions{}
+	flags.StringVar(&options.Model, "model", "", "Override default model")
+	flags.StringVar(&options.Profile, "profile", "", "Use a named config profile")
+	flags.StringVar(&options.Style, "style", commitStyleConventional, "Message style: conventional or plain")
+	flags.IntVar(&options.MaxLength, "max-length", 72, "Maximum length of the subject line")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "Print the drafted message without committing")
+	flags.StringVar(&options.Hook, "hook", "", "Draft into a message file (used by the installed git hook)")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if options.Style != commitStyleConventional && options.Style != commitStylePlain {
+		return fmt.Errorf("invalid style '%s'. Please use 'conventional' or 'plain'", options.Style)
+	}
+	if options.MaxLength <= 0 {
+		return fmt.Errorf("--max-length must be positive")
+	}
+	if options.Hook != "" {
+		return runCommitHook(options, options.Hook)
+	}
+
+	diff, err := git.StagedDiff()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return fmt.Errorf("no staged changes. Stage files with 'git add' first")
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	model := options.Model
+	if model == "" {
+		model = cfg.ModelFor("commit")
+	}
+	if model == "" {
+		model = getDefaultModel(llmClient)
+	}
+
+	prompt := commitUserPrompt(diff, cfg.ContextLimit)
+	systemPrompt := commitSystemPrompt(options.Style, options.MaxLength)
+
+	draft := func() (string, error) {
+		response := streamResponse(llmClient, model, systemPrompt, []string{prompt}, false)
+		if strings.HasPrefix(response, "Error: ") {
+			return "", fmt.Errorf("%s", strings.TrimPrefix(response, "Error: "))
+		}
+		return cleanCommitMessage(response, options.MaxLength), nil
+	}
+
+	message, err := draft()
+	if err != nil {
+		return err
+	}
+
+	for {
+		printCommitMessage(message, model, cfg.RenderWidth)
+		if options.DryRun {
+			return nil
+		}
+
+		var action string
+		err := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title("Use this commit message?").
+					Options(
+						huh.NewOption("Accept and commit", "accept"),
+						huh.NewOption("Edit", "edit"),
+						huh.NewOption("Regenerate", "regenerate"),
+						huh.NewOption("Cancel", "cancel"),
+					).
+					Value(&action),
+			),
+		).WithTheme(huh.ThemeBase16()).Run()
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case "accept":
+			return commitWithMessage(message)
+		case "edit":
+			err := huh.NewForm(
+				huh.NewGroup(
+					huh.NewText().
+						Title("Commit message").
+						Lines(12).
+						Value(&message),
+				),
+			).WithTheme(huh.ThemeBase16()).Run()
+			if err != nil {
+				return err
+			}
+			message = strings.TrimSpace(message)
+		case "regenerate":
+			if message, err = draft(); err != nil {
+				return err
+			}
+		default:
+			fmt.Println("Commit cancelled.")
+			return nil
+		}
+	}
+}
+
+// commitSystemPrompt returns the instructions for drafting a message in
+// the given style
+func commitSystemPrompt(style string, maxLength int) string {
+	var b strings.Builder
+	b.WriteString("You write git commit messages for staged changes. ")
+	if style == commitStyleConventional {
+		b.WriteString("Use the Conventional Commits format: a subject line of the form " +
+			"'type(scope): summary' where type is one of feat, fix, docs, style, refactor, perf, test, build, ci or chore, " +
+			"and the scope is optional. ")
+	} else {
+		b.WriteString("Start with a short summary line in the imperative mood, e.g. 'Add retry to uploads'. ")
+	}
+	fmt.Fprintf(&b, "Keep the subject line under %d characters. ", maxLength)
+	b.WriteString("If the change needs explanation, add a blank line and a brief body wrapped at 72 characters " +
+		"that explains what changed and why. " +
+		"Reply with the commit message only: no code fences, quotes or commentary.")
+	return b.String()
+}
+
+// commitUserPrompt wraps the staged diff, truncated to limit bytes
+func commitUserPrompt(diff string, limit int) string {
+	if len(diff) > limit {
+		// Back up to the start of a character rather than split one
+		for limit > 0 && !utf8.RuneStart(diff[limit]) {
+			limit--
+		}
+		diff = diff[:limit] + "\n... (diff truncated)"
+	}
+	return "Write a commit message for this staged diff:\n\n" + diff
+}
+
+// cleanCommitMessage strips code fences and quotes the model may add and
+// shortens an overlong subject line at a word boundary
+func cleanCommitMessage(message string, maxLength int) string {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "```") {
+		message = strings.TrimPrefix(message, "```")
+		// Drop a language tag on the opening fence
+		if i := strings.Index(message, "\n"); i >= 0 {
+			message = message[i+1:]
+		}
+		message = strings.TrimSuffix(strings.TrimSpace(message), "```")
+	}
+	message = strings.Trim(strings.TrimSpace(message), "\"'`")
+
+	subject, body, hasBody := strings.Cut(message, "\n")
+	subject = strings.TrimSpace(subject)
+	if utf8.RuneCountInString(subject) > maxLength {
+		// maxLength counts characters; find the byte offset of the first
+		// one past it
+		limit := 0
+		for i := 0; i < maxLength; i++ {
+			_, size := utf8.DecodeRuneInString(subject[limit:])
+			limit += size
+		}
+		cut := strings.LastIndex(subject[:limit], " ")
+		if cut <= 0 {
+			cut = limit
+		}
+		subject = strings.TrimSpace(subject[:cut])
+	}
+	if !hasBody || strings.TrimSpace(body) == "" {
+		return subject
+	}
+	return subject + "\n\n" + strings.TrimSpace(body)
+}
+
+// printCommitMessage renders the drafted message with glamour
+func printCommitMessage(message, model string, width int) {
+	markdown := fmt.Sprintf("```\n%s\n```", message)
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(width),
+	)
+	if err == nil {
+		if rendered, err := renderer.Render(markdown); err == nil {
+			markdown = rendered
+		}
+	}
+	fmt.Printf("\n📝 Commit Message (using %s):\n%s\n", model, markdown)
+}
+
+// commitWithMessage writes the message to a temporary file and commits
+func commitWithMessage(message string) error {
+	f, err := os.CreateTemp("", "lamacli-commit-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create message file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(message + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write message file: %w", err)
+	}
+	f.Close()
+
+	return git.CommitWithMessageFile(f.Name())
+}
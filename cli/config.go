@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/hariharen9/lamacli/config"
+)
+
+// handleConfigCommand handles the config subcommands
+func handleConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("config requires a subcommand: get, set, list, edit or path")
+	}
+
+	cfg, err := config.Load()
+	if err != nil && args[0] != "edit" && args[0] != "path" {
+		return err
+	}
+
+	switch args[0] {
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: lamacli config get <key>")
+		}
+		value, err := cfg.Get(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: lamacli config set <key> <value>")
+		}
+		if err := cfg.Set(args[1], strings.Join(args[2:], " ")); err != nil {
+			return err
+		}
+		return cfg.Save()
+
+	case "list":
+		path, _ := config.Path()
+		fmt.Printf("# %s\n", path)
+		if active := cfg.ActiveProfile(); active != "" {
+			fmt.Printf("# active profile: %s\n", active)
+		}
+		for _, key := range cfg.Keys() {
+			value, _ := cfg.Get(key)
+			fmt.Printf("%s = %q\n", key, value)
+		}
+		return nil
+
+	case "edit":
+		return editConfig()
+
+	case "path":
+		path, err := config.Path()
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown config subcommand '%s'. Use get, set, list, edit or path", args[0])
+	}
+}
+
+// editConfig opens the config file in the user's editor, creating it with
+// the defaults first if it does not exist yet
+func editConfig() error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := config.Default().Save(); err != nil {
+			return err
+		}
+	}
+
+	// The editor may carry arguments, e.g. "code --wait"
+	editor := strings.Fields(editorCommand())
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run editor: %w", err)
+	}
+
+	// Validate the edited file so mistakes surface immediately
+	if _, err := config.Load(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// editorCommand returns the user's preferred editor
+func editorCommand() string {
+	if editor := os.Getenv("VISUAL"); editor != "" {
+		return editor
+	}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
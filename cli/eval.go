@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hariharen9/lamacli/eval"
+	"github.com/hariharen9/lamacli/llm"
+)
+
+// evalResult is the outcome of one case against one model
+type evalResult struct {
+	Case     string
+	Model    string
+	Latency  time.Duration
+	Outcomes []eval.Outcome
+}
+
+func (r evalResult) passed() int {
+	n := 0
+	for _, outcome := range r.Outcomes {
+		if outcome.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+// handleEvalCommand runs a suite of prompt test cases and prints a
+// pass/fail matrix, failing if any assertion fails
+func handleEvalCommand(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	var models stringList
+	flags.Var(&models, "model", "Model to evaluate, repeatable; replaces the models in the suite")
+	profile := flags.String("profile", "", "Use a named config profile")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("eval requires a suite file, e.g. 'lamacli eval suite.yaml'")
+	}
+
+	suite, err := eval.Load(positional[0])
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	defaultModel := cfg.ModelFor("eval")
+	if defaultModel == "" {
+		defaultModel = getDefaultModel(llmClient)
+	}
+
+	ctx := context.Background()
+	var results []evalResult
+	var columns []string
+	seenModel := make(map[string]bool)
+
+	for _, c := range suite.Cases {
+		caseModels := suite.ModelsFor(c, models)
+		if len(caseModels) == 0 {
+			caseModels = []string{defaultModel}
+		}
+		client := llmClient.WithOptions(suite.OptionsFor(c))
+
+		for _, model := range caseModels {
+			if !seenModel[model] {
+				seenModel[model] = true
+				columns = append(columns, model)
+			}
+
+			fmt.Fprintf(os.Stderr, "🧪 %s × %s ... ", c.Name, model)
+			result := runEvalCase(ctx, client, llmClient, suite, c, model)
+			status := "✅"
+			if result.passed() < len(result.Outcomes) {
+				status = "❌"
+			}
+			fmt.Fprintf(os.Stderr, "%s (%.1fs)\n", status, result.Latency.Seconds())
+			results = append(results, result)
+		}
+	}
+
+	return printEvalReport(suite, columns, results)
+}
+
+// runEvalCase runs one case against one model and checks its assertions
+func runEvalCase(ctx context.Context, client, judgeClient *llm.OllamaClient, suite *eval.Suite, c eval.Case, model string) evalResult {
+	result := evalResult{Case: c.Name, Model: model}
+
+	start := time.Now()
+	output, err := client.Complete(ctx, model, suite.SystemFor(c), []string{c.Prompt})
+	result.Latency = time.Since(start)
+	if err != nil {
+		// Without output no assertion can run; report the failure once
+		result.Outcomes = []eval.Outcome{{Assertion: "generate", Detail: err.Error()}}
+		return result
+	}
+
+	judgeModel := suite.Judge
+	if judgeModel == "" {
+		judgeModel = model
+	}
+	// The judge grades with deterministic settings regardless of the case
+	judge := judgeClient.WithOptions(map[string]any{"temperature": 0})
+	judgeFn := func(ctx context.Context, rubric, prompt, output string) (bool, string, error) {
+		response, err := judge.CompleteJSON(ctx, judgeModel, eval.JudgeSystemPrompt,
+			[]string{eval.JudgePrompt(rubric, prompt, output)}, eval.JudgeSchema)
+		if err != nil {
+			return false, "", err
+		}
+		return eval.ParseVerdict(response)
+	}
+
+	for _, assertion := range c.Assert {
+		result.Outcomes = append(result.Outcomes, eval.Check(ctx, assertion, c.Prompt, output, result.Latency, judgeFn))
+	}
+	return result
+}
+
+// printEvalReport prints the pass/fail matrix and the failed assertions
+func printEvalReport(suite *eval.Suite, columns []string, results []evalResult) error {
+	byCell := make(map[[2]string]evalResult)
+	for _, result := range results {
+		byCell[[2]string{result.Case, result.Model}] = result
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CASE\t%s\n", strings.Join(columns, "\t"))
+	for _, c := range suite.Cases {
+		cells := make([]string, len(columns))
+		for i, model := range columns {
+			result, ok := byCell[[2]string{c.Name, model}]
+			switch {
+			case !ok:
+				cells[i] = "-"
+			case result.passed() == len(result.Outcomes):
+				cells[i] = fmt.Sprintf("✅ %d/%d", result.passed(), len(result.Outcomes))
+			default:
+				cells[i] = fmt.Sprintf("❌ %d/%d", result.passed(), len(result.Outcomes))
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\n", c.Name, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	failedRuns, failedChecks, totalChecks := 0, 0, 0
+	for _, result := range results {
+		totalChecks += len(result.Outcomes)
+		if result.passed() == len(result.Outcomes) {
+			continue
+		}
+		if failedRuns == 0 {
+			fmt.Println("\nFailures:")
+		}
+		failedRuns++
+		for _, outcome := range result.Outcomes {
+			if outcome.Pass {
+				continue
+			}
+			failedChecks++
+			fmt.Printf("  ❌ %s × %s: %s: %s\n", result.Case, result.Model, outcome.Assertion, outcome.Detail)
+		}
+	}
+
+	fmt.Printf("\n%d of %d run(s) passed, %d of %d check(s) failed\n",
+		len(results)-failedRuns, len(results), failedChecks, totalChecks)
+	if failedRuns > 0 {
+		return fmt.Errorf("%d run(s) failed", failedRuns)
+	}
+	return nil
+}
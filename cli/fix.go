@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hariharen9/lamacli/filecontext"
+)
+
+const (
+	fixMaxRefs      = 8  // Source references attached to the prompt
+	fixSnippetLines = 10 // Lines shown on each side of a referenced line
+)
+
+// fixSystemPrompt asks for a diagnosis followed by an applicable patch
+const fixSystemPrompt = "You are an expert at debugging failing commands. You are given a command, its exit code, " +
+	"its output and the source code it refers to. First explain the root cause briefly. Then give the fix as a " +
+	"unified diff in a single ```diff code block, with '--- a/<path>' and '+++ b/<path>' headers using the paths " +
+	"exactly as shown, '@@' hunk headers and a few unchanged context lines so the patch applies cleanly. " +
+	"Source is shown with line numbers for reference; leave them out of the diff. " +
+	"Change only what is needed to fix the failure. If the failure cannot be fixed in the source code, " +
+	"explain what to do instead and do not include a diff."
+
+// sourceRefPattern matches file:line references such as "main.go:12",
+// "./pkg/util.go:40:2" or "src/app.py:7"
+var sourceRefPattern = regexp.MustCompile(`(?:^|[\s("'\[])((?:[A-Za-z]:)?[\w./\\-]*\w\.\w+):(\d+)`)
+
+// fixOptions holds options for the fix command
+type fixOptions struct {
+	Model   string
+	Profile string
+	Yes     bool
+}
+
+// sourceRef is a file:line reference found in command output
+type sourceRef struct {
+	Path string
+	Line int
+}
+
+// handleFixCommand runs a command and, if it fails, asks the model for a
+// diagnosis and a patch, applies it and re-runs the command
+func handleFixCommand(args []string) error {
+	flags := flag.NewFlagSet("fix", flag.ContinueOnError)
+	flags.Usage = func() {} // Suppress default usage
+	options := &fixOptions{}
+	flags.StringVar(&options.Model, "model", "", "Override default model")
+	flags.StringVar(&options.Profile, "profile", "", "Use a named config profile")
+	flags.BoolVar(&options.Yes, "yes", false, "Apply the proposed patch without asking")
+	// Flags stop at the command so its own flags are left alone
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	command := flags.Args()
+	if len(command) == 0 {
+		return fmt.Errorf("fix requires a command to run, e.g. 'lamacli fix -- go test ./...'")
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+
+	output, exitCode, err := runFixCommand(command)
+	if err != nil {
+		return err
+	}
+	if exitCode == 0 {
+		fmt.Println("\n✅ Command succeeded, nothing to fix.")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "\n❌ Command failed with exit code %d\n", exitCode)
+
+	refs := findSourceRefs(output)
+	for _, ref := range refs {
+		fmt.Fprintf(os.Stderr, "   + %s:%d\n", ref.Path, ref.Line)
+	}
+
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+	model := options.Model
+	if model == "" {
+		model = cfg.ModelFor("fix")
+	}
+	if model == "" {
+		model = getDefaultModel(llmClient)
+	}
+
+	prompt := fixPrompt(command, exitCode, output, refs, cfg.ContextLimit)
+	response := streamResponse(llmClient, model, fixSystemPrompt, []string{prompt}, false)
+	if strings.HasPrefix(response, "Error: ") {
+		return fmt.Errorf("%s", strings.TrimPrefix(response, "Error: "))
+	}
+	printFormattedResponse(CommandFix, response, model, cfg.RenderWidth)
+
+	applied, err := applyResponsePatch(response, options.Yes)
+	if err != nil || !applied {
+		return err
+	}
+	fmt.Printf("🔁 Re-running %s\n\n", strings.Join(command, " "))
+
+	if _, exitCode, err = runFixCommand(command); err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command still fails with exit code %d. Revert the patch with 'lamacli undo'", exitCode)
+	}
+	fmt.Println("\n✅ Command now succeeds.")
+	return nil
+}
+
+// runFixCommand runs the command with its output shown on the terminal
+// and returns the combined output and exit code. An error is returned
+// only if the command could not be started.
+func runFixCommand(command []string) (string, int, error) {
+	var output bytes.Buffer
+	writer := io.MultiWriter(os.Stdout, &output)
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to run %s: %w", command[0], err)
+	}
+	return output.String(), 0, nil
+}
+
+// findSourceRefs returns the file:line references in the output that
+// point at existing files, in order of appearance
+func findSourceRefs(output string) []sourceRef {
+	var refs []sourceRef
+	seen := make(map[sourceRef]bool)
+	seenMatch := make(map[string]bool)
+	resolved := make(map[string]string) // Resolved path of each name found
+	for _, match := range sourceRefPattern.FindAllStringSubmatch(output, -1) {
+		// Output often repeats a reference; look each one up once
+		if seenMatch[match[1]+":"+match[2]] {
+			continue
+		}
+		seenMatch[match[1]+":"+match[2]] = true
+
+		line, err := strconv.Atoi(match[2])
+		if err != nil || line <= 0 {
+			continue
+		}
+		path, ok := resolved[match[1]]
+		if !ok {
+			path = resolveSourcePath(match[1])
+			resolved[match[1]] = path
+		}
+		if path == "" {
+			continue
+		}
+
+		ref := sourceRef{Path: path, Line: line}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+		if len(refs) == fixMaxRefs {
+			break
+		}
+	}
+	return refs
+}
+
+// fixSkipDirs are directories of dependencies, which failures rarely need
+// fixing in and which can be large to search
+var fixSkipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// resolveSourcePath returns the path of a referenced file relative to the
+// current directory. Tools such as go test print bare file names, so a
+// name that doesn't exist here is looked up below it when it is unique,
+// leaving out ignored and dependency directories.
+func resolveSourcePath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		if filepath.IsAbs(path) {
+			if rel, err := filepath.Rel(".", path); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+		}
+		return filepath.Clean(path)
+	}
+	if strings.ContainsAny(path, `/\`) {
+		return ""
+	}
+
+	var found []string
+	filecontext.Walk(".", func(rel string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if fixSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == path {
+			found = append(found, filepath.FromSlash(rel))
+			if len(found) > 1 {
+				return filepath.SkipAll // Ambiguous
+			}
+		}
+		return nil
+	})
+	if len(found) == 1 {
+		return found[0]
+	}
+	return ""
+}
+
+// fixPrompt describes the failure and attaches the referenced source
+func fixPrompt(command []string, exitCode int, output string, refs []sourceRef, limit int) string {
+	// The end of the output usually holds the error
+	if len(output) > limit {
+		// Move forward to the start of a character rather than split one
+		start := len(output) - limit
+		for start < len(output) && !utf8.RuneStart(output[start]) {
+			start++
+		}
+		output = "... (output truncated)\n" + output[start:]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Command: %s\nExit code: %d\n\nOutput:\n```\n%s\n```\n", strings.Join(command, " "), exitCode, strings.TrimRight(output, "\n"))
+	for _, ref := range refs {
+		snippet, err := sourceSnippet(ref)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\nSource around %s:%d:\n```\n%s```\n", filepath.ToSlash(ref.Path), ref.Line, snippet)
+	}
+	return b.String()
+}
+
+// sourceSnippet returns the numbered lines around a reference, marking
+// the referenced line
+func sourceSnippet(ref sourceRef) (string, error) {
+	f, err := os.Open(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if line < ref.Line-fixSnippetLines {
+			continue
+		}
+		if line > ref.Line+fixSnippetLines {
+			break
+		}
+		marker := " "
+		if line == ref.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s%5d  %s\n", marker, line, scanner.Text())
+	}
+	return b.String(), scanner.Err()
+}
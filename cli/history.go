@@ -0,0 +1,683 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/config"
+	"github.com/hariharen9/lamacli/export"
+	"github.com/hariharen9/lamacli/importer"
+)
+
+// searchMatch is the JSON representation of a search result
+type searchMatch struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	MessageIndex int      `json:"message_index"`
+	Role         string   `json:"role,omitempty"`
+	Snippet      string   `json:"snippet"`
+	Highlights   [][2]int `json:"highlights"`
+	Score        float64  `json:"score"`
+}
+
+// handleHistoryCommand handles the history subcommands
+func handleHistoryCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("history requires a subcommand: list, show, export, import, delete, search, rename, summarize, tag, pin, unpin, mv, doctor or migrate")
+	}
+
+	historyManager, err := chathistory.NewChatHistoryManager()
+	if err != nil {
+		return fmt.Errorf("failed to open chat history: %w", err)
+	}
+
+	subcommand, args := args[0], args[1:]
+	switch subcommand {
+	case "list", "ls":
+		return historyList(historyManager, args)
+	case "show":
+		return historyShow(historyManager, args)
+	case "export":
+		return historyExport(historyManager, args)
+	case "import":
+		return historyImport(historyManager, args)
+	case "delete", "rm":
+		return historyDelete(historyManager, args)
+	case "search":
+		return historySearch(historyManager, args)
+	case "rename":
+		return historyRename(historyManager, args)
+	case "summarize":
+		return historySummarize(historyManager, args)
+	case "tag":
+		return historyTag(historyManager, args)
+	case "pin":
+		return historyPin(historyManager, args, true)
+	case "unpin":
+		return historyPin(historyManager, args, false)
+	case "mv", "move":
+		return historyMove(historyManager, args)
+	case "doctor":
+		return historyDoctor(historyManager, args)
+	case "migrate":
+		return historyMigrate(historyManager, args)
+	default:
+		return fmt.Errorf("unknown history subcommand '%s'. Use 'lamacli help' for usage information", subcommand)
+	}
+}
+
+// historyList prints a page of the saved sessions as a table or JSON
+func historyList(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history list", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	limit := flags.Int("limit", 0, "Show at most this many sessions")
+	offset := flags.Int("offset", 0, "Skip this many sessions, for the next page")
+	tag := flags.String("tag", "", "Only list sessions with this tag")
+	folder := flags.String("folder", "", "Only list sessions in this folder or its subfolders")
+	pinned := flags.Bool("pinned", false, "Only list pinned sessions")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	page, err := historyManager.ListSessionInfo(chathistory.Query{
+		Tag:         *tag,
+		Folder:      *folder,
+		Pinned:      *pinned,
+		PinnedFirst: true,
+		Offset:      *offset,
+		Limit:       *limit,
+	})
+	if err != nil {
+		return err
+	}
+	for _, issue := range historyManager.Issues() {
+		fmt.Fprintf(os.Stderr, "⚠️  %s: %s\n", issue.File, issue.Problem)
+	}
+
+	if *jsonOutput {
+		return printJSON(page.Sessions)
+	}
+
+	if len(page.Sessions) == 0 {
+		switch {
+		case page.Total > 0:
+			fmt.Printf("No sessions past --offset %d (%d in total).\n", *offset, page.Total)
+		case *tag != "" || *folder != "" || *pinned:
+			fmt.Println("No matching sessions.")
+		default:
+			fmt.Println("No chat history found.")
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tFOLDER\tTAGS\tMODEL\tMESSAGES\tUPDATED")
+	for _, info := range page.Sessions {
+		title := truncate(info.Title, 50)
+		if info.Pinned {
+			title = "📌 " + title
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			info.ID, title, info.Folder, strings.Join(info.Tags, ","), info.Model, info.Messages, info.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if shown := *offset + len(page.Sessions); shown < page.Total {
+		fmt.Printf("\nShowing %d-%d of %d. Next page: --offset %d\n", *offset+1, shown, page.Total, shown)
+	}
+	return nil
+}
+
+// historyShow renders a saved conversation with glamour
+func historyShow(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history show", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: lamacli history show <id>")
+	}
+
+	session, err := historyManager.LoadSessionRef(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		return printJSON(session)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	transcript := export.RenderMarkdown(session)
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(cfg.RenderWidth),
+	)
+	if err == nil {
+		if rendered, err := renderer.Render(transcript); err == nil {
+			transcript = rendered
+		}
+	}
+	fmt.Print(transcript)
+	return nil
+}
+
+// historyExport writes a saved session to stdout or a file, as JSON or in
+// another export format
+func historyExport(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history export", flag.ContinueOnError)
+	output := flags.String("o", "", "Write to this file instead of stdout")
+	formatName := flags.String("format", "", "Export format: markdown, html, json or text (default: from the -o extension, else json)")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: lamacli history export <id> [--format markdown|html|json|text] [-o file]")
+	}
+
+	format := export.JSON
+	switch {
+	case *formatName != "":
+		if format, err = export.ParseFormat(*formatName); err != nil {
+			return err
+		}
+	case *output != "":
+		if inferred, err := export.ParseFormat(filepath.Ext(*output)); err == nil {
+			format = inferred
+		}
+	}
+
+	session, err := historyManager.LoadSessionRef(positional[0])
+	if err != nil {
+		return err
+	}
+
+	data, err := export.Render(session, format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s to %s\n", session.ID, *output)
+	return nil
+}
+
+// importedSession is the JSON representation of an imported conversation
+type importedSession struct {
+	File        string `json:"file"`
+	Format      string `json:"format"`
+	Title       string `json:"title"`
+	Status      string `json:"status"` // imported, duplicate or invalid
+	ID          string `json:"id,omitempty"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	Messages    int    `json:"messages,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// historyImport converts conversations exported by other tools into saved
+// sessions
+func historyImport(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history import", flag.ContinueOnError)
+	formatName := flags.String("format", "auto", "Export format: auto, openai, openwebui, markdown or lamacli")
+	model := flags.String("model", "", "Model to continue the imported sessions with")
+	dryRun := flags.Bool("dry-run", false, "Show what would be imported without saving")
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: lamacli history import <file>... [--format auto|openai|openwebui|markdown|lamacli] [--model name] [--dry-run]")
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var sessions []*chathistory.ChatSession
+	var imported []importedSession
+	var pending []int // Index in imported of each session
+	for _, path := range positional {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		conversations, detected, err := importer.Read(path, data, format, info.ModTime())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, conversation := range conversations {
+			entry := importedSession{File: path, Format: string(detected), Title: conversation.Title}
+			if conversation.Err != nil {
+				entry.Status, entry.Error = "invalid", conversation.Err.Error()
+			} else {
+				if *model != "" {
+					conversation.Session.Model = *model
+				}
+				sessions = append(sessions, conversation.Session)
+				pending = append(pending, len(imported))
+			}
+			imported = append(imported, entry)
+		}
+	}
+
+	results, err := historyManager.ImportSessions(sessions, *dryRun)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		entry := &imported[pending[i]]
+		entry.Title = result.Session.Title
+		switch {
+		case result.Err != nil:
+			entry.Status, entry.Error = "invalid", result.Err.Error()
+		case result.Duplicate != nil:
+			entry.Status, entry.DuplicateOf = "duplicate", result.Duplicate.ID
+		default:
+			entry.Status, entry.ID = "imported", result.Session.ID
+			entry.Messages = len(result.Session.History) / 2
+		}
+	}
+
+	if *jsonOutput {
+		return printJSON(imported)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range imported {
+		counts[entry.Status]++
+		title := truncate(entry.Title, 50)
+		if title == "" {
+			title = entry.File
+		}
+		switch entry.Status {
+		case "imported":
+			if *dryRun {
+				fmt.Printf("✅ Would import %q (%d messages)\n", title, entry.Messages)
+			} else {
+				fmt.Printf("✅ Imported %q as %s (%d messages)\n", title, entry.ID, entry.Messages)
+			}
+		case "duplicate":
+			fmt.Printf("⏭️  Skipped %q: already saved as %s\n", title, entry.DuplicateOf)
+		default:
+			fmt.Printf("⚠️  Skipped %q: %s\n", title, entry.Error)
+		}
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d session(s), skipped %d duplicate(s) and %d invalid\n",
+		verb, counts["imported"], counts["duplicate"], counts["invalid"])
+	return nil
+}
+
+// historyDelete deletes one or more saved sessions
+func historyDelete(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lamacli history delete <id>...")
+	}
+
+	for _, id := range args {
+		if err := historyManager.DeleteSession(id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Printf("Deleted %s\n", id)
+	}
+	return nil
+}
+
+// historySearch prints the messages best matching a full-text query
+func historySearch(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history search", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	limit := flags.Int("limit", 20, "Show at most this many matches (0 for all)")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: lamacli history search <query>")
+	}
+
+	results, err := historyManager.SearchSessions(strings.Join(positional, " "))
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(results) > *limit {
+		results = results[:*limit]
+	}
+
+	matches := make([]searchMatch, len(results))
+	for i, result := range results {
+		matches[i] = searchMatch{
+			ID:           result.Session.ID,
+			Title:        result.Session.Title,
+			MessageIndex: result.MessageIndex,
+			Role:         messageRole(result.MessageIndex),
+			Snippet:      result.Snippet,
+			Highlights:   result.Highlights,
+			Score:        math.Round(result.Score*1000) / 1000,
+		}
+	}
+
+	if *jsonOutput {
+		return printJSON(matches)
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No matches found.")
+		return nil
+	}
+
+	highlight := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tROLE\tMATCH")
+	for i, match := range matches {
+		role := match.Role
+		if role == "" {
+			role = "title"
+		}
+		snippet := results[i].Highlight(func(s string) string { return highlight.Render(s) })
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", match.ID, truncate(match.Title, 30), role, snippet)
+	}
+	return w.Flush()
+}
+
+// historyRename changes the title of a saved session
+func historyRename(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: lamacli history rename <id> <title>")
+	}
+
+	title := strings.TrimSpace(strings.Join(args[1:], " "))
+	if err := historyManager.RenameSession(args[0], title); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed %s to %q\n", args[0], title)
+	return nil
+}
+
+// historySummarize regenerates the titles and summaries of saved sessions
+// with the titles model
+func historySummarize(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history summarize", flag.ContinueOnError)
+	model := flags.String("model", "", "Model to write the titles and summaries")
+	missing := flags.Bool("missing", false, "Summarize every session without a summary")
+	ids, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *missing {
+		page, err := historyManager.ListSessionInfo(chathistory.Query{})
+		if err != nil {
+			return err
+		}
+		for _, session := range page.Sessions {
+			if session.Summary == "" {
+				ids = append(ids, session.ID)
+			}
+		}
+		if len(ids) == 0 {
+			fmt.Println("Every session has a summary.")
+			return nil
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("usage: lamacli history summarize <id>... [--model name] or --missing")
+	}
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+	if *model == "" {
+		*model = cfg.ModelFor("titles")
+	}
+	if *model == "" {
+		*model = getDefaultModel(llmClient)
+	}
+
+	ctx := context.Background()
+	for i, id := range ids {
+		fmt.Fprintf(os.Stderr, "✨ Summarizing %s (%d/%d)...\n", id, i+1, len(ids))
+		session, err := historyManager.SummarizeSession(ctx, llmClient, *model, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Printf("%s: %s\n  %s\n", session.ID, session.Title, session.Summary)
+	}
+	return nil
+}
+
+// historyTag adds tags to a saved session, or removes them
+func historyTag(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history tag", flag.ContinueOnError)
+	remove := flags.Bool("remove", false, "Remove the tags instead of adding them")
+	clearTags := flags.Bool("clear", false, "Remove all tags")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || (len(positional) == 1 && !*clearTags) {
+		return fmt.Errorf("usage: lamacli history tag <id> <tag>... [--remove] | lamacli history tag <id> --clear")
+	}
+
+	// Each argument is a tag, or several separated by commas
+	id := positional[0]
+	var tags []string
+	for _, arg := range positional[1:] {
+		for _, tag := range strings.Split(arg, ",") {
+			if tag = chathistory.NormalizeTag(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	var result []string
+	switch {
+	case *clearTags:
+		result, err = historyManager.SetSessionTags(id, nil)
+	case *remove:
+		result, err = historyManager.TagSession(id, nil, tags)
+	default:
+		result, err = historyManager.TagSession(id, tags, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		fmt.Printf("%s has no tags\n", id)
+	} else {
+		fmt.Printf("Tags of %s: %s\n", id, strings.Join(result, ", "))
+	}
+	return nil
+}
+
+// historyPin pins or unpins saved sessions
+func historyPin(historyManager *chathistory.ChatHistoryManager, args []string, pinned bool) error {
+	verb := "pin"
+	if !pinned {
+		verb = "unpin"
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lamacli history %s <id>...", verb)
+	}
+
+	for _, id := range args {
+		if err := historyManager.PinSession(id, pinned); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		if pinned {
+			fmt.Printf("Pinned %s\n", id)
+		} else {
+			fmt.Printf("Unpinned %s\n", id)
+		}
+	}
+	return nil
+}
+
+// historyMove moves saved sessions into a folder, or out of folders with "/"
+func historyMove(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: lamacli history mv <id>... <folder> (use / for no folder)")
+	}
+
+	ids, folder := args[:len(args)-1], args[len(args)-1]
+	for _, id := range ids {
+		moved, err := historyManager.MoveSession(id, folder)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		if moved == "" {
+			fmt.Printf("Moved %s out of its folder\n", id)
+		} else {
+			fmt.Printf("Moved %s to %s\n", id, moved)
+		}
+	}
+	return nil
+}
+
+// historyDoctor checks the saved sessions and, with --fix, repairs them
+func historyDoctor(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	flags := flag.NewFlagSet("history doctor", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "Repair the problems found")
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	report, err := historyManager.Doctor(*fix)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		return printJSON(report)
+	}
+
+	fmt.Printf("Checked %d session file(s)\n", report.Checked)
+	fixable := 0
+	for _, issue := range report.Issues {
+		status := "⚠️ "
+		if issue.Fixed {
+			status = "✅"
+		} else if issue.Fixable {
+			fixable++
+		}
+		fmt.Printf("%s %s: %s\n", status, issue.File, issue.Problem)
+	}
+	if len(report.Quarantined) > 0 {
+		fmt.Printf("%d file(s) in quarantine: %s\n", len(report.Quarantined), historyManager.QuarantinePath())
+	}
+
+	switch {
+	case len(report.Issues) == 0:
+		fmt.Println("No problems found.")
+	case fixable > 0 && !*fix:
+		fmt.Println("Run 'lamacli history doctor --fix' to repair them.")
+	}
+	return nil
+}
+
+// messageRole returns the role of the message at a history index
+func messageRole(index int) string {
+	switch {
+	case index < 0:
+		return ""
+	case index%2 == 0:
+		return "user"
+	default:
+		return "assistant"
+	}
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// truncate shortens a string to at most n runes, collapsing newlines
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// historyMigrate copies the saved sessions to another storage backend and
+// switches the history_backend config key to it
+func historyMigrate(historyManager *chathistory.ChatHistoryManager, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lamacli history migrate <%s>", strings.Join(chathistory.Backends, "|"))
+	}
+	backend := args[0]
+	if backend == historyManager.Backend() {
+		return fmt.Errorf("chat history already uses the %s backend", backend)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	target, err := chathistory.NewChatHistoryManagerWithBackend(backend)
+	if err != nil {
+		return err
+	}
+
+	copied, err := historyManager.CopyTo(target)
+	for _, issue := range historyManager.Issues() {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipped %s: %s\n", issue.File, issue.Problem)
+	}
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set("history_backend", backend); err != nil {
+		return err
+	}
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Copied %d sessions from %s to %s, and set history_backend to %s.\n", copied, historyManager.Backend(), backend, backend)
+	fmt.Printf("The %s sessions were kept as a backup; 'lamacli history migrate %s' moves back.\n", historyManager.Backend(), historyManager.Backend())
+	return nil
+}
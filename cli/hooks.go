@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hariharen9/lamacli/git"
+)
+
+// hookMarker identifies hooks written by lamacli so that foreign hooks are
+// never overwritten or removed by accident
+const hookMarker = "# lamacli-prepare-commit-msg"
+
+// hookScript is the prepare-commit-msg hook. %s are the quoted path of
+// the lamacli binary and the message style.
+const hookScript = `#!/bin/sh
+` + hookMarker + `
+# Pre-fills the commit message with a draft from lamacli. It never blocks
+# a commit: on failure or timeout the message is left untouched.
+# Remove with 'lamacli hooks uninstall'.
+
+# Skip messages given with -m/-F, merges, squashes and amends
+case "$2" in
+message|merge|squash|commit) exit 0 ;;
+esac
+[ -n "$LAMACLI_SKIP_HOOK" ] && exit 0
+
+LAMACLI=%s
+[ -x "$LAMACLI" ] || LAMACLI=$(command -v lamacli) || exit 0
+"$LAMACLI" commit --hook "$1" --style %s </dev/null >/dev/null || true
+exit 0
+`
+
+// handleHooksCommand installs or removes the prepare-commit-msg hook
+func handleHooksCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("hooks requires a subcommand: install or uninstall")
+	}
+
+	hooksDir, err := git.Path("hooks")
+	if err != nil {
+		return err
+	}
+	hookPath := filepath.Join(hooksDir, "prepare-commit-msg")
+
+	switch args[0] {
+	case "install":
+		flags := flag.NewFlagSet("hooks install", flag.ContinueOnError)
+		force := flags.Bool("force", false, "Replace an existing prepare-commit-msg hook")
+		style := flags.String("style", commitStyleConventional, "Message style: conventional or plain")
+		if _, err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *style != commitStyleConventional && *style != commitStylePlain {
+			return fmt.Errorf("invalid style '%s'. Please use 'conventional' or 'plain'", *style)
+		}
+		return installHook(hookPath, *style, *force)
+
+	case "uninstall":
+		return uninstallHook(hookPath)
+
+	default:
+		return fmt.Errorf("unknown hooks subcommand '%s'. Use install or uninstall", args[0])
+	}
+}
+
+// installHook writes the prepare-commit-msg hook
+func installHook(hookPath, style string, force bool) error {
+	if existing, err := os.ReadFile(hookPath); err == nil {
+		if !strings.Contains(string(existing), hookMarker) && !force {
+			return fmt.Errorf("%s already exists and was not installed by lamacli. Use --force to replace it", hookPath)
+		}
+	}
+
+	// Point the hook at this binary, falling back to lamacli on the PATH
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "lamacli"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	script := fmt.Sprintf(hookScript, shellQuote(exe), style)
+	if err := os.WriteFile(hookPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	fmt.Printf("✅ Installed prepare-commit-msg hook at %s\n", hookPath)
+	return nil
+}
+
+// uninstallHook removes the hook if lamacli installed it
+func uninstallHook(hookPath string) error {
+	existing, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		fmt.Println("No prepare-commit-msg hook installed.")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hook: %w", err)
+	}
+	if !strings.Contains(string(existing), hookMarker) {
+		return fmt.Errorf("%s was not installed by lamacli; leaving it in place", hookPath)
+	}
+
+	if err := os.Remove(hookPath); err != nil {
+		return fmt.Errorf("failed to remove hook: %w", err)
+	}
+	fmt.Printf("🗑️  Removed prepare-commit-msg hook from %s\n", hookPath)
+	return nil
+}
+
+// runCommitHook drafts a commit message into the message file git passes
+// to prepare-commit-msg. It never returns an error so the commit always
+// goes ahead; problems are reported on stderr.
+func runCommitHook(options *commitOptions, messageFile string) error {
+	if err := draftIntoMessageFile(options, messageFile); err != nil {
+		fmt.Fprintf(os.Stderr, "lamacli: commit message not drafted: %v\n", err)
+	}
+	return nil
+}
+
+// draftIntoMessageFile prepends a drafted message to the message file,
+// unless it already holds a message or the commit is a merge
+func draftIntoMessageFile(options *commitOptions, messageFile string) error {
+	existing, err := os.ReadFile(messageFile)
+	if err != nil {
+		return err
+	}
+	if hasCommitMessage(string(existing)) {
+		return nil
+	}
+	if mergeHead, err := git.Path("MERGE_HEAD"); err == nil {
+		if _, err := os.Stat(mergeHead); err == nil {
+			return nil
+		}
+	}
+
+	diff, err := git.StagedDiff()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.HookTimeout)*time.Second)
+	defer cancel()
+
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+	if err := llmClient.Ping(ctx); err != nil {
+		return err
+	}
+
+	model := options.Model
+	if model == "" {
+		model = cfg.ModelFor("commit")
+	}
+	if model == "" {
+		// List the models under the timeout too, so a hung server cannot
+		// stall the commit
+		models, err := llmClient.ListModelsContext(ctx)
+		if err != nil {
+			return err
+		}
+		if len(models) == 0 {
+			return fmt.Errorf("no Ollama models found")
+		}
+		model = models[0]
+	}
+
+	response, err := llmClient.Complete(ctx, model,
+		commitSystemPrompt(options.Style, options.MaxLength),
+		[]string{commitUserPrompt(diff, cfg.ContextLimit)})
+	if err != nil {
+		return err
+	}
+
+	message := cleanCommitMessage(response, options.MaxLength)
+	if message == "" {
+		return nil
+	}
+	return os.WriteFile(messageFile, []byte(message+"\n"+string(existing)), 0644)
+}
+
+// hasCommitMessage reports whether the message file contains anything
+// besides comments and blank lines
+func hasCommitMessage(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
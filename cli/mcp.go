@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hariharen9/lamacli/mcp"
+)
+
+// mcpServerInfo is a server as printed by 'mcp list --json'
+type mcpServerInfo struct {
+	Name      string         `json:"name"`
+	Connected bool           `json:"connected"`
+	Error     string         `json:"error,omitempty"`
+	Tools     []mcp.Tool     `json:"tools"`
+	Resources []mcp.Resource `json:"resources"`
+}
+
+// handleMCPCommand handles the mcp subcommands
+func handleMCPCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: lamacli mcp list [--json]")
+	}
+
+	flags := flag.NewFlagSet("mcp list", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Print servers as JSON")
+	profile := flags.String("profile", "", "Use a named config profile")
+	if _, err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	if len(cfg.MCPServers) == 0 {
+		return fmt.Errorf("no MCP servers configured. Add one under [mcp_servers.<name>] in the config file")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	manager := mcp.Connect(ctx, cfg.MCPServers, Version)
+	defer manager.Close()
+
+	var servers []mcpServerInfo
+	for _, server := range manager.Servers {
+		info := mcpServerInfo{
+			Name:      server.Name,
+			Connected: server.Err == nil,
+			Tools:     server.Tools,
+			Resources: server.Resources,
+		}
+		if server.Err != nil {
+			info.Error = server.Err.Error()
+		}
+		servers = append(servers, info)
+	}
+	for name, server := range cfg.MCPServers {
+		if server.Disabled {
+			servers = append(servers, mcpServerInfo{Name: name, Error: "disabled"})
+		}
+	}
+
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	if *jsonOutput {
+		return printJSON(servers)
+	}
+
+	for i, server := range servers {
+		if i > 0 {
+			fmt.Println()
+		}
+		if !server.Connected {
+			fmt.Printf("❌ %s: %s\n", server.Name, server.Error)
+			continue
+		}
+		fmt.Printf("🔌 %s (%d tool(s), %d resource(s))\n", server.Name, len(server.Tools), len(server.Resources))
+		for _, tool := range server.Tools {
+			fmt.Printf("  🔧 %s", tool.Name)
+			if tool.Description != "" {
+				fmt.Printf(" - %s", truncate(tool.Description, 80))
+			}
+			fmt.Println()
+		}
+		for _, resource := range server.Resources {
+			fmt.Printf("  📄 %s (%s)\n", resource.URI, resource.Name)
+		}
+	}
+	return nil
+}
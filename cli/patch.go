@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/hariharen9/lamacli/patch"
+)
+
+// applyResponsePatch previews the file changes in a response and applies
+// them to the current directory once confirmed. It reports whether
+// anything was applied.
+func applyResponsePatch(response string, yes bool) (bool, error) {
+	changes, err := patch.Extract(response)
+	if err != nil {
+		return false, err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No file changes found in the response.")
+		return false, nil
+	}
+
+	plan, err := patch.Prepare(".", changes)
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("\n🩹 Proposed changes:\n%s\n", plan.Preview())
+
+	if !yes {
+		apply := false
+		err := huh.NewForm(
+			huh.NewGroup(
+				huh.NewConfirm().
+					Title("Apply these changes?").
+					Description(strings.Join(plan.Summary(), "\n")).
+					Value(&apply),
+			),
+		).WithTheme(huh.ThemeBase16()).Run()
+		if err != nil {
+			return false, err
+		}
+		if !apply {
+			fmt.Println("Changes not applied.")
+			return false, nil
+		}
+	}
+
+	backup, err := plan.Apply()
+	if err != nil {
+		return false, err
+	}
+	for _, line := range plan.Summary() {
+		fmt.Printf("   %s\n", line)
+	}
+	fmt.Printf("✅ Applied. Undo with 'lamacli undo %s'\n", backup.ID)
+	return true, nil
+}
+
+// handleUndoCommand restores the files from a patch backup
+func handleUndoCommand(args []string) error {
+	flags := flag.NewFlagSet("undo", flag.ContinueOnError)
+	list := flags.Bool("list", false, "List patches that can be undone")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		backups, err := patch.ListBackups(".")
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Println("No patches to undo.")
+			return nil
+		}
+		for _, backup := range backups {
+			paths := make([]string, len(backup.Files))
+			for i, file := range backup.Files {
+				paths[i] = file.Path
+			}
+			fmt.Printf("%s  %s  %s\n", backup.ID, backup.Created.Format("2006-01-02 15:04"), strings.Join(paths, ", "))
+		}
+		return nil
+	}
+
+	id := ""
+	if len(positional) > 0 {
+		id = positional[0]
+	}
+	backup, err := patch.Undo(".", id)
+	if err != nil {
+		return err
+	}
+	for _, file := range backup.Files {
+		action := "restored"
+		if !file.Existed {
+			action = "removed"
+		}
+		fmt.Printf("   %s %s\n", action, file.Path)
+	}
+	fmt.Printf("↩️  Undid patch %s\n", backup.ID)
+	return nil
+}
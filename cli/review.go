@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hariharen9/lamacli/git"
+	"github.com/hariharen9/lamacli/review"
+)
+
+// Review output formats
+const (
+	reviewFormatText  = "text"
+	reviewFormatJSON  = "json"
+	reviewFormatSARIF = "sarif"
+)
+
+// reviewContextLines is how many unchanged lines surround each hunk
+const reviewContextLines = 10
+
+// reviewOptions holds options for the review command
+type reviewOptions struct {
+	Model   string
+	Profile string
+	Staged  bool
+	Base    string
+	Format  string
+}
+
+// handleReviewCommand reviews a diff file by file and reports the findings
+func handleReviewCommand(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	options := &reviewOptions{}
+	flags.StringVar(&options.Model, "model", "", "Override default model")
+	flags.StringVar(&options.Profile, "profile", "", "Use a named config profile")
+	flags.BoolVar(&options.Staged, "staged", false, "Review the staged changes")
+	flags.StringVar(&options.Base, "base", "", "Review the current branch against a base branch")
+	flags.StringVar(&options.Format, "format", reviewFormatText, "Output format: text, json or sarif")
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	switch options.Format {
+	case reviewFormatText, reviewFormatJSON, reviewFormatSARIF:
+	default:
+		return fmt.Errorf("invalid format '%s'. Please use 'text', 'json' or 'sarif'", options.Format)
+	}
+	if options.Staged && options.Base != "" {
+		return fmt.Errorf("--staged and --base cannot be used together")
+	}
+
+	diff, err := reviewDiff(options, files)
+	if err != nil {
+		return err
+	}
+	fileDiffs := review.ParseDiff(diff)
+	if len(fileDiffs) == 0 {
+		return fmt.Errorf("no changes to review")
+	}
+
+	cfg, err := loadConfig(options.Profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	model := options.Model
+	if model == "" {
+		model = cfg.ModelFor("review")
+	}
+	if model == "" {
+		model = getDefaultModel(llmClient)
+	}
+
+	ctx := context.Background()
+	findings := []review.Finding{}
+	for i, file := range fileDiffs {
+		fmt.Fprintf(os.Stderr, "🔍 Reviewing %s (%d/%d)...\n", file.Path, i+1, len(fileDiffs))
+		for _, chunk := range review.Chunks(file, cfg.ContextLimit) {
+			response, err := llmClient.CompleteJSON(ctx, model, review.SystemPrompt,
+				[]string{review.Prompt(file.Path, chunk)}, review.Schema)
+			if err != nil {
+				return err
+			}
+			fileFindings, err := review.ParseFindings(file.Path, response)
+			if err != nil {
+				// One malformed answer shouldn't lose the rest of the review
+				fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+				continue
+			}
+			findings = append(findings, fileFindings...)
+		}
+	}
+	review.Sort(findings)
+
+	switch options.Format {
+	case reviewFormatJSON:
+		return printJSON(findings)
+	case reviewFormatSARIF:
+		return review.WriteSARIF(os.Stdout, findings, Version)
+	default:
+		printFormattedResponse(CommandReview, reviewMarkdown(findings, len(fileDiffs)), model, cfg.RenderWidth)
+		return nil
+	}
+}
+
+// reviewDiff returns the diff selected by the flags: the staged changes,
+// the branch against its base, or the uncommitted changes, optionally
+// limited to the given files
+func reviewDiff(options *reviewOptions, files []string) (string, error) {
+	var args []string
+	switch {
+	case options.Staged:
+		args = append(args, "--staged")
+	case options.Base != "":
+		// Compare against the merge base, like a pull request would
+		args = append(args, options.Base+"...HEAD")
+	default:
+		args = append(args, "HEAD")
+	}
+	if len(files) > 0 {
+		args = append(args, "--")
+		args = append(args, files...)
+	}
+	return git.Diff(reviewContextLines, args...)
+}
+
+// reviewMarkdown renders the findings grouped by file
+func reviewMarkdown(findings []review.Finding, fileCount int) string {
+	if len(findings) == 0 {
+		return fmt.Sprintf("✅ No issues found in %d file(s).", fileCount)
+	}
+
+	icons := map[string]string{
+		review.SeverityError:   "🔴",
+		review.SeverityWarning: "🟡",
+		review.SeverityInfo:    "🔵",
+	}
+
+	var b strings.Builder
+	counts := review.Count(findings)
+	fmt.Fprintf(&b, "**%d finding(s)** in %d file(s): %d error, %d warning, %d info\n",
+		len(findings), fileCount, counts[review.SeverityError], counts[review.SeverityWarning], counts[review.SeverityInfo])
+
+	currentFile := ""
+	for _, finding := range findings {
+		if finding.File != currentFile {
+			currentFile = finding.File
+			fmt.Fprintf(&b, "\n### %s\n\n", currentFile)
+		}
+		location := "general"
+		if finding.Line > 0 {
+			location = fmt.Sprintf("line %d", finding.Line)
+		}
+		fmt.Fprintf(&b, "- %s **%s** (%s): %s\n", icons[finding.Severity], finding.Severity, location, finding.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/server"
+)
+
+// defaultServeAddr is next to Ollama's default port and only reachable
+// from this machine
+const defaultServeAddr = "127.0.0.1:11435"
+
+// handleServeCommand serves ask, suggest, explain, sessions and templates
+// over HTTP for editor plugins and scripts
+func handleServeCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", defaultServeAddr, "Address to listen on")
+	token := flags.String("token", os.Getenv("LAMACLI_TOKEN"), "Bearer token clients must send (default: $LAMACLI_TOKEN or a random token)")
+	profile := flags.String("profile", "", "Use a named config profile")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument '%s'", positional[0])
+	}
+
+	cfg, err := loadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+	historyManager, err := chathistory.NewChatHistoryManager()
+	if err != nil {
+		return fmt.Errorf("failed to open chat history: %w", err)
+	}
+
+	// Without a token, generate one and save it where plugins can find it
+	tokenFile := ""
+	if *token == "" {
+		if *token, tokenFile, err = generateServeToken(); err != nil {
+			return err
+		}
+		defer os.Remove(tokenFile)
+	}
+
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return fmt.Errorf("invalid --addr: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "⚠️  Listening on %s exposes your files and history to the network\n", host)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	handler := server.New(server.Options{
+		Config:  cfg,
+		Client:  llmClient,
+		History: historyManager,
+		Token:   *token,
+		Version: Version,
+		SystemPrompt: func(command, custom string) string {
+			return buildSystemPrompt(Command(command), custom, cfg)
+		},
+	})
+	httpServer := &http.Server{
+		Handler:           logRequests(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Fprintf(os.Stderr, "🦙 Serving on http://%s\n", listener.Addr())
+	if tokenFile != "" {
+		fmt.Fprintf(os.Stderr, "🔑 Token: %s (saved to %s)\n", *token, tokenFile)
+	}
+
+	// Ctrl+C shuts down gracefully, letting in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpServer.Serve(listener) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Fprintln(os.Stderr, "\nShutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// generateServeToken creates a random token and writes it to
+// ~/.lamacli/serve_token, readable only by the user
+func generateServeToken() (string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(homeDir, ".lamacli", "serve_token")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", "", fmt.Errorf("failed to save token: %w", err)
+	}
+	return token, path, nil
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController flush the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests logs each request to stderr
+func logRequests(next http.Handler) http.Handler {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		logger.Printf("%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
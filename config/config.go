@@ -0,0 +1,517 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Default values used when the config file does not set them.
+const (
+	DefaultTheme          = "dark"
+	DefaultContextLimit   = 10000
+	DefaultRenderWidth    = 100
+	DefaultHookTimeout    = 15
+	DefaultHistoryBackend = "json"
+)
+
+// DefaultSystemPrompt is used by chat and ask when no system prompt is
+// configured.
+const DefaultSystemPrompt = "You are a helpful assistant."
+
+// ModelDefaults holds the default model for each command. An empty value
+// falls back to Default, and an empty Default falls back to the first
+// model reported by Ollama.
+type ModelDefaults struct {
+	Default string `toml:"default"`
+	Chat    string `toml:"chat"`
+	Ask     string `toml:"ask"`
+	Suggest string `toml:"suggest"`
+	Explain string `toml:"explain"`
+	Commit  string `toml:"commit"`
+	Review  string `toml:"review"`
+	Fix     string `toml:"fix"`
+	Titles  string `toml:"titles"` // Writes chat session titles and summaries
+}
+
+// Profile bundles the settings that differ between environments, such as
+// a remote GPU server and a laptop. Non-empty profile values take
+// precedence over the top-level settings.
+type Profile struct {
+	Host         string         `toml:"host"`
+	Model        string         `toml:"model"`
+	SystemPrompt string         `toml:"system_prompt"`
+	Options      map[string]any `toml:"options"`
+}
+
+// MCPServer is a Model Context Protocol server that lamacli launches as a
+// subprocess and talks to over stdio.
+type MCPServer struct {
+	Command     string            `toml:"command"`
+	Args        []string          `toml:"args"`
+	Env         map[string]string `toml:"env"`
+	AutoApprove []string          `toml:"auto_approve"` // Tools that run without asking
+	Disabled    bool              `toml:"disabled"`
+}
+
+// Config represents the persistent lamacli configuration.
+type Config struct {
+	Host           string               `toml:"host"`
+	Theme          string               `toml:"theme"`
+	SystemPrompt   string               `toml:"system_prompt"`
+	ContextLimit   int                  `toml:"context_limit"`
+	RenderWidth    int                  `toml:"render_width"`
+	HookTimeout    int                  `toml:"hook_timeout"`
+	HistoryBackend string               `toml:"history_backend"` // Chat history storage: json or bolt
+	Profile        string               `toml:"profile"`
+	Models         ModelDefaults        `toml:"models"`
+	Options        map[string]any       `toml:"options"`
+	Profiles       map[string]Profile   `toml:"profiles"`
+	MCPServers     map[string]MCPServer `toml:"mcp_servers"`
+
+	// active is the profile in use for this run. It starts as Profile,
+	// overridden by LAMACLI_PROFILE, and is never written back.
+	active string
+}
+
+// Default returns a config populated with the built-in defaults.
+func Default() *Config {
+	return &Config{
+		Theme:          DefaultTheme,
+		ContextLimit:   DefaultContextLimit,
+		RenderWidth:    DefaultRenderWidth,
+		HookTimeout:    DefaultHookTimeout,
+		HistoryBackend: DefaultHistoryBackend,
+	}
+}
+
+// Path returns the location of the config file. LAMACLI_CONFIG takes
+// precedence, then $XDG_CONFIG_HOME/lamacli/config.toml, then
+// ~/.lamacli/config.toml.
+func Path() (string, error) {
+	if p := os.Getenv("LAMACLI_CONFIG"); p != "" {
+		return p, nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "lamacli", "config.toml"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".lamacli", "config.toml"), nil
+}
+
+// Load reads the config file and selects the active profile. A missing
+// file is not an error; the defaults are returned instead.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+
+	if _, err := toml.DecodeFile(path, cfg); err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	cfg.applyDefaults()
+
+	profile := cfg.Profile
+	if env := os.Getenv("LAMACLI_PROFILE"); env != "" {
+		profile = env
+	}
+	if err := cfg.UseProfile(profile); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Save writes the config file, creating its directory if needed.
+func (c *Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := toml.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// applyDefaults fills in zero values that would otherwise disable features.
+func (c *Config) applyDefaults() {
+	if c.Theme == "" {
+		c.Theme = DefaultTheme
+	}
+	if c.ContextLimit <= 0 {
+		c.ContextLimit = DefaultContextLimit
+	}
+	if c.RenderWidth <= 0 {
+		c.RenderWidth = DefaultRenderWidth
+	}
+	if c.HookTimeout <= 0 {
+		c.HookTimeout = DefaultHookTimeout
+	}
+	if c.HistoryBackend == "" {
+		c.HistoryBackend = DefaultHistoryBackend
+	}
+}
+
+// UseProfile switches the active profile for this run. An empty name
+// disables profiles.
+func (c *Config) UseProfile(name string) error {
+	if name != "" {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("unknown profile '%s'", name)
+		}
+	}
+	c.active = name
+	return nil
+}
+
+// ActiveProfile returns the name of the active profile, or an empty
+// string when none is selected.
+func (c *Config) ActiveProfile() string {
+	return c.active
+}
+
+// ProfileNames returns the configured profile names in sorted order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profile returns the active profile, or an empty one.
+func (c *Config) profile() Profile {
+	return c.Profiles[c.active]
+}
+
+// OllamaHost returns the Ollama host for the active profile.
+func (c *Config) OllamaHost() string {
+	if host := c.profile().Host; host != "" {
+		return host
+	}
+	return c.Host
+}
+
+// GenerationOptions returns the model options (temperature, num_ctx, ...)
+// with the active profile's options layered over the top-level ones.
+func (c *Config) GenerationOptions() map[string]any {
+	options := make(map[string]any)
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	for k, v := range c.profile().Options {
+		options[k] = v
+	}
+	return options
+}
+
+// ConfiguredPrompt returns the system prompt set by the active profile or
+// the top-level config, or an empty string if neither sets one.
+func (c *Config) ConfiguredPrompt() string {
+	if prompt := c.profile().SystemPrompt; prompt != "" {
+		return prompt
+	}
+	return c.SystemPrompt
+}
+
+// DefaultPrompt returns the configured system prompt, falling back to
+// DefaultSystemPrompt.
+func (c *Config) DefaultPrompt() string {
+	if prompt := c.ConfiguredPrompt(); prompt != "" {
+		return prompt
+	}
+	return DefaultSystemPrompt
+}
+
+// ModelFor returns the configured default model for a command such as
+// "ask" or "chat", or an empty string if none is configured. The active
+// profile's model wins, since per-command models may not exist on the
+// profile's host.
+func (c *Config) ModelFor(command string) string {
+	if model := c.profile().Model; model != "" {
+		return model
+	}
+
+	var model string
+	switch command {
+	case "chat":
+		model = c.Models.Chat
+	case "ask":
+		model = c.Models.Ask
+	case "suggest":
+		model = c.Models.Suggest
+	case "explain":
+		model = c.Models.Explain
+	case "commit":
+		model = c.Models.Commit
+	case "review":
+		model = c.Models.Review
+	case "fix":
+		model = c.Models.Fix
+	case "titles":
+		model = c.Models.Titles
+	}
+	if model == "" {
+		model = c.Models.Default
+	}
+	return model
+}
+
+// field describes a single settable config key.
+type field struct {
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+var fields = map[string]field{
+	"history_backend": {
+		get: func(c *Config) string { return c.HistoryBackend },
+		set: func(c *Config, v string) error {
+			if v != "json" && v != "bolt" {
+				return fmt.Errorf("invalid history backend '%s'. Please use 'json' or 'bolt'", v)
+			}
+			c.HistoryBackend = v
+			return nil
+		},
+	},
+	"hook_timeout": {
+		get: func(c *Config) string { return strconv.Itoa(c.HookTimeout) },
+		set: func(c *Config, v string) error { return setPositiveInt(&c.HookTimeout, v) },
+	},
+	"host": {
+		get: func(c *Config) string { return c.Host },
+		set: func(c *Config, v string) error { c.Host = v; return nil },
+	},
+	"theme": {
+		get: func(c *Config) string { return c.Theme },
+		set: func(c *Config, v string) error {
+			if v != "dark" && v != "light" {
+				return fmt.Errorf("invalid theme '%s'. Please use 'dark' or 'light'", v)
+			}
+			c.Theme = v
+			return nil
+		},
+	},
+	"system_prompt": {
+		get: func(c *Config) string { return c.SystemPrompt },
+		set: func(c *Config, v string) error { c.SystemPrompt = v; return nil },
+	},
+	"context_limit": {
+		get: func(c *Config) string { return strconv.Itoa(c.ContextLimit) },
+		set: func(c *Config, v string) error { return setPositiveInt(&c.ContextLimit, v) },
+	},
+	"profile": {
+		get: func(c *Config) string { return c.Profile },
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, ok := c.Profiles[v]; !ok {
+					return fmt.Errorf("unknown profile '%s'", v)
+				}
+			}
+			c.Profile = v
+			return nil
+		},
+	},
+	"render_width": {
+		get: func(c *Config) string { return strconv.Itoa(c.RenderWidth) },
+		set: func(c *Config, v string) error { return setPositiveInt(&c.RenderWidth, v) },
+	},
+	"models.default": {
+		get: func(c *Config) string { return c.Models.Default },
+		set: func(c *Config, v string) error { c.Models.Default = v; return nil },
+	},
+	"models.chat": {
+		get: func(c *Config) string { return c.Models.Chat },
+		set: func(c *Config, v string) error { c.Models.Chat = v; return nil },
+	},
+	"models.ask": {
+		get: func(c *Config) string { return c.Models.Ask },
+		set: func(c *Config, v string) error { c.Models.Ask = v; return nil },
+	},
+	"models.suggest": {
+		get: func(c *Config) string { return c.Models.Suggest },
+		set: func(c *Config, v string) error { c.Models.Suggest = v; return nil },
+	},
+	"models.explain": {
+		get: func(c *Config) string { return c.Models.Explain },
+		set: func(c *Config, v string) error { c.Models.Explain = v; return nil },
+	},
+	"models.commit": {
+		get: func(c *Config) string { return c.Models.Commit },
+		set: func(c *Config, v string) error { c.Models.Commit = v; return nil },
+	},
+	"models.review": {
+		get: func(c *Config) string { return c.Models.Review },
+		set: func(c *Config, v string) error { c.Models.Review = v; return nil },
+	},
+	"models.fix": {
+		get: func(c *Config) string { return c.Models.Fix },
+		set: func(c *Config, v string) error { c.Models.Fix = v; return nil },
+	},
+	"models.titles": {
+		get: func(c *Config) string { return c.Models.Titles },
+		set: func(c *Config, v string) error { c.Models.Titles = v; return nil },
+	},
+}
+
+// Keys returns all config keys in sorted order, including the keys of
+// the configured profiles and options.
+func (c *Config) Keys() []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	for name := range c.Options {
+		keys = append(keys, "options."+name)
+	}
+	for name, p := range c.Profiles {
+		prefix := "profiles." + name + "."
+		keys = append(keys, prefix+"host", prefix+"model", prefix+"system_prompt")
+		for opt := range p.Options {
+			keys = append(keys, prefix+"options."+opt)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the value of a config key.
+func (c *Config) Get(key string) (string, error) {
+	key = strings.ToLower(key)
+	if f, ok := fields[key]; ok {
+		return f.get(c), nil
+	}
+
+	if opt, ok := strings.CutPrefix(key, "options."); ok {
+		return formatOption(c.Options[opt]), nil
+	}
+	if name, rest, ok := splitProfileKey(key); ok {
+		p, exists := c.Profiles[name]
+		if !exists {
+			return "", fmt.Errorf("unknown profile '%s'", name)
+		}
+		switch rest {
+		case "host":
+			return p.Host, nil
+		case "model":
+			return p.Model, nil
+		case "system_prompt":
+			return p.SystemPrompt, nil
+		}
+		if opt, ok := strings.CutPrefix(rest, "options."); ok {
+			return formatOption(p.Options[opt]), nil
+		}
+	}
+	return "", fmt.Errorf("unknown config key '%s'", key)
+}
+
+// Set updates a config key, validating the value. Setting a key under
+// profiles.<name> creates the profile if needed.
+func (c *Config) Set(key, value string) error {
+	key = strings.ToLower(key)
+	if f, ok := fields[key]; ok {
+		return f.set(c, value)
+	}
+
+	if opt, ok := strings.CutPrefix(key, "options."); ok && opt != "" {
+		if c.Options == nil {
+			c.Options = make(map[string]any)
+		}
+		c.Options[opt] = parseOption(value)
+		return nil
+	}
+	if name, rest, ok := splitProfileKey(key); ok {
+		p := c.Profiles[name]
+		switch rest {
+		case "host":
+			p.Host = value
+		case "model":
+			p.Model = value
+		case "system_prompt":
+			p.SystemPrompt = value
+		default:
+			opt, ok := strings.CutPrefix(rest, "options.")
+			if !ok || opt == "" {
+				return fmt.Errorf("unknown config key '%s'", key)
+			}
+			if p.Options == nil {
+				p.Options = make(map[string]any)
+			}
+			p.Options[opt] = parseOption(value)
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]Profile)
+		}
+		c.Profiles[name] = p
+		return nil
+	}
+	return fmt.Errorf("unknown config key '%s'", key)
+}
+
+// splitProfileKey splits "profiles.<name>.<rest>" into its parts.
+func splitProfileKey(key string) (name, rest string, ok bool) {
+	key, ok = strings.CutPrefix(key, "profiles.")
+	if !ok {
+		return "", "", false
+	}
+	name, rest, ok = strings.Cut(key, ".")
+	return name, rest, ok && name != "" && rest != ""
+}
+
+// parseOption converts an option value from the command line into the
+// number or boolean Ollama expects, keeping anything else as a string.
+func parseOption(value string) any {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value
+}
+
+// formatOption renders an option value for display.
+func formatOption(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func setPositiveInt(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid value '%s'. Please use a positive integer", value)
+	}
+	*dst = n
+	return nil
+}
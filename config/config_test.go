@@ -0,0 +1,116 @@
+package config
+
+import "testing"
+
+func TestSplitProfileKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		name, rest string
+		ok         bool
+	}{
+		{"profiles.gpu.host", "gpu", "host", true},
+		{"profiles.gpu.options.temperature", "gpu", "options.temperature", true},
+		{"profiles.gpu", "", "", false},
+		{"profiles.gpu.", "", "", false},
+		{"profiles..host", "", "", false},
+		{"profiles.", "", "", false},
+		{"host", "", "", false},
+		{"options.temperature", "", "", false},
+	}
+	for _, tt := range tests {
+		name, rest, ok := splitProfileKey(tt.key)
+		if ok != tt.ok || (ok && (name != tt.name || rest != tt.rest)) {
+			t.Errorf("splitProfileKey(%q) = %q, %q, %v; want %q, %q, %v", tt.key, name, rest, ok, tt.name, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestProfileKeys(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Set("profiles.gpu.host", "http://gpu:11434"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("profiles.gpu.options.temperature", "0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("profiles.gpu.colour", "red"); err == nil {
+		t.Error("Set of an unknown profile key succeeded")
+	}
+
+	if got, err := cfg.Get("profiles.gpu.host"); err != nil || got != "http://gpu:11434" {
+		t.Errorf("Get(profiles.gpu.host) = %q, %v", got, err)
+	}
+	if got, err := cfg.Get("profiles.gpu.options.temperature"); err != nil || got != "0.2" {
+		t.Errorf("Get(profiles.gpu.options.temperature) = %q, %v", got, err)
+	}
+	if _, err := cfg.Get("profiles.laptop.host"); err == nil {
+		t.Error("Get of an unknown profile succeeded")
+	}
+
+	if err := cfg.UseProfile("laptop"); err == nil {
+		t.Error("UseProfile of an unknown profile succeeded")
+	}
+	if err := cfg.UseProfile("gpu"); err != nil {
+		t.Fatal(err)
+	}
+	if host := cfg.OllamaHost(); host != "http://gpu:11434" {
+		t.Errorf("OllamaHost() = %q", host)
+	}
+}
+
+func TestModelFor(t *testing.T) {
+	models := ModelDefaults{Default: "llama3", Commit: "qwen-coder", Titles: "gemma"}
+	tests := []struct {
+		name    string
+		models  ModelDefaults
+		profile string // Model of the active profile
+		command string
+		want    string
+	}{
+		{"command model", models, "", "commit", "qwen-coder"},
+		{"titles model", models, "", "titles", "gemma"},
+		{"falls back to default", models, "", "review", "llama3"},
+		{"unknown command", models, "", "dance", "llama3"},
+		{"nothing configured", ModelDefaults{}, "", "chat", ""},
+		{"profile overrides command", models, "mistral", "commit", "mistral"},
+		{"profile overrides default", models, "mistral", "chat", "mistral"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Models = tt.models
+			if tt.profile != "" {
+				cfg.Profiles = map[string]Profile{"p": {Model: tt.profile}}
+				if err := cfg.UseProfile("p"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cfg.ModelFor(tt.command); got != tt.want {
+				t.Errorf("ModelFor(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("LAMACLI_CONFIG", t.TempDir()+"/lamacli/config.toml")
+	t.Setenv("LAMACLI_PROFILE", "")
+
+	cfg := Default()
+	for key, value := range map[string]string{"theme": "light", "models.titles": "gemma", "profiles.gpu.host": "http://gpu:11434"} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Theme != "light" || loaded.ModelFor("titles") != "gemma" || loaded.Profiles["gpu"].Host != "http://gpu:11434" {
+		t.Errorf("loaded config = %+v", loaded)
+	}
+}
@@ -0,0 +1,131 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// JudgeFunc asks a model whether output meets a rubric.
+type JudgeFunc func(ctx context.Context, rubric, prompt, output string) (pass bool, reason string, err error)
+
+// Outcome is the result of one assertion.
+type Outcome struct {
+	Assertion string // Short description, e.g. `contains "ls"`
+	Pass      bool
+	Detail    string // Why the assertion failed
+}
+
+// Check evaluates an assertion against a case's output and latency.
+func Check(ctx context.Context, a Assertion, prompt, output string, latency time.Duration, judge JudgeFunc) Outcome {
+	switch {
+	case a.Contains != "":
+		outcome := Outcome{Assertion: fmt.Sprintf("contains %q", a.Contains)}
+		outcome.Pass = strings.Contains(output, a.Contains)
+		if !outcome.Pass {
+			outcome.Detail = "text not found in output"
+		}
+		return outcome
+
+	case a.Regex != "":
+		outcome := Outcome{Assertion: fmt.Sprintf("regex %q", a.Regex)}
+		outcome.Pass = regexp.MustCompile(a.Regex).MatchString(output)
+		if !outcome.Pass {
+			outcome.Detail = "no match in output"
+		}
+		return outcome
+
+	case a.JSONSchema != nil:
+		outcome := Outcome{Assertion: "json_schema"}
+		var value any
+		if err := json.Unmarshal([]byte(stripFences(output)), &value); err != nil {
+			outcome.Detail = fmt.Sprintf("output is not JSON: %v", err)
+			return outcome
+		}
+		if err := Validate(a.JSONSchema.(map[string]any), value); err != nil {
+			outcome.Detail = err.Error()
+			return outcome
+		}
+		outcome.Pass = true
+		return outcome
+
+	case a.MaxLatency > 0:
+		limit := time.Duration(a.MaxLatency)
+		outcome := Outcome{Assertion: fmt.Sprintf("max_latency %s", limit)}
+		outcome.Pass = latency <= limit
+		if !outcome.Pass {
+			outcome.Detail = fmt.Sprintf("took %s", latency.Round(time.Millisecond))
+		}
+		return outcome
+
+	default:
+		outcome := Outcome{Assertion: fmt.Sprintf("judge %q", truncate(a.Judge, 40))}
+		pass, reason, err := judge(ctx, a.Judge, prompt, output)
+		if err != nil {
+			outcome.Detail = fmt.Sprintf("judge failed: %v", err)
+			return outcome
+		}
+		outcome.Pass = pass
+		if !pass {
+			outcome.Detail = reason
+		}
+		return outcome
+	}
+}
+
+// JudgeSystemPrompt instructs the judge model.
+const JudgeSystemPrompt = "You grade responses from an AI assistant against a rubric. " +
+	"Decide strictly whether the response satisfies every point of the rubric. " +
+	`Respond with JSON of the form {"pass": true, "reason": "one sentence"}.`
+
+// JudgeSchema is the JSON schema of the judge's verdict.
+var JudgeSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "pass": {"type": "boolean"},
+    "reason": {"type": "string"}
+  },
+  "required": ["pass", "reason"]
+}`)
+
+// JudgePrompt builds the prompt sent to the judge model.
+func JudgePrompt(rubric, prompt, output string) string {
+	return fmt.Sprintf("Rubric:\n%s\n\nPrompt given to the assistant:\n%s\n\nResponse to grade:\n%s", rubric, prompt, output)
+}
+
+// ParseVerdict decodes the judge model's verdict.
+func ParseVerdict(response string) (bool, string, error) {
+	var verdict struct {
+		Pass   bool   `json:"pass"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(stripFences(response)), &verdict); err != nil {
+		return false, "", fmt.Errorf("invalid verdict: %w", err)
+	}
+	return verdict.Pass, verdict.Reason, nil
+}
+
+// stripFences removes a Markdown code fence around JSON output
+func stripFences(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if i := strings.Index(text, "\n"); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// truncate shortens a string to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
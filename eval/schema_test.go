@@ -0,0 +1,148 @@
+package eval
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// personSchema is written in YAML, as in a suite file, so its numbers
+// decode as ints while the values checked against it are JSON float64s
+const personSchema = `
+type: object
+required: [name, age]
+additionalProperties: false
+properties:
+  name:
+    type: string
+    minLength: 2
+    maxLength: 10
+  age:
+    type: integer
+    minimum: 0
+    maximum: 150
+  score:
+    type: number
+    minimum: 0.5
+  role:
+    enum: [admin, user, 3]
+  active:
+    type: [boolean, "null"]
+  version:
+    const: 2
+  tags:
+    type: array
+    minItems: 1
+    maxItems: 3
+    items:
+      type: string
+  address:
+    type: object
+    required: [city]
+    properties:
+      city:
+        type: string
+      lines:
+        type: array
+        items:
+          type: object
+          required: [text]
+`
+
+func TestValidate(t *testing.T) {
+	var schema map[string]any
+	if err := yaml.Unmarshal([]byte(personSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string // JSON
+		wantErr string // Substring of the error, empty for valid values
+	}{
+		{name: "minimal", value: `{"name":"Ada","age":36}`},
+		{name: "everything", value: `{"name":"Ada","age":36,"score":0.5,"role":"admin","active":null,"version":2,` +
+			`"tags":["a","b"],"address":{"city":"London","lines":[{"text":"1 Main St"}]}}`},
+		{name: "integral float is an integer", value: `{"name":"Ada","age":36.0}`},
+		{name: "YAML int in enum matches JSON number", value: `{"name":"Ada","age":1,"role":3}`},
+		{name: "YAML int const matches JSON number", value: `{"name":"Ada","age":1,"version":2.0}`},
+		{name: "type list", value: `{"name":"Ada","age":1,"active":true}`},
+
+		{name: "not an object", value: `[1]`, wantErr: "$: expected object, got array"},
+		{name: "missing required", value: `{"name":"Ada"}`, wantErr: "$: missing required property 'age'"},
+		{name: "additional property", value: `{"name":"Ada","age":1,"extra":1}`, wantErr: "$: unexpected property 'extra'"},
+		{name: "wrong type", value: `{"name":7,"age":1}`, wantErr: "$.name: expected string, got number"},
+		{name: "fraction is not an integer", value: `{"name":"Ada","age":1.5}`, wantErr: "$.age: expected integer, got number"},
+		{name: "below minimum", value: `{"name":"Ada","age":-1}`, wantErr: "$.age: -1 is less than the minimum 0"},
+		{name: "above maximum", value: `{"name":"Ada","age":200}`, wantErr: "$.age: 200 is greater than the maximum 150"},
+		{name: "float minimum", value: `{"name":"Ada","age":1,"score":0.4}`, wantErr: "$.score: 0.4 is less than the minimum 0.5"},
+		{name: "too short", value: `{"name":"A","age":1}`, wantErr: "$.name: expected at least 2 characters"},
+		{name: "length counts characters", value: `{"name":"ÀÉÎÕÜÀÉÎÕÜ","age":1}`},
+		{name: "too long", value: `{"name":"Adalovelace","age":1}`, wantErr: "$.name: expected at most 10 characters"},
+		{name: "not in enum", value: `{"name":"Ada","age":1,"role":"root"}`, wantErr: "$.role: value is not one of"},
+		{name: "not the const", value: `{"name":"Ada","age":1,"version":3}`, wantErr: "$.version: value must be 2"},
+		{name: "type list mismatch", value: `{"name":"Ada","age":1,"active":"yes"}`, wantErr: "$.active: expected boolean or null, got string"},
+		{name: "too few items", value: `{"name":"Ada","age":1,"tags":[]}`, wantErr: "$.tags: expected at least 1 items"},
+		{name: "too many items", value: `{"name":"Ada","age":1,"tags":["a","b","c","d"]}`, wantErr: "$.tags: expected at most 3 items"},
+		{name: "bad item", value: `{"name":"Ada","age":1,"tags":["a",2]}`, wantErr: "$.tags[1]: expected string, got number"},
+		{name: "nested required", value: `{"name":"Ada","age":1,"address":{}}`, wantErr: "$.address: missing required property 'city'"},
+		{name: "nested items", value: `{"name":"Ada","age":1,"address":{"city":"x","lines":[{}]}}`, wantErr: "$.address.lines[0]: missing required property 'text'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := Validate(schema, value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value any
+		want  float64
+		ok    bool
+	}{
+		{3, 3, true},
+		{int64(4), 4, true},
+		{2.5, 2.5, true},
+		{"3", 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := number(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("number(%#v) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a longer string", 10, "a longe..."},
+		{"ééééééééééééé", 10, "ééééééé..."},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of prompt test cases loaded from YAML.
+type Suite struct {
+	Models  []string       `yaml:"models"`  // Models every case runs against unless it lists its own
+	System  string         `yaml:"system"`  // Default system prompt
+	Options map[string]any `yaml:"options"` // Default generation options
+	Judge   string         `yaml:"judge"`   // Model that grades judge assertions
+	Cases   []Case         `yaml:"cases"`
+}
+
+// Case is a single prompt and the assertions its output must pass.
+type Case struct {
+	Name    string         `yaml:"name"`
+	Prompt  string         `yaml:"prompt"`
+	System  string         `yaml:"system"`
+	Models  []string       `yaml:"models"`
+	Options map[string]any `yaml:"options"`
+	Assert  []Assertion    `yaml:"assert"`
+}
+
+// Assertion is one check on a case's output. Exactly one field is set.
+type Assertion struct {
+	Contains   string   `yaml:"contains"`
+	Regex      string   `yaml:"regex"`
+	JSONSchema any      `yaml:"json_schema"`
+	MaxLatency Duration `yaml:"max_latency"`
+	Judge      string   `yaml:"judge"` // Rubric the judge model grades the output against
+}
+
+// Duration is a time.Duration written as "5s" or "1m30s" in YAML.
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load reads and validates a suite file.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	if err := suite.validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	return &suite, nil
+}
+
+func (s *Suite) validate() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	seen := make(map[string]bool)
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("duplicate case name '%s'", c.Name)
+		}
+		seen[c.Name] = true
+
+		if strings.TrimSpace(c.Prompt) == "" {
+			return fmt.Errorf("%s: missing prompt", c.Name)
+		}
+		if len(c.Assert) == 0 {
+			return fmt.Errorf("%s: no assertions", c.Name)
+		}
+		for j, a := range c.Assert {
+			if err := a.validate(); err != nil {
+				return fmt.Errorf("%s: assertion %d: %w", c.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a Assertion) validate() error {
+	set := 0
+	for _, ok := range []bool{a.Contains != "", a.Regex != "", a.JSONSchema != nil, a.MaxLatency > 0, a.Judge != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("set exactly one of contains, regex, json_schema, max_latency or judge")
+	}
+	if a.Regex != "" {
+		if _, err := regexp.Compile(a.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	if a.JSONSchema != nil {
+		if _, ok := a.JSONSchema.(map[string]any); !ok {
+			return fmt.Errorf("json_schema must be a mapping")
+		}
+	}
+	return nil
+}
+
+// ModelsFor returns the models a case runs against. override, when set,
+// replaces the models in the suite.
+func (s *Suite) ModelsFor(c Case, override []string) []string {
+	switch {
+	case len(override) > 0:
+		return override
+	case len(c.Models) > 0:
+		return c.Models
+	default:
+		return s.Models
+	}
+}
+
+// SystemFor returns the system prompt for a case.
+func (s *Suite) SystemFor(c Case) string {
+	if c.System != "" {
+		return c.System
+	}
+	return s.System
+}
+
+// OptionsFor merges the suite and case generation options.
+func (s *Suite) OptionsFor(c Case) map[string]any {
+	options := make(map[string]any, len(s.Options)+len(c.Options))
+	for k, v := range s.Options {
+		options[k] = v
+	}
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	return options
+}
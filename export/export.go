@@ -0,0 +1,283 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/hariharen9/lamacli/chathistory"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	JSON     Format = "json"
+	Text     Format = "text"
+)
+
+// Formats lists the export formats. Their first letters are distinct, so
+// the TUI uses them as keys.
+var Formats = []Format{Markdown, HTML, JSON, Text}
+
+// FormatForKey returns the format picked by a key press in the TUI.
+func FormatForKey(key string) (Format, bool) {
+	for _, format := range Formats {
+		if key == string(format[0]) {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// KeyHint describes the format keys for the TUI.
+func KeyHint() string {
+	hints := make([]string, len(Formats))
+	for i, format := range Formats {
+		hints[i] = fmt.Sprintf("%c: %s", format[0], format)
+	}
+	return strings.Join(hints, " • ")
+}
+
+// ParseFormat parses a format name or file extension.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "markdown", "md":
+		return Markdown, nil
+	case "html", "htm":
+		return HTML, nil
+	case "json":
+		return JSON, nil
+	case "text", "txt":
+		return Text, nil
+	}
+	return "", fmt.Errorf("unknown export format '%s' (use markdown, html, json or text)", name)
+}
+
+// Extension returns the file extension of the format, including the dot.
+func (f Format) Extension() string {
+	switch f {
+	case Markdown:
+		return ".md"
+	case Text:
+		return ".txt"
+	}
+	return "." + string(f)
+}
+
+// Message is a message of an exported conversation.
+type Message struct {
+	Role    string // "user" or "assistant"
+	Content string
+}
+
+// Messages returns the messages of a session worth exporting: empty
+// messages and the TUI's welcome greeting, which answers an empty prompt,
+// are left out.
+func Messages(session *chathistory.ChatSession) []Message {
+	var messages []Message
+	for i, content := range session.History {
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if i == 1 && session.History[0] == "" {
+			continue // Welcome message
+		}
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, Message{Role: role, Content: content})
+	}
+	return messages
+}
+
+// Title returns the session's title, or a fallback for unsaved chats.
+func Title(session *chathistory.ChatSession) string {
+	if title := strings.TrimSpace(session.Title); title != "" {
+		return title
+	}
+	for _, message := range Messages(session) {
+		if message.Role == "user" {
+			return truncate(strings.Join(strings.Fields(message.Content), " "), 50)
+		}
+	}
+	return "LamaCLI Chat"
+}
+
+// Render renders a session in the given format.
+func Render(session *chathistory.ChatSession, format Format) ([]byte, error) {
+	switch format {
+	case Markdown:
+		return []byte(RenderMarkdown(session)), nil
+	case HTML:
+		return RenderHTML(session)
+	case JSON:
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal session: %w", err)
+		}
+		return append(data, '\n'), nil
+	case Text:
+		return []byte(RenderText(session)), nil
+	}
+	return nil, fmt.Errorf("unknown export format '%s'", format)
+}
+
+// RenderMarkdown renders a session as a Markdown transcript with a heading
+// per message. Files added to a prompt as context are fenced as code.
+func RenderMarkdown(session *chathistory.ChatSession) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", Title(session))
+	if meta := metadata(session); meta != "" {
+		fmt.Fprintf(&b, "*%s*\n\n", meta)
+	}
+
+	for _, message := range Messages(session) {
+		if message.Role == "user" {
+			b.WriteString("## 👤 You\n\n")
+		} else {
+			b.WriteString("## 🤖 Assistant\n\n")
+		}
+		b.WriteString(strings.TrimSpace(markdownContent(message.Content)))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// RenderText renders a session as plain text.
+func RenderText(session *chathistory.ChatSession) string {
+	var b strings.Builder
+	title := Title(session)
+	fmt.Fprintf(&b, "%s\n%s\n", title, strings.Repeat("=", len([]rune(title))))
+	if meta := metadata(session); meta != "" {
+		fmt.Fprintf(&b, "%s\n", meta)
+	}
+
+	for _, message := range Messages(session) {
+		if message.Role == "user" {
+			b.WriteString("\nYou:\n")
+		} else {
+			b.WriteString("\nAssistant:\n")
+		}
+		b.WriteString(strings.TrimSpace(message.Content))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// metadata returns the model and date of a session, as far as known
+func metadata(session *chathistory.ChatSession) string {
+	var parts []string
+	if session.Model != "" {
+		parts = append(parts, session.Model)
+	}
+	if !session.UpdatedAt.IsZero() {
+		parts = append(parts, session.UpdatedAt.Format("Jan 2, 2006 15:04"))
+	}
+	return strings.Join(parts, " • ")
+}
+
+// fileContextPattern matches a file the TUI added to a prompt as context
+var fileContextPattern = regexp.MustCompile(`(?s)--- Start of File: (.+?) ---\n(.*?)\n?--- End of File ---`)
+
+// markdownContent fences files added as context and closes a code fence
+// left open by a cut-off reply, so it cannot swallow the next messages
+func markdownContent(content string) string {
+	content = fileContextPattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := fileContextPattern.FindStringSubmatch(match)
+		name, code := parts[1], parts[2]
+		lang := ""
+		if lexer := lexers.Match(name); lexer != nil && len(lexer.Config().Aliases) > 0 {
+			lang = lexer.Config().Aliases[0]
+		}
+		fence := codeFence(code)
+		return fmt.Sprintf("\n**📄 %s**\n\n%s%s\n%s\n%s", name, fence, lang, code, fence)
+	})
+
+	open := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case open == "" && strings.HasPrefix(trimmed, "```"):
+			open = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+		case open != "" && strings.TrimRight(trimmed, "`") == "" && len(trimmed) >= len(open):
+			open = ""
+		}
+	}
+	if open != "" {
+		content = strings.TrimRight(content, "\n") + "\n" + open
+	}
+	return content
+}
+
+// codeFence returns a backtick fence longer than any in the code
+func codeFence(code string) string {
+	longest := 0
+	for _, line := range strings.Split(code, "\n") {
+		trimmed := strings.TrimSpace(line)
+		longest = max(longest, len(trimmed)-len(strings.TrimLeft(trimmed, "`")))
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// FileName returns a file name for exporting a session, made from its title
+func FileName(session *chathistory.ChatSession, format Format) string {
+	slug := []rune(strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(Title(session)), "-"), "-"))
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	name := strings.TrimRight(string(slug), "-")
+	if name == "" {
+		name = "lamacli-chat"
+	}
+	return name + format.Extension()
+}
+
+var nonSlug = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// WriteFile exports a session into a directory without overwriting existing
+// files, and returns the path written.
+func WriteFile(session *chathistory.ChatSession, format Format, dir string) (string, error) {
+	data, err := Render(session, format)
+	if err != nil {
+		return "", err
+	}
+
+	name := FileName(session, format)
+	base := strings.TrimSuffix(name, format.Extension())
+	for i := 2; ; i++ {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			name = fmt.Sprintf("%s-%d%s", base, i, format.Extension())
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write export: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to write export: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("failed to write export: %w", err)
+		}
+		return path, nil
+	}
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hariharen9/lamacli/chathistory"
+)
+
+func TestRenderHTMLEscapes(t *testing.T) {
+	session := &chathistory.ChatSession{
+		Title: "<i>Tags</i> & more",
+		History: []string{
+			"What does <b>this</b> do?",
+			"<script>alert('block')</script>\n\nInline <img src=x onerror=alert(1)> tag.\n\n" +
+				"```html\n<script>alert('code')</script>\n```",
+		},
+	}
+	out, err := RenderHTML(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(out)
+
+	for _, raw := range []string{"<script", "<img", "<b>", "<i>"} {
+		if strings.Contains(page, raw) {
+			t.Errorf("page contains unescaped %q", raw)
+		}
+	}
+	for _, escaped := range []string{
+		"&lt;i&gt;Tags&lt;/i&gt; &amp; more",
+		"&lt;b&gt;this&lt;/b&gt;",
+		"&lt;script&gt;alert(&#39;block&#39;)&lt;/script&gt;",
+		"&lt;img src=x onerror=alert(1)&gt;",
+	} {
+		if !strings.Contains(page, escaped) {
+			t.Errorf("page does not contain %q", escaped)
+		}
+	}
+	// Highlighting splits code into spans, so look for it in the text
+	if !strings.Contains(stripTags(page), "&lt;script&gt;alert(&#39;code&#39;)&lt;/script&gt;") {
+		t.Error("code block is missing or unescaped")
+	}
+}
+
+// stripTags removes the tags from HTML, leaving its text
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func TestRenderMarkdownCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "fenced code is kept",
+			content: "Run this:\n\n```go\nfunc main() {\n\tfmt.Println(\"<hi>\")\n}\n```\n\nDone.",
+			want:    "Run this:\n\n```go\nfunc main() {\n\tfmt.Println(\"<hi>\")\n}\n```\n\nDone.",
+		},
+		{
+			name:    "cut-off fence is closed",
+			content: "```python\nprint(1)",
+			want:    "```python\nprint(1)\n```",
+		},
+		{
+			name:    "file context is fenced longer than its code",
+			content: "--- Start of File: README.md ---\nUse:\n```sh\nmake\n```\n--- End of File ---\nExplain it",
+			want:    "**📄 README.md**\n\n````md\nUse:\n```sh\nmake\n```\n````\nExplain it",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &chathistory.ChatSession{Title: "Code", History: []string{tt.content, "ok"}}
+			out := RenderMarkdown(session)
+			want := "# Code\n\n## 👤 You\n\n" + tt.want + "\n\n## 🤖 Assistant\n\nok\n\n"
+			if out != want {
+				t.Errorf("RenderMarkdown() =\n%s\nwant\n%s", out, want)
+			}
+		})
+	}
+}
+
+func TestMessages(t *testing.T) {
+	session := &chathistory.ChatSession{History: []string{"", "Welcome!", "Hi", "Hello", "  ", "lost"}}
+	got := Messages(session)
+	want := []Message{{"user", "Hi"}, {"assistant", "Hello"}, {"assistant", "lost"}}
+	if len(got) != len(want) {
+		t.Fatalf("Messages() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Messages()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
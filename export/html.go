@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Chroma styles for code in the light and dark color schemes
+const (
+	lightCodeStyle = "github"
+	darkCodeStyle  = "monokai"
+)
+
+// pageCSS styles the exported page. Code highlighting is appended from the
+// chroma styles.
+const pageCSS = `
+:root { color-scheme: light dark; --bg: #ffffff; --fg: #1f2328; --muted: #656d76; --border: #d0d7de; --user: #f6f8fa; --code: #f6f8fa; --accent: #8250df; }
+@media (prefers-color-scheme: dark) {
+  :root { --bg: #1e1e2e; --fg: #cdd6f4; --muted: #a6adc8; --border: #45475a; --user: #313244; --code: #272822; --accent: #cba6f7; }
+}
+* { box-sizing: border-box; }
+body { margin: 0; background: var(--bg); color: var(--fg); font: 16px/1.6 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; }
+main { max-width: 860px; margin: 0 auto; padding: 2rem 1rem 4rem; }
+header { border-bottom: 1px solid var(--border); margin-bottom: 1.5rem; }
+header h1 { margin: 0 0 0.25rem; }
+.meta { color: var(--muted); font-size: 0.9rem; margin: 0 0 1rem; }
+.message { margin: 1.25rem 0; padding: 0.75rem 1.25rem; border: 1px solid var(--border); border-radius: 10px; }
+.message.user { background: var(--user); }
+.role { margin: 0 0 0.5rem; font-size: 0.85rem; font-weight: 600; text-transform: uppercase; letter-spacing: 0.05em; color: var(--accent); }
+.message > .content > :first-child { margin-top: 0; }
+.message > .content > :last-child { margin-bottom: 0; }
+pre { background: var(--code); padding: 0.75rem 1rem; border-radius: 6px; overflow-x: auto; font-size: 0.875rem; line-height: 1.45; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+:not(pre) > code { background: var(--code); padding: 0.1em 0.35em; border-radius: 4px; font-size: 0.875em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid var(--border); padding: 0.35rem 0.75rem; }
+.raw { white-space: pre-wrap; }
+blockquote { margin: 0; padding-left: 1rem; border-left: 3px solid var(--border); color: var(--muted); }
+a { color: var(--accent); }
+footer { color: var(--muted); font-size: 0.8rem; text-align: center; margin-top: 3rem; }
+`
+
+// RenderHTML renders a session as a self-contained HTML page, with the
+// messages' Markdown rendered and code blocks syntax highlighted.
+func RenderHTML(session *chathistory.ChatSession) ([]byte, error) {
+	css, err := codeCSS()
+	if err != nil {
+		return nil, err
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(&messageRenderer{}, 100)),
+		),
+	)
+
+	title := html.EscapeString(Title(session))
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s%s</style>\n</head>\n<body>\n<main>\n", title, pageCSS, css)
+	fmt.Fprintf(&b, "<header>\n<h1>%s</h1>\n", title)
+	if meta := metadata(session); meta != "" {
+		fmt.Fprintf(&b, "<p class=\"meta\">%s</p>\n", html.EscapeString(meta))
+	}
+	b.WriteString("</header>\n")
+
+	for _, message := range Messages(session) {
+		role := "You"
+		if message.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "<section class=\"message %s\">\n<p class=\"role\">%s</p>\n<div class=\"content\">\n", message.Role, role)
+		if err := md.Convert([]byte(markdownContent(message.Content)), &b); err != nil {
+			return nil, fmt.Errorf("failed to render message: %w", err)
+		}
+		b.WriteString("</div>\n</section>\n")
+	}
+
+	b.WriteString("<footer>Exported from LamaCLI</footer>\n</main>\n</body>\n</html>\n")
+	return b.Bytes(), nil
+}
+
+// codeCSS returns the highlighting CSS for both color schemes
+func codeCSS() (string, error) {
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	var light, dark bytes.Buffer
+	if err := formatter.WriteCSS(&light, styles.Get(lightCodeStyle)); err != nil {
+		return "", err
+	}
+	if err := formatter.WriteCSS(&dark, styles.Get(darkCodeStyle)); err != nil {
+		return "", err
+	}
+	return light.String() + "@media (prefers-color-scheme: dark) {\n" + dark.String() + "}\n", nil
+}
+
+// messageRenderer renders fenced code blocks highlighted with chroma, and
+// HTML in messages as text: models often mention tags outside of code, and
+// the page must not run or hide them.
+type messageRenderer struct{}
+
+func (r *messageRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+}
+
+func (r *messageRenderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.HTMLBlock)
+	if entering {
+		w.WriteString("<p class=\"raw\">")
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			w.WriteString(html.EscapeString(string(line.Value(source))))
+		}
+	} else {
+		if n.HasClosure() {
+			w.WriteString(html.EscapeString(string(n.ClosureLine.Value(source))))
+		}
+		w.WriteString("</p>\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *messageRenderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*ast.RawHTML)
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			w.WriteString(html.EscapeString(string(segment.Value(source))))
+		}
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *messageRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	var lexer chroma.Lexer
+	if language := n.Language(source); language != nil {
+		lexer = lexers.Get(string(language))
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err == nil {
+		formatter := chromahtml.New(chromahtml.WithClasses(true))
+		if err = formatter.Format(w, styles.Get(lightCodeStyle), iterator); err == nil {
+			return ast.WalkSkipChildren, nil
+		}
+	}
+
+	// Fall back to plain, escaped code
+	fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", html.EscapeString(code.String()))
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,275 @@
+package filecontext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Strategy decides which files are included first when the token budget
+// cannot fit them all.
+type Strategy string
+
+const (
+	StrategyPath     Strategy = "path"     // Alphabetical by path
+	StrategySmallest Strategy = "smallest" // Smallest files first, fitting as many files as possible
+	StrategyRecent   Strategy = "recent"   // Most recently modified files first
+)
+
+// Defaults used when Options leaves a limit unset.
+const (
+	DefaultMaxTokens   = 2500
+	DefaultMaxFileSize = 100 * 1024
+)
+
+// Skip reasons reported in Result.Skipped.
+const (
+	ReasonBinary     = "binary"
+	ReasonTooLarge   = "too large"
+	ReasonOverBudget = "over budget"
+	ReasonUnreadable = "unreadable"
+)
+
+// Options configures how context is collected.
+type Options struct {
+	Root        string
+	Include     []string // doublestar patterns; when set, only matching files are included
+	Exclude     []string // doublestar patterns for files to leave out
+	MaxTokens   int      // Token budget for the whole context
+	MaxFileSize int64    // Files larger than this many bytes are skipped
+	Strategy    Strategy
+}
+
+// File is a file included in the context.
+type File struct {
+	Path    string // Slash-separated path relative to the root
+	Size    int64
+	Tokens  int
+	ModTime time.Time
+}
+
+// SkippedFile is a candidate file that was left out of the context.
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// Result is the collected context along with what went into it.
+type Result struct {
+	Content   string
+	Included  []File
+	Skipped   []SkippedFile
+	Tokens    int
+	MaxTokens int
+}
+
+// EstimateTokens approximates the token count of text, at roughly four
+// bytes per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// ParseStrategy validates a strategy name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch Strategy(name) {
+	case "":
+		return StrategyPath, nil
+	case StrategyPath, StrategySmallest, StrategyRecent:
+		return Strategy(name), nil
+	default:
+		return "", fmt.Errorf("unknown context strategy '%s'. Please use 'path', 'smallest' or 'recent'", name)
+	}
+}
+
+// Build walks opts.Root and collects file contents up to the token budget.
+// It honours .gitignore and .lamacliignore files, skips hidden entries,
+// binaries and large files, and fills the budget in strategy order.
+func Build(opts Options) (*Result, error) {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{MaxTokens: opts.MaxTokens}
+	candidates, err := collect(root, opts, result)
+	if err != nil {
+		return nil, err
+	}
+	order(candidates, opts.Strategy)
+
+	var content strings.Builder
+	for _, file := range candidates {
+		remaining := opts.MaxTokens - result.Tokens
+		// Skip files that cannot fit before reading them
+		if EstimateTokens(file.Path)+int(file.Size/4) > remaining {
+			result.skip(file.Path, ReasonOverBudget)
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file.Path)))
+		if err != nil {
+			result.skip(file.Path, ReasonUnreadable)
+			continue
+		}
+		if isBinary(data) {
+			result.skip(file.Path, ReasonBinary)
+			continue
+		}
+
+		section := fmt.Sprintf("\n--- File: %s ---\n%s\n", file.Path, data)
+		file.Tokens = EstimateTokens(section)
+		if file.Tokens > remaining {
+			result.skip(file.Path, ReasonOverBudget)
+			continue
+		}
+
+		content.WriteString(section)
+		result.Tokens += file.Tokens
+		result.Included = append(result.Included, file)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// collect walks the root and returns the files that pass the ignore
+// rules, the include/exclude patterns and the size limit.
+func collect(root string, opts Options, result *Result) ([]File, error) {
+	var files []File
+
+	err := Walk(root, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAny(opts.Include, rel) {
+			return nil
+		}
+		if matchAny(opts.Exclude, rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			result.skip(rel, ReasonUnreadable)
+			return nil
+		}
+		if info.Size() > opts.MaxFileSize {
+			result.skip(rel, ReasonTooLarge)
+			return nil
+		}
+
+		files = append(files, File{Path: rel, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return files, err
+}
+
+// Walk calls fn for the directories and files below root, skipping hidden
+// entries and those ignored by .gitignore and .lamacliignore files. fn is
+// given the entry's slash-separated path relative to root; it may return
+// filepath.SkipDir to skip a directory or filepath.SkipAll to stop.
+func Walk(root string, fn func(rel string, d fs.DirEntry) error) error {
+	matcher := &ignoreMatcher{}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // Skip entries that can't be read
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if path == root {
+			matcher.load(root, rel)
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), ".") || matcher.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := fn(rel, d); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			matcher.load(root, rel)
+		}
+		return nil
+	})
+}
+
+// order sorts candidate files by the strategy's priority.
+func order(files []File, strategy Strategy) {
+	switch strategy {
+	case StrategySmallest:
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Size < files[j].Size })
+	case StrategyRecent:
+		sort.SliceStable(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	default:
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	}
+}
+
+// isBinary reports whether data looks like a binary file: it contains a
+// NUL byte or is not valid UTF-8 within the first few kilobytes.
+func isBinary(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+		// Don't let a multi-byte character cut in half count as invalid
+		for i := 0; i < utf8.UTFMax-1 && len(sample) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(sample); r != utf8.RuneError || size != 1 {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return bytes.IndexByte(sample, 0) >= 0 || !utf8.Valid(sample)
+}
+
+func (r *Result) skip(path, reason string) {
+	r.Skipped = append(r.Skipped, SkippedFile{Path: path, Reason: reason})
+}
+
+// WriteSummary prints what was included in and left out of the context.
+func (r *Result) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "📦 Context: %d file(s), ~%d of %d tokens\n", len(r.Included), r.Tokens, r.MaxTokens)
+	for _, file := range r.Included {
+		fmt.Fprintf(w, "   + %s (~%d tokens)\n", file.Path, file.Tokens)
+	}
+
+	if len(r.Skipped) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	var reasons []string
+	for _, skipped := range r.Skipped {
+		if counts[skipped.Reason] == 0 {
+			reasons = append(reasons, skipped.Reason)
+		}
+		counts[skipped.Reason]++
+	}
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	fmt.Fprintf(w, "   Skipped: %s\n", strings.Join(parts, ", "))
+}
@@ -0,0 +1,119 @@
+package filecontext
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreFiles are the per-directory ignore files that are honoured, in the
+// order their rules are applied.
+var ignoreFiles = []string{".gitignore", ".lamacliignore"}
+
+// ignoreRule is a single pattern from an ignore file.
+type ignoreRule struct {
+	base    string // Slash-separated directory of the ignore file, relative to the root
+	pattern string // doublestar pattern relative to base
+	negate  bool   // Pattern started with "!"
+	dirOnly bool   // Pattern ended with "/"
+}
+
+// ignoreMatcher evaluates gitignore-style rules collected while walking.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// load reads the ignore files in dir, a directory relative to the root.
+func (m *ignoreMatcher) load(root, dir string) {
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(root, filepath.FromSlash(dir), name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(dir, scanner.Text()); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+		f.Close()
+	}
+}
+
+// parseIgnoreLine converts a line from an ignore file into a rule.
+func parseIgnoreLine(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern containing a slash is anchored to the ignore file's
+	// directory; otherwise it matches at any depth below it.
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// ignored reports whether rel, a slash-separated path relative to the
+// root, is ignored. The last matching rule wins, as in git.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if rule.base != "." {
+			prefix := rule.base + "/"
+			if !strings.HasPrefix(rel, prefix) {
+				continue
+			}
+			target = strings.TrimPrefix(rel, prefix)
+		}
+
+		if ok, _ := doublestar.Match(rule.pattern, target); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchAny reports whether rel matches any of the doublestar patterns.
+// Patterns without a slash also match the base name, so "*.md" matches
+// Markdown files at any depth.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := doublestar.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package filecontext
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// matcherOf builds a matcher from ignore file lines keyed by the
+// directory of their ignore file
+func matcherOf(files map[string][]string, order []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, base := range order {
+		for _, line := range files[base] {
+			if rule, ok := parseIgnoreLine(base, line); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+	}
+	return m
+}
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignoreRule
+		ok   bool
+	}{
+		{line: "", ok: false},
+		{line: "# comment", ok: false},
+		{line: "   ", ok: false},
+		{line: "/", ok: false},
+		{line: "*.log", want: ignoreRule{base: ".", pattern: "**/*.log"}, ok: true},
+		{line: "*.log  ", want: ignoreRule{base: ".", pattern: "**/*.log"}, ok: true},
+		{line: "/build", want: ignoreRule{base: ".", pattern: "build"}, ok: true},
+		{line: "docs/*.md", want: ignoreRule{base: ".", pattern: "docs/*.md"}, ok: true},
+		{line: "node_modules/", want: ignoreRule{base: ".", pattern: "**/node_modules", dirOnly: true}, ok: true},
+		{line: "!keep.log", want: ignoreRule{base: ".", pattern: "**/keep.log", negate: true}, ok: true},
+		{line: `\#notes`, want: ignoreRule{base: ".", pattern: "**/#notes"}, ok: true},
+		{line: `\!bang`, want: ignoreRule{base: ".", pattern: "**/!bang"}, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreLine(".", tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseIgnoreLine(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	files := map[string][]string{
+		".": {
+			"*.log",
+			"!keep.log",
+			"/build",
+			"docs/*.md",
+			"tmp/",
+			"**/generated/**",
+			"secrets/**/*.key",
+		},
+		"web": {
+			"dist",
+			"/local.js",
+			"!debug.log",
+		},
+	}
+	m := matcherOf(files, []string{".", "web"})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// Unanchored patterns match at any depth
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"app.go", false, false},
+		// Negation re-includes, the last matching rule winning
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		// A leading slash anchors to the ignore file's directory
+		{"build", true, true},
+		{"build", false, true},
+		{"src/build", true, false},
+		// A slash in the middle anchors too
+		{"docs/readme.md", false, true},
+		{"docs/api/readme.md", false, false},
+		{"other/docs/readme.md", false, false},
+		// Directory-only patterns
+		{"tmp", true, true},
+		{"a/tmp", true, true},
+		{"tmp", false, false},
+		// Double stars
+		{"generated/x.go", false, true},
+		{"a/generated/b/x.go", false, true},
+		{"secrets/prod.key", false, true},
+		{"secrets/a/b/prod.key", false, true},
+		{"secrets/prod.txt", false, false},
+		// Nested ignore files apply below their directory only
+		{"web/dist", true, true},
+		{"web/app/dist", true, true},
+		{"dist", true, false},
+		{"web/local.js", false, true},
+		{"web/app/local.js", false, false},
+		{"local.js", false, false},
+		{"web/debug.log", false, false},
+		{"debug.log", false, true},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{[]string{"*.md"}, "README.md", true},
+		{[]string{"*.md"}, "docs/guide/intro.md", true},
+		{[]string{"docs/*.md"}, "docs/intro.md", true},
+		{[]string{"docs/*.md"}, "docs/guide/intro.md", false},
+		{[]string{"docs/**"}, "docs/guide/intro.md", true},
+		{[]string{"*.go", "*.md"}, "main.go", true},
+		{nil, "main.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchAny(tt.patterns, tt.path); got != tt.want {
+			t.Errorf("matchAny(%q, %q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBuildHonoursIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		".gitignore":             "*.log\nvendor/\n",
+		"main.go":                "package main\n",
+		"debug.log":              "noise\n",
+		"vendor/lib/lib.go":      "package lib\n",
+		".hidden/config":         "x\n",
+		"web/.lamacliignore":     "dist/\n!important.log\n",
+		"web/index.js":           "run()\n",
+		"web/important.log":      "keep\n",
+		"web/dist/bundle.js":     "minified\n",
+		"web/app/dist/bundle.js": "minified\n",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Build(Options{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, file := range result.Included {
+		got = append(got, file.Path)
+	}
+	want := []string{"main.go", "web/important.log", "web/index.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("included %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// run executes git with the given arguments and returns its stdout. On
+// failure the error includes git's stderr.
+func run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// RepoRoot returns the top-level directory of the current repository.
+func RepoRoot() (string, error) {
+	out, err := run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Path resolves a path inside the git directory, such as "hooks" or
+// "MERGE_HEAD", honouring worktrees and core.hooksPath.
+func Path(name string) (string, error) {
+	out, err := run("rev-parse", "--git-path", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// StagedDiff returns the diff of the changes staged for commit.
+func StagedDiff() (string, error) {
+	return run("diff", "--staged", "--no-color")
+}
+
+// CommitWithMessageFile runs git commit -F with the given message file,
+// connected to the terminal so hooks and signing prompts work.
+func CommitWithMessageFile(path string) error {
+	cmd := exec.Command("git", "commit", "-F", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	return nil
+}
+
+// Diff runs git diff with the given arguments, showing contextLines lines
+// of unchanged code around each hunk.
+func Diff(contextLines int, args ...string) (string, error) {
+	return run(append([]string{"diff", "--no-color", fmt.Sprintf("-U%d", contextLines)}, args...)...)
+}
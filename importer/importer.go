@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hariharen9/lamacli/chathistory"
+)
+
+// Format is a chat export format that can be imported.
+type Format string
+
+const (
+	Auto      Format = "auto"
+	OpenAI    Format = "openai"    // ChatGPT conversations.json or {"messages": [...]}
+	OpenWebUI Format = "openwebui" // Open WebUI chat export
+	Markdown  Format = "markdown"  // Transcript with a heading per message
+	LamaCLI   Format = "lamacli"   // Session JSON from `lamacli history export`
+)
+
+// ParseFormat parses a format name.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return Auto, nil
+	case "openai", "chatgpt":
+		return OpenAI, nil
+	case "openwebui", "open-webui", "webui":
+		return OpenWebUI, nil
+	case "markdown", "md":
+		return Markdown, nil
+	case "lamacli", "json":
+		return LamaCLI, nil
+	}
+	return "", fmt.Errorf("unknown import format '%s' (use auto, openai, openwebui, markdown or lamacli)", name)
+}
+
+// Conversation is a conversation read from an export file. Conversations
+// that could not be converted have Err set instead of Session.
+type Conversation struct {
+	Title   string
+	Session *chathistory.ChatSession
+	Err     error
+}
+
+// message is a message of a conversation before conversion
+type message struct {
+	role    string
+	content string
+}
+
+// Read converts the conversations in an export file. With Auto the format
+// is detected from the file name and contents. modTime, the file's
+// modification time, dates conversations whose export does not record
+// when they took place.
+func Read(name string, data []byte, format Format, modTime time.Time) ([]Conversation, Format, error) {
+	if format == Auto {
+		format = Detect(name, data)
+	}
+
+	var conversations []Conversation
+	var err error
+	switch format {
+	case OpenAI:
+		conversations, err = readOpenAI(data)
+	case OpenWebUI:
+		conversations, err = readOpenWebUI(data)
+	case Markdown:
+		conversations = []Conversation{readMarkdown(data)}
+	case LamaCLI:
+		conversations, err = readLamaCLI(data)
+	default:
+		return nil, format, fmt.Errorf("unknown import format '%s'", format)
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("not a valid %s export: %w", format, err)
+	}
+
+	for _, conversation := range conversations {
+		if session := conversation.Session; session != nil && session.UpdatedAt.IsZero() {
+			session.UpdatedAt = modTime
+		}
+	}
+	return conversations, format, nil
+}
+
+// Detect guesses the format of an export file.
+func Detect(name string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown", ".txt":
+		return Markdown
+	}
+
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return Markdown
+	}
+	if list, ok := decoded.([]any); ok {
+		if len(list) == 0 {
+			return OpenAI
+		}
+		decoded = list[0]
+	}
+	object, ok := decoded.(map[string]any)
+	if !ok {
+		return OpenAI
+	}
+	switch {
+	case object["chat"] != nil:
+		return OpenWebUI
+	case object["history"] != nil && object["mapping"] == nil:
+		return LamaCLI
+	}
+	return OpenAI
+}
+
+// readLamaCLI reads a session exported by lamacli, or a list of them
+func readLamaCLI(data []byte) ([]Conversation, error) {
+	var raw []json.RawMessage
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	} else {
+		raw = []json.RawMessage{data}
+	}
+
+	conversations := make([]Conversation, len(raw))
+	for i, item := range raw {
+		session, err := chathistory.DecodeSession(item)
+		if err != nil {
+			conversations[i] = Conversation{Title: fmt.Sprintf("session %d", i+1), Err: err}
+			continue
+		}
+		conversations[i] = Conversation{Title: session.Title, Session: &chathistory.ChatSession{
+			Title:     session.Title,
+			Model:     session.Model,
+			History:   session.History,
+			Messages:  session.Messages,
+			Current:   session.Current,
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
+		}}
+	}
+	return conversations, nil
+}
+
+// convert turns the messages of a conversation into a session, validating
+// that it holds something worth continuing
+func convert(title, model string, messages []message, created, updated time.Time) Conversation {
+	title = strings.TrimSpace(title)
+	history, err := toHistory(messages)
+	if err != nil {
+		return Conversation{Title: title, Err: err}
+	}
+	if updated.IsZero() || updated.Before(created) {
+		updated = created
+	}
+	return Conversation{Title: title, Session: &chathistory.ChatSession{
+		Title:     title,
+		Model:     model,
+		History:   history,
+		CreatedAt: created,
+		UpdatedAt: updated,
+	}}
+}
+
+// toHistory converts messages into a history of alternating user and
+// assistant messages. System and tool messages are dropped and consecutive
+// messages of one role are merged.
+func toHistory(messages []message) ([]string, error) {
+	var history []string
+	hasUser := false
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.content)
+		if content == "" {
+			continue
+		}
+
+		var turn int // Position in the user/assistant alternation
+		switch strings.ToLower(msg.role) {
+		case "user", "human", "you", "me":
+			turn, hasUser = 0, true
+		case "assistant", "ai", "bot", "model", "llm", "chatgpt":
+			turn = 1
+		default:
+			continue // System prompts, tool calls and results
+		}
+
+		switch {
+		case len(history)%2 == turn:
+			history = append(history, content)
+		case len(history) == 0:
+			// Conversation opened by the assistant, as after the TUI's
+			// empty welcome prompt
+			history = append(history, "", content)
+		default:
+			history[len(history)-1] += "\n\n" + content
+		}
+	}
+
+	if !hasUser {
+		return nil, fmt.Errorf("no messages from the user")
+	}
+	if len(history)%2 == 1 {
+		history = append(history, "") // Unanswered last message
+	}
+	return history, nil
+}
+
+// unixTime converts a Unix timestamp in seconds, milliseconds,
+// microseconds or nanoseconds to a time
+func unixTime(value float64) time.Time {
+	switch {
+	case value <= 0:
+		return time.Time{}
+	case value > 1e17:
+		return time.Unix(0, int64(value))
+	case value > 1e14:
+		return time.UnixMicro(int64(value))
+	case value > 1e11:
+		return time.UnixMilli(int64(value))
+	}
+	return time.UnixMilli(int64(math.Round(value * 1000)))
+}
@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chatGPTExport is a ChatGPT conversation whose first answer was
+// regenerated. The regenerated branch runs a tool before answering.
+const chatGPTExport = `[{
+  "title": "Listing files",
+  "create_time": 1700000000.5,
+  "update_time": 1700000100,
+  "current_node": "%s",
+  "mapping": {
+    "root": {"parent": null, "children": ["sys"], "message": null},
+    "sys": {"parent": "root", "children": ["u1"], "message": {
+      "author": {"role": "system"}, "content": {"content_type": "text", "parts": ["You are ChatGPT"]},
+      "metadata": {"is_visually_hidden_from_conversation": true}}},
+    "u1": {"parent": "sys", "children": ["a1", "a2"], "message": {
+      "author": {"role": "user"}, "content": {"content_type": "text", "parts": ["How do I list files?"]}}},
+    "a1": {"parent": "u1", "children": [], "message": {
+      "author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Use dir."]}}},
+    "a2": {"parent": "u1", "children": ["t1"], "message": {
+      "author": {"role": "assistant"}, "recipient": "python", "content": {"content_type": "text", "parts": ["import os"]}}},
+    "t1": {"parent": "a2", "children": ["a3"], "message": {
+      "author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["a.txt"]}}},
+    "a3": {"parent": "t1", "children": ["u2"], "message": {
+      "author": {"role": "assistant"}, "content": {"content_type": "multimodal_text", "parts": ["Use ls.", {"asset": "image"}]}}},
+    "u2": {"parent": "a3", "children": [], "message": {
+      "author": {"role": "user"}, "content": {"content_type": "text", "parts": ["Thanks"]}}}
+  }
+}]`
+
+// completionMessages are chat completions messages with a system prompt,
+// a split user message and tool output between two answers
+const completionMessages = `[
+  {"role": "system", "content": "Be brief"},
+  {"role": "user", "content": "Part one"},
+  {"role": "user", "content": [{"type": "text", "text": "Part two"}, {"type": "image_url", "image_url": {"url": "x"}}]},
+  {"role": "assistant", "content": "Answer"},
+  {"role": "tool", "content": "42"},
+  {"role": "assistant", "content": "More"}
+]`
+
+// openWebUIExport is an Open WebUI chat whose answer was regenerated
+// with another model
+const openWebUIExport = `[{
+  "title": "Greetings",
+  "created_at": 1700000000,
+  "updated_at": 1700000300,
+  "chat": {
+    "models": ["llama3"],
+    "history": {
+      "currentId": "a2",
+      "messages": {
+        "u1": {"parentId": null, "role": "user", "content": "Hello"},
+        "a1": {"parentId": "u1", "role": "assistant", "content": "Hi", "model": "llama3"},
+        "a2": {"parentId": "u1", "role": "assistant", "content": "Hey there", "model": "mistral"}
+      }
+    },
+    "messages": [
+      {"role": "user", "content": "Hello"},
+      {"role": "assistant", "content": "Hi", "model": "llama3"}
+    ]
+  }
+}]`
+
+// markdownTranscript is a transcript as lamacli exports it, with a
+// heading inside a code block
+const markdownTranscript = "# Shell help\n\n*llama3 • Jan 2, 2024 15:04*\n\n" +
+	"## 👤 You\n\nWrite a README\n\n" +
+	"## 🤖 Assistant\n\n```markdown\n## User\nNot a new message\n```\n\n" +
+	"### User:\nThanks\n"
+
+func TestRead(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := time.UnixMilli(1700000000500)
+	markdownDate := time.Date(2024, 1, 2, 15, 4, 0, 0, time.Local)
+
+	tests := []struct {
+		name        string
+		file        string
+		data        string
+		wantFormat  Format
+		wantTitle   string
+		wantModel   string
+		wantHistory []string
+		wantCreated time.Time
+		wantUpdated time.Time
+	}{
+		{
+			name:        "ChatGPT branch at current_node",
+			file:        "conversations.json",
+			data:        fmt.Sprintf(chatGPTExport, "a1"),
+			wantFormat:  OpenAI,
+			wantTitle:   "Listing files",
+			wantHistory: []string{"How do I list files?", "Use dir."},
+			wantCreated: created,
+			wantUpdated: time.Unix(1700000100, 0),
+		},
+		{
+			name:        "ChatGPT latest branch without current_node",
+			file:        "conversations.json",
+			data:        fmt.Sprintf(chatGPTExport, ""),
+			wantFormat:  OpenAI,
+			wantTitle:   "Listing files",
+			wantHistory: []string{"How do I list files?", "Use ls.", "Thanks", ""},
+			wantCreated: created,
+			wantUpdated: time.Unix(1700000100, 0),
+		},
+		{
+			name:        "bare list of chat completions messages",
+			file:        "chat.json",
+			data:        completionMessages,
+			wantFormat:  OpenAI,
+			wantHistory: []string{"Part one\n\nPart two", "Answer\n\nMore"},
+			wantUpdated: modTime, // Undated, so dated by the file
+		},
+		{
+			name:        "chat completions request",
+			file:        "chat.json",
+			data:        `{"title": "Parts", "created": 1700000000, "messages": ` + completionMessages + `}`,
+			wantFormat:  OpenAI,
+			wantTitle:   "Parts",
+			wantHistory: []string{"Part one\n\nPart two", "Answer\n\nMore"},
+			wantCreated: time.Unix(1700000000, 0),
+			wantUpdated: time.Unix(1700000000, 0),
+		},
+		{
+			name:        "Open WebUI branch at currentId",
+			file:        "chat-export.json",
+			data:        openWebUIExport,
+			wantFormat:  OpenWebUI,
+			wantTitle:   "Greetings",
+			wantModel:   "mistral",
+			wantHistory: []string{"Hello", "Hey there"},
+			wantCreated: time.Unix(1700000000, 0),
+			wantUpdated: time.Unix(1700000300, 0),
+		},
+		{
+			name:        "Open WebUI without history",
+			file:        "chat-export.json",
+			data:        `{"title": "Flat", "chat": {"timestamp": 1700000000000, "messages": [{"role": "user", "content": "Hello"}, {"role": "assistant", "content": "Hi", "model": "phi3"}]}}`,
+			wantFormat:  OpenWebUI,
+			wantTitle:   "Flat",
+			wantModel:   "phi3",
+			wantHistory: []string{"Hello", "Hi"},
+			wantCreated: time.Unix(1700000000, 0),
+			wantUpdated: time.Unix(1700000000, 0),
+		},
+		{
+			name:        "lamacli Markdown",
+			file:        "shell-help.md",
+			data:        markdownTranscript,
+			wantFormat:  Markdown,
+			wantTitle:   "Shell help",
+			wantModel:   "llama3",
+			wantHistory: []string{"Write a README", "```markdown\n## User\nNot a new message\n```", "Thanks", ""},
+			wantCreated: markdownDate,
+			wantUpdated: markdownDate,
+		},
+		{
+			name:        "Markdown opened by the assistant",
+			file:        "notes.txt",
+			data:        "## Assistant\nWelcome\n## User\nHi\n## Assistant\nHello\n",
+			wantFormat:  Markdown,
+			wantHistory: []string{"", "Welcome", "Hi", "Hello"},
+			wantUpdated: modTime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations, format, err := Read(tt.file, []byte(tt.data), Auto, modTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %s, want %s", format, tt.wantFormat)
+			}
+			if len(conversations) != 1 {
+				t.Fatalf("read %d conversations, want 1", len(conversations))
+			}
+			conversation := conversations[0]
+			if conversation.Err != nil {
+				t.Fatal(conversation.Err)
+			}
+			session := conversation.Session
+			if session.Title != tt.wantTitle || session.Model != tt.wantModel {
+				t.Errorf("title, model = %q, %q; want %q, %q", session.Title, session.Model, tt.wantTitle, tt.wantModel)
+			}
+			if !reflect.DeepEqual(session.History, tt.wantHistory) {
+				t.Errorf("history = %q, want %q", session.History, tt.wantHistory)
+			}
+			if !session.CreatedAt.Equal(tt.wantCreated) || !session.UpdatedAt.Equal(tt.wantUpdated) {
+				t.Errorf("created, updated = %v, %v; want %v, %v", session.CreatedAt, session.UpdatedAt, tt.wantCreated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestReadRejectsConversationsWithoutUser(t *testing.T) {
+	conversations, _, err := Read("chat.json", []byte(`{"messages": [{"role": "system", "content": "x"}, {"role": "assistant", "content": "y"}]}`), Auto, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 1 || conversations[0].Err == nil {
+		t.Errorf("conversation without user messages was converted: %+v", conversations)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name, data string
+		want       Format
+	}{
+		{"chat.md", "{}", Markdown},
+		{"chat.json", "not json", Markdown},
+		{"conversations.json", `[{"mapping": {}}]`, OpenAI},
+		{"chat.json", `{"messages": []}`, OpenAI},
+		{"export.json", `[{"chat": {}}]`, OpenWebUI},
+		{"session.json", `{"history": ["a", "b"]}`, LamaCLI},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name, []byte(tt.data)); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %s, want %s", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestUnixTime(t *testing.T) {
+	want := time.UnixMilli(1700000000500)
+	for _, value := range []float64{1700000000.5, 1700000000500, 1700000000500000, 1700000000500000000} {
+		if got := unixTime(value); !got.Equal(want) {
+			t.Errorf("unixTime(%v) = %v, want %v", value, got, want)
+		}
+	}
+	if got := unixTime(0); !got.IsZero() {
+		t.Errorf("unixTime(0) = %v, want the zero time", got)
+	}
+}
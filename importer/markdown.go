@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// roleHeading matches a heading naming who speaks next, such as
+	// "## User", "### Assistant:" or "## 🤖 Assistant" as lamacli exports
+	roleHeading = regexp.MustCompile(`(?i)^#{1,6}\s+(?:[^\p{L}\p{N}\s]+\s*)?(user|you|human|me|assistant|ai|bot|model|llm|chatgpt)\s*:?\s*$`)
+
+	// titleHeading matches the title heading of a transcript
+	titleHeading = regexp.MustCompile(`^#\s+(.+?)\s*$`)
+
+	// metadataLine matches the italic line of model and date lamacli puts
+	// under the title
+	metadataLine = regexp.MustCompile(`^\*([^*]+)\*$`)
+)
+
+// metadataDate is the date layout of the lamacli metadata line
+const metadataDate = "Jan 2, 2006 15:04"
+
+// readMarkdown reads a transcript with a role heading before each message.
+// Headings inside code blocks are part of the message.
+func readMarkdown(data []byte) Conversation {
+	var title, model string
+	var date time.Time
+	var messages []message
+	var body []string
+	fence := ""
+
+	flush := func() {
+		if len(messages) > 0 {
+			messages[len(messages)-1].content = strings.Join(body, "\n")
+		}
+		body = nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = updateFence(fence, trimmed)
+			body = append(body, line)
+			continue
+		}
+
+		if match := roleHeading.FindStringSubmatch(trimmed); match != nil {
+			flush()
+			messages = append(messages, message{role: match[1]})
+			continue
+		}
+		if len(messages) == 0 {
+			// Before the first message: the title and metadata
+			if match := titleHeading.FindStringSubmatch(trimmed); match != nil && title == "" {
+				title = match[1]
+			} else if match := metadataLine.FindStringSubmatch(trimmed); match != nil && title != "" {
+				model, date = parseMetadata(match[1])
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return convert(title, model, messages, date, date)
+}
+
+// updateFence tracks whether a line opens or closes a code block, given
+// the fence of the open block, if any
+func updateFence(fence, line string) string {
+	marker := line[:len(line)-len(strings.TrimLeft(line, "`~"))]
+	switch {
+	case fence == "" && len(marker) >= 3:
+		return marker
+	case fence != "" && strings.TrimSpace(line) == marker && marker[0] == fence[0] && len(marker) >= len(fence):
+		return ""
+	}
+	return fence
+}
+
+// parseMetadata reads the model and date from a lamacli metadata line,
+// "model • date". Without a date the line is not lamacli's, and ignored.
+func parseMetadata(line string) (string, time.Time) {
+	parts := strings.Split(line, " • ")
+	date, err := time.ParseInLocation(metadataDate, strings.TrimSpace(parts[len(parts)-1]), time.Local)
+	if err != nil {
+		return "", time.Time{}
+	}
+	if len(parts) == 2 {
+		return strings.TrimSpace(parts[0]), date
+	}
+	return "", date
+}
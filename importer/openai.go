@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// chatGPTConversation is a conversation of a ChatGPT data export. Its
+// messages form a tree, edits and regenerations being branches; the
+// conversation as last shown ends at current_node.
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Content struct {
+		ContentType string `json:"content_type"`
+		Parts       []any  `json:"parts"`
+		Text        string `json:"text"`
+	} `json:"content"`
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// completionConversation is a conversation in the shape of an OpenAI chat
+// completions request
+type completionConversation struct {
+	Title      string              `json:"title"`
+	Created    float64             `json:"created"`
+	CreateTime float64             `json:"create_time"`
+	UpdateTime float64             `json:"update_time"`
+	Messages   []completionMessage `json:"messages"`
+}
+
+type completionMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // A string or a list of content parts
+}
+
+// readOpenAI reads a ChatGPT data export (conversations.json) or chat
+// completions style conversations: {"messages": [...]}, a list of those,
+// or a bare list of messages
+func readOpenAI(data []byte) ([]Conversation, error) {
+	var items []json.RawMessage
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		var first map[string]any
+		if len(items) > 0 && json.Unmarshal(items[0], &first) == nil && first["role"] != nil {
+			items = []json.RawMessage{[]byte(`{"messages":` + string(data) + `}`)}
+		}
+	} else {
+		items = []json.RawMessage{data}
+	}
+
+	conversations := make([]Conversation, 0, len(items))
+	for i, item := range items {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(item, &keys); err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+
+		switch {
+		case keys["mapping"] != nil:
+			var conversation chatGPTConversation
+			if err := json.Unmarshal(item, &conversation); err != nil {
+				return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+			}
+			conversations = append(conversations, convert(conversation.Title, "", conversation.messages(),
+				unixTime(conversation.CreateTime), unixTime(conversation.UpdateTime)))
+		case keys["messages"] != nil:
+			var conversation completionConversation
+			if err := json.Unmarshal(item, &conversation); err != nil {
+				return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+			}
+			created := unixTime(max(conversation.Created, conversation.CreateTime))
+			conversations = append(conversations, convert(conversation.Title, "", conversation.messages(),
+				created, unixTime(conversation.UpdateTime)))
+		default:
+			return nil, fmt.Errorf("conversation %d has neither \"mapping\" nor \"messages\"", i+1)
+		}
+	}
+	return conversations, nil
+}
+
+// messages returns the branch of the conversation that was last shown
+func (c chatGPTConversation) messages() []message {
+	node := c.CurrentNode
+	if _, ok := c.Mapping[node]; !ok {
+		node = c.lastLeaf()
+	}
+
+	var branch []message
+	seen := make(map[string]bool)
+	for node != "" && !seen[node] {
+		seen[node] = true
+		current, ok := c.Mapping[node]
+		if !ok {
+			break
+		}
+		if msg := current.Message; msg != nil && !msg.Metadata.Hidden && (msg.Recipient == "" || msg.Recipient == "all") {
+			if content := msg.text(); content != "" {
+				branch = append(branch, message{role: msg.Author.Role, content: content})
+			}
+		}
+		node = current.Parent
+	}
+
+	// The branch was collected from its end
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch
+}
+
+// lastLeaf finds the end of the conversation by following the latest
+// child from the root, for exports without a current_node
+func (c chatGPTConversation) lastLeaf() string {
+	node := ""
+	for id, n := range c.Mapping {
+		if _, ok := c.Mapping[n.Parent]; !ok {
+			node = id
+			break
+		}
+	}
+	for i := 0; i < len(c.Mapping); i++ {
+		children := c.Mapping[node].Children
+		if len(children) == 0 {
+			break
+		}
+		node = children[len(children)-1]
+	}
+	return node
+}
+
+// text returns the visible text of a message. Code run by tools, images
+// and other content types are skipped.
+func (m *chatGPTMessage) text() string {
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+	default:
+		return ""
+	}
+	if len(m.Content.Parts) == 0 {
+		return m.Content.Text
+	}
+	var parts []string
+	for _, part := range m.Content.Parts {
+		if text, ok := part.(string); ok {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (c completionConversation) messages() []message {
+	messages := make([]message, 0, len(c.Messages))
+	for _, msg := range c.Messages {
+		messages = append(messages, message{role: msg.Role, content: contentText(msg.Content)})
+	}
+	return messages
+}
+
+// contentText returns the text of a chat completions message content,
+// which is a string or a list of parts of which only text is kept
+func contentText(content any) string {
+	switch content := content.(type) {
+	case string:
+		return content
+	case []any:
+		var parts []string
+		for _, part := range content {
+			if part, ok := part.(map[string]any); ok && part["type"] == "text" {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openWebUIChat is a chat of an Open WebUI export. Like ChatGPT's, its
+// messages form a tree whose shown branch ends at history.currentId.
+type openWebUIChat struct {
+	Title     string  `json:"title"`
+	CreatedAt float64 `json:"created_at"`
+	UpdatedAt float64 `json:"updated_at"`
+	Chat      struct {
+		Title     string             `json:"title"`
+		Models    []string           `json:"models"`
+		Timestamp float64            `json:"timestamp"`
+		Messages  []openWebUIMessage `json:"messages"`
+		History   struct {
+			Messages  map[string]openWebUIMessage `json:"messages"`
+			CurrentID string                      `json:"currentId"`
+		} `json:"history"`
+	} `json:"chat"`
+}
+
+type openWebUIMessage struct {
+	ParentID string `json:"parentId"`
+	Role     string `json:"role"`
+	Content  any    `json:"content"`
+	Model    string `json:"model"`
+}
+
+// readOpenWebUI reads an Open WebUI export, a list of chats or a single one
+func readOpenWebUI(data []byte) ([]Conversation, error) {
+	var chats []openWebUIChat
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &chats); err != nil {
+			return nil, err
+		}
+	} else {
+		var chat openWebUIChat
+		if err := json.Unmarshal(data, &chat); err != nil {
+			return nil, err
+		}
+		chats = []openWebUIChat{chat}
+	}
+
+	conversations := make([]Conversation, len(chats))
+	for i, chat := range chats {
+		title := chat.Title
+		if title == "" {
+			title = chat.Chat.Title
+		}
+		created := unixTime(chat.CreatedAt)
+		if created.IsZero() {
+			created = unixTime(chat.Chat.Timestamp)
+		}
+		messages, model := chat.messages()
+		conversations[i] = convert(title, model, messages, created, unixTime(chat.UpdatedAt))
+	}
+	return conversations, nil
+}
+
+// messages returns the branch of the chat that was last shown, and the
+// model that answered it
+func (c openWebUIChat) messages() ([]message, string) {
+	var shown []openWebUIMessage
+	history := c.Chat.History
+	if _, ok := history.Messages[history.CurrentID]; ok {
+		seen := make(map[string]bool)
+		for id := history.CurrentID; id != "" && !seen[id]; {
+			seen[id] = true
+			msg, ok := history.Messages[id]
+			if !ok {
+				break
+			}
+			shown = append([]openWebUIMessage{msg}, shown...)
+			id = msg.ParentID
+		}
+	} else {
+		shown = c.Chat.Messages
+	}
+
+	model := ""
+	if len(c.Chat.Models) > 0 {
+		model = c.Chat.Models[0]
+	}
+	messages := make([]message, 0, len(shown))
+	for _, msg := range shown {
+		messages = append(messages, message{role: msg.Role, content: contentText(msg.Content)})
+		if msg.Role == "assistant" && msg.Model != "" {
+			model = msg.Model
+		}
+	}
+	return messages, model
+}
@@ -2,15 +2,21 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	ollama "github.com/ollama/ollama/api"
 )
 
 // OllamaClient wraps the Ollama API client.
 type OllamaClient struct {
-	client *ollama.Client
+	client  *ollama.Client
+	options map[string]any
 }
 
 // NewOllamaClient creates a new OllamaClient.
@@ -22,9 +28,67 @@ func NewOllamaClient() (*OllamaClient, error) {
 	return &OllamaClient{client: o}, nil
 }
 
+// NewOllamaClientWithHost creates a new OllamaClient for the given host,
+// e.g. "gpu-box:11434" or "http://localhost:11434". An empty host falls
+// back to OLLAMA_HOST and the Ollama defaults.
+func NewOllamaClientWithHost(host string) (*OllamaClient, error) {
+	if host == "" {
+		return NewOllamaClient()
+	}
+
+	base, err := parseHost(host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
+	}
+	return &OllamaClient{client: ollama.NewClient(base, http.DefaultClient)}, nil
+}
+
+// parseHost normalises a host string into a base URL. Like OLLAMA_HOST, a
+// bare host gets the http scheme and the default Ollama port.
+func parseHost(host string) (*url.URL, error) {
+	bare := !strings.Contains(host, "://")
+	if bare {
+		host = "http://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if bare && u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "11434")
+	}
+	return u, nil
+}
+
+// SetOptions sets the model options (temperature, num_ctx, ...) sent with
+// every request.
+func (oc *OllamaClient) SetOptions(options map[string]any) {
+	oc.options = options
+}
+
+// WithOptions returns a client that shares the connection but sends the
+// given options on top of the current ones.
+func (oc *OllamaClient) WithOptions(options map[string]any) *OllamaClient {
+	merged := make(map[string]any, len(oc.options)+len(options))
+	for k, v := range oc.options {
+		merged[k] = v
+	}
+	for k, v := range options {
+		merged[k] = v
+	}
+	return &OllamaClient{client: oc.client, options: merged}
+}
+
 // ListModels lists all available Ollama models.
 func (oc *OllamaClient) ListModels() ([]string, error) {
-	resp, err := oc.client.List(context.Background())
+	return oc.ListModelsContext(context.Background())
+}
+
+// ListModelsContext is like ListModels but honours ctx, so callers can time
+// out.
+func (oc *OllamaClient) ListModelsContext(ctx context.Context) ([]string, error) {
+	resp, err := oc.client.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list Ollama models: %w", err)
 	}
@@ -41,10 +105,11 @@ func (oc *OllamaClient) GenerateResponse(modelName, prompt, systemPrompt string)
 	var responseText string
 	stream := false
 	err := oc.client.Generate(context.Background(), &ollama.GenerateRequest{
-		Model:  modelName,
-		Prompt: prompt,
-		System: systemPrompt,
-		Stream: &stream,
+		Model:   modelName,
+		Prompt:  prompt,
+		System:  systemPrompt,
+		Stream:  &stream,
+		Options: oc.options,
 	}, func(res ollama.GenerateResponse) error {
 		responseText += res.Response
 		return nil
@@ -62,6 +127,146 @@ func (oc *OllamaClient) GenerateResponse(modelName, prompt, systemPrompt string)
 func (oc *OllamaClient) GenerateResponseStream(modelName, systemPrompt string, history []string, ch chan<- string) {
 	defer close(ch)
 
+	stream := true
+	err := oc.client.Chat(context.Background(), &ollama.ChatRequest{
+		Model:    modelName,
+		Messages: buildMessages(systemPrompt, history),
+		Stream:   &stream,
+		Options:  oc.options,
+	}, func(res ollama.ChatResponse) error {
+		ch <- res.Message.Content
+		return nil
+	})
+
+	if err != nil {
+		errorMsg := fmt.Sprintf("Error: %v", err)
+		ch <- errorMsg
+	}
+}
+
+// Ping checks that the Ollama server is reachable.
+func (oc *OllamaClient) Ping(ctx context.Context) error {
+	if err := oc.client.Heartbeat(ctx); err != nil {
+		return fmt.Errorf("Ollama is not reachable: %w", err)
+	}
+	return nil
+}
+
+// Complete sends the chat history to Ollama and returns the full response.
+// Unlike GenerateResponseStream it honours ctx, so callers can time out.
+func (oc *OllamaClient) Complete(ctx context.Context, modelName, systemPrompt string, history []string) (string, error) {
+	return oc.complete(ctx, modelName, systemPrompt, history, nil)
+}
+
+// CompleteJSON is like Complete but constrains the response to JSON
+// matching the given schema.
+func (oc *OllamaClient) CompleteJSON(ctx context.Context, modelName, systemPrompt string, history []string, schema json.RawMessage) (string, error) {
+	return oc.complete(ctx, modelName, systemPrompt, history, schema)
+}
+
+func (oc *OllamaClient) complete(ctx context.Context, modelName, systemPrompt string, history []string, format json.RawMessage) (string, error) {
+	var response strings.Builder
+	stream := false
+	err := oc.client.Chat(ctx, &ollama.ChatRequest{
+		Model:    modelName,
+		Messages: buildMessages(systemPrompt, history),
+		Stream:   &stream,
+		Format:   format,
+		Options:  oc.options,
+	}, func(res ollama.ChatResponse) error {
+		response.WriteString(res.Message.Content)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate response: %w", err)
+	}
+	return strings.TrimSpace(response.String()), nil
+}
+
+// Stream sends the chat history to Ollama, passing each chunk of the
+// response to onChunk as it arrives, and returns the full response. An
+// error from onChunk stops generation.
+func (oc *OllamaClient) Stream(ctx context.Context, modelName, systemPrompt string, history []string, onChunk func(string) error) (string, error) {
+	var response strings.Builder
+	stream := true
+	err := oc.client.Chat(ctx, &ollama.ChatRequest{
+		Model:    modelName,
+		Messages: buildMessages(systemPrompt, history),
+		Stream:   &stream,
+		Options:  oc.options,
+	}, func(res ollama.ChatResponse) error {
+		if res.Message.Content == "" {
+			return nil
+		}
+		response.WriteString(res.Message.Content)
+		return onChunk(res.Message.Content)
+	})
+	if err != nil {
+		return response.String(), fmt.Errorf("failed to generate response: %w", err)
+	}
+	return response.String(), nil
+}
+
+// Stats are the timings Ollama reports for a completion, plus the time
+// until the first token arrived.
+type Stats struct {
+	FirstToken     time.Duration
+	Total          time.Duration
+	Load           time.Duration // Time spent loading the model
+	PromptTokens   int
+	PromptDuration time.Duration
+	OutputTokens   int
+	OutputDuration time.Duration
+}
+
+// CompleteWithStats streams a completion and returns the response along
+// with its timings.
+func (oc *OllamaClient) CompleteWithStats(ctx context.Context, modelName, systemPrompt string, history []string) (string, Stats, error) {
+	var response strings.Builder
+	var stats Stats
+	start := time.Now()
+	stream := true
+	err := oc.client.Chat(ctx, &ollama.ChatRequest{
+		Model:    modelName,
+		Messages: buildMessages(systemPrompt, history),
+		Stream:   &stream,
+		Options:  oc.options,
+	}, func(res ollama.ChatResponse) error {
+		if stats.FirstToken == 0 && res.Message.Content != "" {
+			stats.FirstToken = time.Since(start)
+		}
+		response.WriteString(res.Message.Content)
+		if res.Done {
+			stats.Total = res.TotalDuration
+			stats.Load = res.LoadDuration
+			stats.PromptTokens = res.PromptEvalCount
+			stats.PromptDuration = res.PromptEvalDuration
+			stats.OutputTokens = res.EvalCount
+			stats.OutputDuration = res.EvalDuration
+		}
+		return nil
+	})
+	if err != nil {
+		return "", stats, fmt.Errorf("failed to generate response: %w", err)
+	}
+	return response.String(), stats, nil
+}
+
+// Unload asks Ollama to release a model from memory.
+func (oc *OllamaClient) Unload(ctx context.Context, modelName string) error {
+	err := oc.client.Generate(ctx, &ollama.GenerateRequest{
+		Model:     modelName,
+		KeepAlive: &ollama.Duration{Duration: 0},
+	}, func(ollama.GenerateResponse) error { return nil })
+	if err != nil {
+		return fmt.Errorf("failed to unload %s: %w", modelName, err)
+	}
+	return nil
+}
+
+// buildMessages converts a system prompt and an alternating user/assistant
+// history into chat messages.
+func buildMessages(systemPrompt string, history []string) []ollama.Message {
 	messages := []ollama.Message{}
 	if systemPrompt != "" {
 		messages = append(messages, ollama.Message{
@@ -82,19 +287,5 @@ func (oc *OllamaClient) GenerateResponseStream(modelName, systemPrompt string, h
 			Content: message,
 		})
 	}
-
-	stream := true
-	err := oc.client.Chat(context.Background(), &ollama.ChatRequest{
-		Model:    modelName,
-		Messages: messages,
-		Stream:   &stream,
-	}, func(res ollama.ChatResponse) error {
-		ch <- res.Message.Content
-		return nil
-	})
-
-	if err != nil {
-		errorMsg := fmt.Sprintf("Error: %v", err)
-		ch <- errorMsg
-	}
+	return messages
 }
@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	ollama "github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/types/model"
+)
+
+// Tool is a function the model may call.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON schema of the arguments
+}
+
+// ToolCall is the model's request to call a tool.
+type ToolCall struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// ToolStep is one round of tool use within an assistant reply: the text
+// the model wrote before calling tools, its calls and their results.
+type ToolStep struct {
+	Content string
+	Calls   []ToolCall
+	Results []string // One per call, in order
+}
+
+// StreamEvent is a chunk of a response, the tool calls the model made at
+// the end of it, or an error.
+type StreamEvent struct {
+	Text      string
+	ToolCalls []ToolCall
+	Err       error
+}
+
+// SupportsTools reports whether a model can call tools.
+func (oc *OllamaClient) SupportsTools(ctx context.Context, modelName string) bool {
+	info, err := oc.client.Show(ctx, &ollama.ShowRequest{Model: modelName})
+	return err == nil && slices.Contains(info.Capabilities, model.CapabilityTools)
+}
+
+// StreamWithTools is like GenerateResponseStream but offers tools to the
+// model. steps are the tool rounds already completed in the current reply.
+// If the model calls tools, they are sent as the last event. The channel
+// is closed when the response is complete.
+func (oc *OllamaClient) StreamWithTools(ctx context.Context, modelName, systemPrompt string, history []string, steps []ToolStep, tools []Tool, ch chan<- StreamEvent) {
+	defer close(ch)
+
+	messages := buildMessages(systemPrompt, history)
+	for _, step := range steps {
+		assistant := ollama.Message{Role: "assistant", Content: step.Content}
+		for _, call := range step.Calls {
+			assistant.ToolCalls = append(assistant.ToolCalls, ollama.ToolCall{
+				Function: ollama.ToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, assistant)
+		for i, result := range step.Results {
+			messages = append(messages, ollama.Message{Role: "tool", Content: result, ToolName: step.Calls[i].Name})
+		}
+	}
+
+	var calls []ToolCall
+	stream := true
+	err := oc.client.Chat(ctx, &ollama.ChatRequest{
+		Model:    modelName,
+		Messages: messages,
+		Stream:   &stream,
+		Tools:    ollamaTools(tools),
+		Options:  oc.options,
+	}, func(res ollama.ChatResponse) error {
+		for _, call := range res.Message.ToolCalls {
+			calls = append(calls, ToolCall{Name: call.Function.Name, Arguments: call.Function.Arguments})
+		}
+		if res.Message.Content != "" {
+			ch <- StreamEvent{Text: res.Message.Content}
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil:
+		ch <- StreamEvent{Err: err}
+	case len(calls) > 0:
+		ch <- StreamEvent{ToolCalls: calls}
+	}
+}
+
+// ollamaTools converts tools to Ollama's format
+func ollamaTools(tools []Tool) ollama.Tools {
+	var converted ollama.Tools
+	for _, tool := range tools {
+		t := ollama.Tool{Type: "function"}
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		if err := json.Unmarshal(tool.Parameters, &t.Function.Parameters); err != nil {
+			// Offer the tool without arguments rather than not at all
+			t.Function.Parameters.Properties = nil
+			t.Function.Parameters.Required = nil
+		}
+		t.Function.Parameters.Type = "object"
+		converted = append(converted, t)
+	}
+	return converted
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 
 	"github.com/hariharen9/lamacli/cli"
+	"github.com/hariharen9/lamacli/config"
 	"github.com/hariharen9/lamacli/ui"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -30,10 +31,29 @@ const lamaPortrait = `
 `
 
 func main() {
+	// Load the config file so its theme can serve as the flag default. A
+	// broken config is reported by the command that needs it, so that
+	// 'config edit' and 'config path' can still be used to fix it.
+	cfg, configErr := config.Load()
+	if configErr != nil {
+		cfg = config.Default()
+	}
+
 	// Define a command-line flag for the theme.
-	theme := flag.String("theme", "dark", "Set the UI theme ('dark' or 'light')")
+	theme := flag.String("theme", cfg.Theme, "Set the UI theme ('dark' or 'light')")
+	profile := flag.String("profile", "", "Use a named config profile")
 	flag.Parse()
 
+	// Select the profile through the environment so that both the CLI and
+	// the TUI, which load the config themselves, pick it up.
+	// An unknown profile fails config.Load like a broken config file.
+	if *profile != "" {
+		os.Setenv("LAMACLI_PROFILE", *profile)
+		if _, err := config.Load(); err != nil {
+			configErr = err
+		}
+	}
+
 	// Set the background color profile based on the theme flag.
 	// This prevents lipgloss from querying the terminal, fixing issues on macOS.
 	switch *theme {
@@ -59,18 +79,23 @@ func main() {
 		return
 	}
 
-	// No positional arguments provided - start interactive mode.
-	startInteractiveMode()
+	// No positional arguments provided - start interactive mode, which
+	// needs a working config.
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", configErr)
+		os.Exit(1)
+	}
+	startInteractiveMode(*theme)
 }
 
-// startInteractiveMode initializes and runs the interactive TUI
-func startInteractiveMode() {
+// startInteractiveMode initializes and runs the interactive TUI in a theme
+func startInteractiveMode(theme string) {
 	// Set terminal to raw mode on macOS to help prevent escape sequence issues
 	if runtime.GOOS == "darwin" {
 		fmt.Print("\033c") // Clear terminal to reset state
 	}
 
-	initialModel := ui.InitialModel()
+	initialModel := ui.InitialModel(cli.Version, theme)
 	if initialModel.Err != nil {
 		errorStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#F28482")). // A reddish color for errors
@@ -81,11 +106,16 @@ func startInteractiveMode() {
 
 		fmt.Println(errorStyle.Render(lamaPortrait))
 		fmt.Println(errorStyle.Render(message))
+		initialModel.Close()
 		os.Exit(1)
 	}
 
 	p := tea.NewProgram(initialModel)
-	if _, err := p.Run(); err != nil {
+	finalModel, err := p.Run()
+	if m, ok := finalModel.(ui.Model); ok {
+		m.Close()
+	}
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,379 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the MCP revision lamacli speaks.
+const ProtocolVersion = "2024-11-05"
+
+// Tool is a tool offered by an MCP server.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Resource is a piece of data an MCP server can provide, such as a file or
+// a database schema.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Capabilities are the features a server supports.
+type Capabilities struct {
+	Tools     *struct{} `json:"tools,omitempty"`
+	Resources *struct{} `json:"resources,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// message is any JSON-RPC message: a request, notification or response
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// Client talks to an MCP server running as a subprocess, exchanging
+// newline-delimited JSON-RPC messages over its stdin and stdout.
+type Client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+
+	mu      sync.Mutex // Guards writes, nextID and pending
+	nextID  int64
+	pending map[int64]chan message
+	done    chan struct{} // Closed when the server's output ends
+
+	Capabilities Capabilities
+}
+
+// Start launches the server and performs the initialize handshake.
+func Start(ctx context.Context, command string, args []string, env map[string]string, version string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tailBuffer{limit: 2048},
+		pending: make(map[int64]chan message),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+	go c.readLoop(stdout)
+
+	var result struct {
+		Capabilities Capabilities `json:"capabilities"`
+	}
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "lamacli", "version": version},
+	}, &result)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	c.Capabilities = result.Capabilities
+	if err := c.notify("notifications/initialized"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListTools returns the server's tools.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if cursor = result.NextCursor; cursor == "" {
+			return tools, nil
+		}
+	}
+}
+
+// ListResources returns the server's resources.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	cursor := ""
+	for {
+		var result struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		resources = append(resources, result.Resources...)
+		if cursor = result.NextCursor; cursor == "" {
+			return resources, nil
+		}
+	}
+}
+
+// content is an item of a tool result or resource
+type content struct {
+	Type     string   `json:"type"`
+	Text     string   `json:"text"`
+	MimeType string   `json:"mimeType"`
+	URI      string   `json:"uri"`
+	Resource *content `json:"resource"`
+}
+
+// String renders content as text for the model
+func (c content) String() string {
+	switch {
+	case c.Resource != nil:
+		return c.Resource.String()
+	case c.Text != "" || c.Type == "text":
+		return c.Text
+	default:
+		return fmt.Sprintf("[%s content: %s]", c.Type, c.MimeType)
+	}
+}
+
+// CallTool calls a tool and returns its output as text. A tool that
+// reports failure returns its output along with an error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (string, error) {
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments}, &result); err != nil {
+		return "", err
+	}
+	parts := make([]string, len(result.Content))
+	for i, item := range result.Content {
+		parts[i] = item.String()
+	}
+	output := strings.Join(parts, "\n")
+	if result.IsError {
+		return output, fmt.Errorf("tool %s failed: %s", name, output)
+	}
+	return output, nil
+}
+
+// ReadResource returns the text of a resource.
+func (c *Client) ReadResource(ctx context.Context, uri string) (string, error) {
+	var result struct {
+		Contents []content `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return "", err
+	}
+	parts := make([]string, len(result.Contents))
+	for i, item := range result.Contents {
+		parts[i] = item.String()
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// Close stops the server, killing it if it does not exit once its input
+// is closed.
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+func cursorParams(cursor string) any {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+// call sends a request and decodes its result
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	ch := make(chan message, 1)
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	err := c.write(message{ID: json.RawMessage(fmt.Sprint(id)), Method: method, Params: params})
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case response := <-ch:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil || len(response.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-c.done:
+		return c.exitError()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification, which has no response
+func (c *Client) notify(method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(message{Method: method})
+}
+
+// write sends a message. The caller holds c.mu.
+func (c *Client) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return c.exitError()
+	}
+	return nil
+}
+
+// readLoop dispatches responses to their callers and answers requests
+// from the server until its output ends
+func (c *Client) readLoop(stdout io.Reader) {
+	defer close(c.done)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			c.handle(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *Client) handle(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return // Servers sometimes log to stdout; ignore anything else
+	}
+
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		// A request from the server. Only ping is supported.
+		response := message{ID: msg.ID}
+		if msg.Method == "ping" {
+			response.Result = json.RawMessage("{}")
+		} else {
+			response.Error = &rpcError{Code: -32601, Message: "method not supported: " + msg.Method}
+		}
+		c.mu.Lock()
+		c.write(response)
+		c.mu.Unlock()
+	case msg.Method != "":
+		// Notifications such as log messages need no reply
+	default:
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+}
+
+// exitError explains why the server stopped responding, using its last
+// lines of stderr
+func (c *Client) exitError() error {
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+		return fmt.Errorf("server exited: %s", tail)
+	}
+	return errors.New("server exited")
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
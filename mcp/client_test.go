@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request is a message the fake server reads from the client
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// pipeClient connects a client to a fake server over in-process pipes.
+// serve is called with each message the client sends and returns the raw
+// lines to send back. Closing the returned writer ends the server's
+// output.
+func pipeClient(t *testing.T, serve func(req request) []string) (*Client, io.Closer) {
+	t.Helper()
+	clientOut, serverIn := io.Pipe()
+	serverOut, clientIn := io.Pipe()
+	c := &Client{
+		stdin:   serverIn,
+		stderr:  &tailBuffer{limit: 2048},
+		pending: make(map[int64]chan message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(serverOut)
+
+	// io.Pipe has no buffer, so the server writes from its own goroutine
+	// rather than block reading while the client answers a request
+	lines := make(chan string, 64)
+	go func() {
+		for line := range lines {
+			if _, err := io.WriteString(clientIn, line+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(clientOut)
+		for scanner.Scan() {
+			var req request
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+				t.Errorf("client sent invalid JSON %q: %v", scanner.Text(), err)
+				continue
+			}
+			for _, line := range serve(req) {
+				lines <- line
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		serverIn.Close()
+		clientIn.Close()
+	})
+	return c, clientIn
+}
+
+// reply returns a response line for a request
+func reply(req request, result string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+}
+
+func TestClientCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(c *Client) (string, error)
+		serve   func(req request) []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "tool result",
+			call: func(c *Client) (string, error) {
+				return c.CallTool(context.Background(), "echo", map[string]any{"text": "hi"})
+			},
+			serve: func(req request) []string {
+				var params struct {
+					Name      string         `json:"name"`
+					Arguments map[string]any `json:"arguments"`
+				}
+				json.Unmarshal(req.Params, &params)
+				return []string{reply(req, fmt.Sprintf(`{"content":[{"type":"text","text":"%s %s"}]}`, params.Name, params.Arguments["text"]))}
+			},
+			want: "echo hi",
+		},
+		{
+			name: "tool failure",
+			call: func(c *Client) (string, error) {
+				return c.CallTool(context.Background(), "fail", nil)
+			},
+			serve: func(req request) []string {
+				return []string{reply(req, `{"content":[{"type":"text","text":"no such file"}],"isError":true}`)}
+			},
+			want:    "no such file",
+			wantErr: "tool fail failed: no such file",
+		},
+		{
+			name: "error response",
+			call: func(c *Client) (string, error) {
+				return c.CallTool(context.Background(), "missing", nil)
+			},
+			serve: func(req request) []string {
+				return []string{fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32602,"message":"unknown tool"}}`, req.ID)}
+			},
+			wantErr: "unknown tool (code -32602)",
+		},
+		{
+			name: "logs and notifications are skipped",
+			call: func(c *Client) (string, error) {
+				return c.ReadResource(context.Background(), "file:///notes.txt")
+			},
+			serve: func(req request) []string {
+				return []string{
+					"starting server...",
+					`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info"}}`,
+					`{"jsonrpc":"2.0","id":999,"result":{}}`,
+					reply(req, `{"contents":[{"uri":"file:///notes.txt","text":"a"},{"resource":{"text":"b"}}]}`),
+				}
+			},
+			want: "a\nb",
+		},
+		{
+			name: "pagination",
+			call: func(c *Client) (string, error) {
+				tools, err := c.ListTools(context.Background())
+				names := make([]string, len(tools))
+				for i, tool := range tools {
+					names[i] = tool.Name
+				}
+				return strings.Join(names, ","), err
+			},
+			serve: func(req request) []string {
+				if strings.Contains(string(req.Params), `"cursor":"2"`) {
+					return []string{reply(req, `{"tools":[{"name":"c"}]}`)}
+				}
+				return []string{reply(req, `{"tools":[{"name":"a"},{"name":"b"}],"nextCursor":"2"}`)}
+			},
+			want: "a,b,c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := pipeClient(t, tt.serve)
+			got, err := tt.call(c)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRoutesResponsesOutOfOrder(t *testing.T) {
+	slow := make(chan request, 1)
+	c, _ := pipeClient(t, func(req request) []string {
+		var params struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if params.Name == "slow" {
+			slow <- req
+			return nil
+		}
+		// Answer the fast call, then the slow one that came before it
+		first := <-slow
+		return []string{
+			reply(req, `{"content":[{"type":"text","text":"fast"}]}`),
+			reply(first, `{"content":[{"type":"text","text":"slow"}]}`),
+		}
+	})
+
+	type outcome struct {
+		output string
+		err    error
+	}
+	slowResult := make(chan outcome, 1)
+	go func() {
+		output, err := c.CallTool(context.Background(), "slow", nil)
+		slowResult <- outcome{output, err}
+	}()
+	for {
+		c.mu.Lock()
+		waiting := len(c.pending)
+		c.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	output, err := c.CallTool(context.Background(), "fast", nil)
+	if err != nil || output != "fast" {
+		t.Errorf("fast call = %q, %v", output, err)
+	}
+	result := <-slowResult
+	if result.err != nil || result.output != "slow" {
+		t.Errorf("slow call = %q, %v", result.output, result.err)
+	}
+}
+
+func TestClientAnswersServerRequests(t *testing.T) {
+	responses := make(chan request, 2)
+	c, _ := pipeClient(t, func(req request) []string {
+		if req.Method == "" {
+			responses <- req
+			return nil
+		}
+		return []string{
+			`{"jsonrpc":"2.0","id":"p1","method":"ping"}`,
+			`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{}}`,
+			reply(req, `{"tools":[]}`),
+		}
+	})
+	if _, err := c.ListTools(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		id    string
+		error bool
+	}{{`"p1"`, false}, {`"s1"`, true}} {
+		select {
+		case response := <-responses:
+			if string(response.ID) != want.id || (response.Error != nil) != want.error {
+				t.Errorf("response = id %s, error %v; want id %s, error %v", response.ID, response.Error, want.id, want.error)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no response to request %s", want.id)
+		}
+	}
+}
+
+func TestClientServerExit(t *testing.T) {
+	c, output := pipeClient(t, func(req request) []string {
+		return nil // Never answer
+	})
+	c.stderr.Write([]byte("panic: out of memory\n"))
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.ListTools(context.Background())
+		result <- err
+	}()
+	output.Close()
+
+	select {
+	case err := <-result:
+		if err == nil || err.Error() != "server exited: panic: out of memory" {
+			t.Errorf("error = %v, want the server's stderr", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call did not return after the server exited")
+	}
+}
+
+func TestClientCallCancelled(t *testing.T) {
+	c, _ := pipeClient(t, func(req request) []string {
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ListTools(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) != 0 {
+		t.Errorf("%d calls still pending", len(c.pending))
+	}
+}
@@ -0,0 +1,230 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hariharen9/lamacli/config"
+)
+
+// ReadResourceTool is the tool offered for servers with resources, letting
+// the model read them.
+const ReadResourceTool = "read_resource"
+
+// maxListedResources caps the resources described to the model per server
+const maxListedResources = 50
+
+// Server is a configured MCP server and what it offers.
+type Server struct {
+	Name      string
+	Client    *Client
+	Tools     []Tool
+	Resources []Resource
+	Err       error // Why the server could not be used
+
+	autoApprove map[string]bool
+}
+
+// BoundTool is a server's tool as offered to the model, under a name
+// qualified by the server's name.
+type BoundTool struct {
+	Name        string // Qualified name, e.g. "github__search_issues"
+	Server      *Server
+	Tool        Tool
+	description string
+	schema      json.RawMessage
+}
+
+// Description describes the tool to the model.
+func (t BoundTool) Description() string {
+	return t.description
+}
+
+// Schema is the JSON schema of the tool's arguments.
+func (t BoundTool) Schema() json.RawMessage {
+	return t.schema
+}
+
+// Manager connects to the configured servers and routes tool calls to
+// them.
+type Manager struct {
+	Servers []*Server
+	tools   map[string]BoundTool
+}
+
+// Connect starts every enabled server and discovers its tools and
+// resources. Servers that fail are kept with their error so they can be
+// reported.
+func Connect(ctx context.Context, servers map[string]config.MCPServer, version string) *Manager {
+	m := &Manager{tools: make(map[string]BoundTool)}
+	for name, cfg := range servers {
+		if cfg.Disabled {
+			continue
+		}
+		server := &Server{Name: name, autoApprove: make(map[string]bool)}
+		for _, tool := range cfg.AutoApprove {
+			server.autoApprove[tool] = true
+		}
+		m.Servers = append(m.Servers, server)
+	}
+	sort.Slice(m.Servers, func(i, j int) bool { return m.Servers[i].Name < m.Servers[j].Name })
+
+	var wg sync.WaitGroup
+	for _, server := range m.Servers {
+		wg.Add(1)
+		go func(server *Server, cfg config.MCPServer) {
+			defer wg.Done()
+			server.Err = server.connect(ctx, cfg, version)
+		}(server, servers[server.Name])
+	}
+	wg.Wait()
+
+	for _, server := range m.Servers {
+		if server.Err == nil {
+			m.bind(server)
+		}
+	}
+	return m
+}
+
+func (s *Server) connect(ctx context.Context, cfg config.MCPServer, version string) error {
+	if cfg.Command == "" {
+		return fmt.Errorf("no command configured")
+	}
+	client, err := Start(ctx, cfg.Command, cfg.Args, cfg.Env, version)
+	if err != nil {
+		return err
+	}
+	s.Client = client
+
+	if client.Capabilities.Tools != nil {
+		if s.Tools, err = client.ListTools(ctx); err != nil {
+			return fmt.Errorf("failed to list tools: %w", err)
+		}
+	}
+	if client.Capabilities.Resources != nil {
+		if s.Resources, err = client.ListResources(ctx); err != nil {
+			return fmt.Errorf("failed to list resources: %w", err)
+		}
+	}
+	return nil
+}
+
+// invalidToolChars are replaced in server names, since models only accept
+// tool names made of letters, digits, underscores and dashes
+var invalidToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// bind offers a server's tools to the model, plus a tool to read its
+// resources
+func (m *Manager) bind(server *Server) {
+	prefix := invalidToolChars.ReplaceAllString(server.Name, "_") + "__"
+	hasReadResource := false
+	for _, tool := range server.Tools {
+		hasReadResource = hasReadResource || tool.Name == ReadResourceTool
+		schema := tool.InputSchema
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type": "object", "properties": {}}`)
+		}
+		m.tools[prefix+tool.Name] = BoundTool{
+			Name:        prefix + tool.Name,
+			Server:      server,
+			Tool:        tool,
+			description: tool.Description,
+			schema:      schema,
+		}
+	}
+
+	if len(server.Resources) == 0 || hasReadResource {
+		return
+	}
+	var description strings.Builder
+	fmt.Fprintf(&description, "Read a resource from the %s server. Available resources:", server.Name)
+	var uris []string
+	for i, resource := range server.Resources {
+		if i == maxListedResources {
+			fmt.Fprintf(&description, "\n- ... and %d more", len(server.Resources)-i)
+			break
+		}
+		fmt.Fprintf(&description, "\n- %s: %s", resource.URI, resource.Name)
+		if resource.Description != "" {
+			fmt.Fprintf(&description, " (%s)", resource.Description)
+		}
+		uris = append(uris, resource.URI)
+	}
+	schema, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"uri": map[string]any{"type": "string", "description": "URI of the resource to read", "enum": uris},
+		},
+		"required": []string{"uri"},
+	})
+	m.tools[prefix+ReadResourceTool] = BoundTool{
+		Name:        prefix + ReadResourceTool,
+		Server:      server,
+		Tool:        Tool{Name: ReadResourceTool},
+		description: description.String(),
+		schema:      schema,
+	}
+}
+
+// Tools returns the tools offered to the model, sorted by name.
+func (m *Manager) Tools() []BoundTool {
+	tools := make([]BoundTool, 0, len(m.tools))
+	for _, tool := range m.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Lookup finds a tool by its qualified name.
+func (m *Manager) Lookup(name string) (BoundTool, bool) {
+	tool, ok := m.tools[name]
+	return tool, ok
+}
+
+// NeedsApproval reports whether the user must approve a call to the tool,
+// which is the case unless its server lists it in auto_approve.
+func (m *Manager) NeedsApproval(name string) bool {
+	tool, ok := m.tools[name]
+	return !ok || !tool.Server.autoApprove[tool.Tool.Name]
+}
+
+// Call runs a tool by its qualified name.
+func (m *Manager) Call(ctx context.Context, name string, arguments map[string]any) (string, error) {
+	tool, ok := m.tools[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool '%s'", name)
+	}
+	if tool.Tool.Name == ReadResourceTool && !tool.Server.hasTool(ReadResourceTool) {
+		uri, _ := arguments["uri"].(string)
+		if uri == "" {
+			return "", fmt.Errorf("uri is required")
+		}
+		return tool.Server.Client.ReadResource(ctx, uri)
+	}
+	return tool.Server.Client.CallTool(ctx, tool.Tool.Name, arguments)
+}
+
+func (s *Server) hasTool(name string) bool {
+	for _, tool := range s.Tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops all servers.
+func (m *Manager) Close() {
+	for _, server := range m.Servers {
+		if server.Client != nil {
+			server.Client.Close()
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileEdit is the planned result of a change to one file.
+type FileEdit struct {
+	Path    string // Slash-separated path relative to the root
+	Old     string // Current contents, empty if the file doesn't exist
+	New     string // Contents after the change, empty when deleting
+	Existed bool
+	Delete  bool
+	Mode    fs.FileMode
+}
+
+// Plan holds the edits computed for a set of changes. Nothing is written
+// until Apply is called, so a change that doesn't fit leaves every file
+// untouched.
+type Plan struct {
+	Root  string
+	Edits []FileEdit
+}
+
+// Prepare reads the files the changes touch and computes their new
+// contents. Changes to the same file are applied in order.
+func Prepare(root string, changes []Change) (*Plan, error) {
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no file changes to apply")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Root: root}
+	index := make(map[string]int)
+	for _, change := range changes {
+		path, err := cleanPath(root, change.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		i, seen := index[path]
+		if !seen {
+			edit, err := loadEdit(root, path)
+			if err != nil {
+				return nil, err
+			}
+			plan.Edits = append(plan.Edits, edit)
+			i = len(plan.Edits) - 1
+			index[path] = i
+		}
+		edit := &plan.Edits[i]
+
+		switch {
+		case change.Delete:
+			if !edit.Existed {
+				return nil, fmt.Errorf("cannot delete %s: file does not exist", path)
+			}
+			edit.New, edit.Delete = "", true
+		case change.Replace:
+			content := change.Content
+			if edit.Existed && usesCRLF(edit.Old) {
+				content = toCRLF(content)
+			}
+			edit.New, edit.Delete = content, false
+		default:
+			updated, err := applyHunks(edit.New, change.Hunks)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			edit.New, edit.Delete = updated, false
+		}
+	}
+
+	// Drop edits that end up changing nothing
+	edits := plan.Edits[:0]
+	for _, edit := range plan.Edits {
+		if edit.Delete || !edit.Existed || edit.New != edit.Old {
+			edits = append(edits, edit)
+		}
+	}
+	plan.Edits = edits
+	if len(plan.Edits) == 0 {
+		return nil, fmt.Errorf("the changes leave all files as they are")
+	}
+	return plan, nil
+}
+
+// cleanPath validates a path from a model response or backup manifest.
+// Paths must stay inside the root, also after following symbolic links,
+// and may not touch version control or backup data.
+func cleanPath(root, path string) (string, error) {
+	path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(strings.TrimSpace(path))))
+	if path == "." || filepath.IsAbs(path) || strings.HasPrefix(path, "/") ||
+		path == ".." || strings.HasPrefix(path, "../") {
+		return "", fmt.Errorf("refusing to write outside the current directory: %s", path)
+	}
+	for _, dir := range []string{".git", BackupDir} {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return "", fmt.Errorf("refusing to write to %s", path)
+		}
+	}
+	if err := checkInsideRoot(root, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkInsideRoot resolves the symbolic links in the deepest existing
+// directory of a path and refuses the path if it leads out of root. The
+// directories below it don't exist yet, so they are created inside it.
+func checkInsideRoot(root, path string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(filepath.Join(root, filepath.FromSlash(path)))
+	for dir != root {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("refusing to write through %s: %w", path, err)
+	}
+
+	rel, err := filepath.Rel(realRoot, realDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to write outside the current directory: %s", path)
+	}
+	return nil
+}
+
+// loadEdit reads the current state of a file
+func loadEdit(root, path string) (FileEdit, error) {
+	edit := FileEdit{Path: path, Mode: 0644}
+	full := filepath.Join(root, filepath.FromSlash(path))
+	info, err := os.Stat(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		return edit, nil
+	}
+	if err != nil {
+		return edit, err
+	}
+	if !info.Mode().IsRegular() {
+		return edit, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return edit, err
+	}
+	edit.Old, edit.New = string(data), string(data)
+	edit.Existed = true
+	edit.Mode = info.Mode().Perm()
+	return edit, nil
+}
+
+// applyHunks applies diff hunks to content. Models often get line numbers
+// wrong, so each hunk is located by its context and removed lines,
+// preferring the match closest to the stated position.
+func applyHunks(content string, hunks []Hunk) (string, error) {
+	// Work on LF lines and restore the file's own line endings afterwards
+	crlf := usesCRLF(content)
+	finalNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := splitLines(strings.ReplaceAll(content, "\r\n", "\n"))
+	offset := 0 // Lines added or removed by earlier hunks
+	from := 0   // Hunks apply in order, so later ones start after earlier ones
+
+	for n, hunk := range hunks {
+		var old, updated []string
+		for _, line := range hunk.Lines {
+			text := line[1:]
+			switch line[0] {
+			case ' ':
+				old = append(old, text)
+				updated = append(updated, text)
+			case '-':
+				old = append(old, text)
+			case '+':
+				updated = append(updated, text)
+			}
+		}
+
+		expected := hunk.OldStart - 1 + offset
+		if hunk.OldStart == 0 {
+			expected = from
+		}
+		pos := findLines(lines, old, from, expected)
+		if pos < 0 {
+			return "", fmt.Errorf("hunk %d does not match the file", n+1)
+		}
+
+		result := make([]string, 0, len(lines)-len(old)+len(updated))
+		result = append(result, lines[:pos]...)
+		result = append(result, updated...)
+		result = append(result, lines[pos+len(old):]...)
+		lines = result
+
+		offset += len(updated) - len(old)
+		from = pos + len(updated)
+	}
+	updated := joinLines(lines)
+	if !finalNewline {
+		updated = strings.TrimSuffix(updated, "\n")
+	}
+	if crlf {
+		updated = toCRLF(updated)
+	}
+	return updated, nil
+}
+
+// findLines returns the position at or after from where want occurs in
+// lines, closest to expected, or -1. Trailing whitespace is ignored if
+// there is no exact match.
+func findLines(lines, want []string, from, expected int) int {
+	if len(want) == 0 {
+		// Pure insertion: trust the stated position
+		return max(from, min(expected, len(lines)))
+	}
+
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t\r") == strings.TrimRight(b, " \t\r") },
+	} {
+		best := -1
+		for pos := from; pos+len(want) <= len(lines); pos++ {
+			if !matchesAt(lines, want, pos, equal) {
+				continue
+			}
+			if best < 0 || abs(pos-expected) < abs(best-expected) {
+				best = pos
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func matchesAt(lines, want []string, pos int, equal func(a, b string) bool) bool {
+	for i, line := range want {
+		if !equal(lines[pos+i], line) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLines splits content into lines without their newlines
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins lines, ending the file with a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// usesCRLF reports whether content ends its first line with CRLF
+func usesCRLF(content string) bool {
+	i := strings.IndexByte(content, '\n')
+	return i > 0 && content[i-1] == '\r'
+}
+
+// toCRLF converts the line endings of content to CRLF
+func toCRLF(content string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Apply writes the planned edits. The original files are backed up
+// first; if any write fails, the files already written are restored.
+func (p *Plan) Apply() (*Backup, error) {
+	backup, err := createBackup(p.Root, p.Edits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up files: %w", err)
+	}
+
+	for i, edit := range p.Edits {
+		full := filepath.Join(p.Root, filepath.FromSlash(edit.Path))
+		if edit.Delete {
+			err = os.Remove(full)
+		} else {
+			err = writeFileAtomic(full, []byte(edit.New), edit.Mode)
+		}
+		if err != nil {
+			written := make([]string, i)
+			for j := range written {
+				written[j] = p.Edits[j].Path
+			}
+			restoreErr := backup.restore(written)
+			if restoreErr != nil {
+				return nil, fmt.Errorf("failed to write %s: %w (restoring backup also failed: %v)", edit.Path, err, restoreErr)
+			}
+			backup.remove()
+			return nil, fmt.Errorf("failed to write %s: %w", edit.Path, err)
+		}
+	}
+	return backup, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and
+// renames it into place, so readers never see a partial file
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
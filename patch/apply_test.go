@@ -0,0 +1,289 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		hunks   []Hunk
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "stated position",
+			content: "a\nb\nc\n",
+			hunks:   []Hunk{{OldStart: 2, Lines: []string{"-b", "+B"}}},
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "wrong line number",
+			content: "a\nb\nc\nd\n",
+			hunks:   []Hunk{{OldStart: 40, Lines: []string{" c", "-d", "+D"}}},
+			want:    "a\nb\nc\nD\n",
+		},
+		{
+			name:    "closest of several matches",
+			content: "x\ny\nx\ny\nx\ny\n",
+			hunks:   []Hunk{{OldStart: 5, Lines: []string{"-x", "+z"}}},
+			want:    "x\ny\nx\ny\nz\ny\n",
+		},
+		{
+			name:    "later hunks start after earlier ones",
+			content: "x\nx\n",
+			hunks:   []Hunk{{Lines: []string{"-x", "+a"}}, {Lines: []string{"-x", "+b"}}},
+			want:    "a\nb\n",
+		},
+		{
+			name:    "offset of earlier hunks",
+			content: "a\nb\nc\nd\n",
+			hunks:   []Hunk{{OldStart: 1, Lines: []string{" a", "+a2", "+a3"}}, {OldStart: 4, Lines: []string{"-d", "+D"}}},
+			want:    "a\na2\na3\nb\nc\nD\n",
+		},
+		{
+			name:    "trailing whitespace",
+			content: "a  \nb\n",
+			hunks:   []Hunk{{Lines: []string{" a", "-b", "+c"}}},
+			want:    "a\nc\n",
+		},
+		{
+			name:    "insertion",
+			content: "a\nb\n",
+			hunks:   []Hunk{{OldStart: 2, Lines: []string{"+x"}}},
+			want:    "a\nx\nb\n",
+		},
+		{
+			name:    "new file",
+			content: "",
+			hunks:   []Hunk{{Lines: []string{"+hello"}}},
+			want:    "hello\n",
+		},
+		{
+			name:    "missing newline at end",
+			content: "a\nb",
+			hunks:   []Hunk{{Lines: []string{"-b", "+c"}}},
+			want:    "a\nc",
+		},
+		{
+			name:    "append to a file without a final newline",
+			content: "a",
+			hunks:   []Hunk{{Lines: []string{" a", "+b"}}},
+			want:    "a\nb",
+		},
+		{
+			name:    "CRLF line endings",
+			content: "a\r\nb\r\nc\r\n",
+			hunks:   []Hunk{{Lines: []string{" a", "-b", "+B", "+B2"}}},
+			want:    "a\r\nB\r\nB2\r\nc\r\n",
+		},
+		{
+			name:    "no match",
+			content: "a\nb\n",
+			hunks:   []Hunk{{Lines: []string{"-z", "+y"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyHunks(tt.content, tt.hunks)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyHunks() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("applyHunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real", filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "main.go", want: "main.go"},
+		{path: " ./cmd//app/main.go ", want: "cmd/app/main.go"},
+		{path: "a/../b.go", want: "b.go"},
+		{path: "../outside.go", wantErr: true},
+		{path: "a/../../outside.go", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+		{path: ".", wantErr: true},
+		{path: ".git/config", wantErr: true},
+		{path: BackupDir + "/x/manifest.json", wantErr: true},
+		{path: ".github/workflows/ci.yml", want: ".github/workflows/ci.yml"},
+		{path: "escape/x.go", wantErr: true},
+		{path: "escape/new/dir/x.go", wantErr: true},
+		{path: "inside/x.go", want: "inside/x.go"},
+	}
+	for _, tt := range tests {
+		got, err := cleanPath(root, tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyAndUndo(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) (string, bool) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if os.IsNotExist(err) {
+			return "", false
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), true
+	}
+	write("edit.go", "package main\n\nfunc main() {}\n")
+	write("gone.go", "package main\n")
+
+	changes := []Change{
+		{Path: "edit.go", Hunks: []Hunk{{OldStart: 3, Lines: []string{"-func main() {}", "+func main() { run() }"}}}},
+		{Path: "gone.go", Delete: true},
+		{Path: "sub/new.go", Content: "package sub\n", Replace: true},
+	}
+	plan, err := Prepare(root, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Edits) != 3 {
+		t.Fatalf("Prepare() planned %d edits, want 3", len(plan.Edits))
+	}
+	backup, err := plan.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := read("edit.go"); got != "package main\n\nfunc main() { run() }\n" {
+		t.Errorf("edit.go after Apply = %q", got)
+	}
+	if _, ok := read("gone.go"); ok {
+		t.Error("gone.go exists after Apply")
+	}
+	if got, _ := read("sub/new.go"); got != "package sub\n" {
+		t.Errorf("sub/new.go after Apply = %q", got)
+	}
+
+	undone, err := Undo(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.ID != backup.ID {
+		t.Errorf("Undo() restored backup %s, want %s", undone.ID, backup.ID)
+	}
+	if got, _ := read("edit.go"); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("edit.go after Undo = %q", got)
+	}
+	if got, _ := read("gone.go"); got != "package main\n" {
+		t.Errorf("gone.go after Undo = %q", got)
+	}
+	if _, ok := read("sub/new.go"); ok {
+		t.Error("sub/new.go exists after Undo")
+	}
+	if backups, err := ListBackups(root); err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups() after Undo = %d backups, %v", len(backups), err)
+	}
+}
+
+func TestPrepareErrors(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "same.go"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		changes []Change
+	}{
+		{"no changes", nil},
+		{"delete a missing file", []Change{{Path: "missing.go", Delete: true}}},
+		{"hunk does not match", []Change{{Path: "same.go", Hunks: []Hunk{{Lines: []string{"-y", "+z"}}}}}},
+		{"nothing changes", []Change{{Path: "same.go", Content: "x\n", Replace: true}}},
+		{"outside the root", []Change{{Path: "../x.go", Content: "x\n", Replace: true}}},
+	}
+	for _, tt := range tests {
+		if _, err := Prepare(root, tt.changes); err == nil {
+			t.Errorf("%s: Prepare() succeeded", tt.name)
+		}
+	}
+}
+
+func TestReplaceKeepsCRLF(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "win.txt"), []byte("a\r\nb\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := Prepare(root, []Change{{Path: "win.txt", Content: "a\nc\n", Replace: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plan.Edits[0].New; got != "a\r\nc\r\n" {
+		t.Errorf("replaced content = %q, want CRLF line endings", got)
+	}
+}
+
+func TestUndoRefusesBadBackups(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "victim.txt")
+	if err := os.WriteFile(victim, []byte("keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"../../x", "latest", "20240101-120000/../x"} {
+		if _, err := Undo(root, id); err == nil {
+			t.Errorf("Undo(%q) succeeded", id)
+		}
+	}
+
+	// A manifest edited to point outside the root must not be restored
+	id := "20240101-120000"
+	dir := filepath.Join(root, filepath.FromSlash(BackupDir), id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(root, victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"id":"` + id + `","files":[{"path":"` + filepath.ToSlash(rel) + `","existed":false}]}`
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(root, id); err == nil {
+		t.Error("Undo() of a manifest with an outside path succeeded")
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("file outside the root was touched: %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// BackupDir is where backups are kept, relative to the root the patch
+// was applied in.
+const BackupDir = ".lamacli/backups"
+
+// backupIDTime is the time layout of backup ids
+const backupIDTime = "20060102-150405"
+
+// manifestFile describes a backup's files inside its directory
+const manifestFile = "manifest.json"
+
+// backupIDFormat matches the ids createBackup generates: a timestamp,
+// with a counter when several backups are made in the same second
+var backupIDFormat = regexp.MustCompile(`^\d{8}-\d{6}(-\d+)?$`)
+
+// Backup records the state of the files before a patch was applied.
+type Backup struct {
+	ID      string       `json:"id"`
+	Created time.Time    `json:"created"`
+	Files   []BackupFile `json:"files"`
+	root    string
+}
+
+// BackupFile is a file saved in a backup.
+type BackupFile struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"` // False for files the patch created
+	Mode    fs.FileMode `json:"mode"`
+}
+
+// createBackup copies the current contents of the edited files into a
+// new backup directory
+func createBackup(root string, edits []FileEdit) (*Backup, error) {
+	base := filepath.Join(root, filepath.FromSlash(BackupDir))
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := now.Format(backupIDTime)
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(base, id), 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		id = fmt.Sprintf("%s-%d", now.Format(backupIDTime), n)
+	}
+
+	backup := &Backup{ID: id, Created: now, root: root}
+	for _, edit := range edits {
+		backup.Files = append(backup.Files, BackupFile{Path: edit.Path, Existed: edit.Existed, Mode: edit.Mode})
+		if !edit.Existed {
+			continue
+		}
+		if err := writeFileAtomic(backup.filePath(edit.Path), []byte(edit.Old), 0644); err != nil {
+			backup.remove()
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(backup.dir(), manifestFile), data, 0644)
+	}
+	if err != nil {
+		backup.remove()
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Undo restores the files from a backup and deletes it. An empty id
+// selects the most recent backup.
+func Undo(root, id string) (*Backup, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup *Backup
+	if id == "" {
+		backups, err := ListBackups(root)
+		if err != nil {
+			return nil, err
+		}
+		if len(backups) == 0 {
+			return nil, fmt.Errorf("no backups to undo")
+		}
+		backup = backups[0]
+	} else if backup, err = loadBackup(root, id); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(backup.Files))
+	for i, file := range backup.Files {
+		paths[i] = file.Path
+	}
+	if err := backup.restore(paths); err != nil {
+		return nil, err
+	}
+	return backup, backup.remove()
+}
+
+// ListBackups returns the backups in root, most recent first.
+func ListBackups(root string) ([]*Backup, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(BackupDir)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backup, err := loadBackup(root, entry.Name())
+		if err != nil {
+			continue // Skip incomplete backups
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Created.After(backups[j].Created) })
+	return backups, nil
+}
+
+// loadBackup reads a backup's manifest. The id may come from the command
+// line, so anything but a generated id is refused before it is used in a
+// path.
+func loadBackup(root, id string) (*Backup, error) {
+	if !backupIDFormat.MatchString(id) {
+		return nil, fmt.Errorf("invalid backup id '%s'", id)
+	}
+	backup := &Backup{ID: id, root: root}
+	data, err := os.ReadFile(filepath.Join(backup.dir(), manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("backup '%s' not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("backup '%s' is corrupt: %w", id, err)
+	}
+	backup.ID = id // The directory name, whatever the manifest says
+	return backup, nil
+}
+
+// restore puts the backed up files with the given paths back in place,
+// removing those that didn't exist before. Manifest paths are checked
+// like patch paths, so an edited manifest cannot reach outside the root.
+func (b *Backup) restore(paths []string) error {
+	wanted := make(map[string]bool)
+	for _, path := range paths {
+		wanted[path] = true
+	}
+
+	var errs []error
+	for _, file := range b.Files {
+		if !wanted[file.Path] {
+			continue
+		}
+		path, err := cleanPath(b.root, file.Path)
+		if err == nil && path != file.Path {
+			err = fmt.Errorf("refusing to restore unclean path %s", file.Path)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		target := filepath.Join(b.root, filepath.FromSlash(path))
+		if !file.Existed {
+			if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(b.filePath(file.Path))
+		if err == nil {
+			err = writeFileAtomic(target, data, file.Mode)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Backup) dir() string {
+	return filepath.Join(b.root, filepath.FromSlash(BackupDir), b.ID)
+}
+
+func (b *Backup) filePath(path string) string {
+	return filepath.Join(b.dir(), "files", filepath.FromSlash(path))
+}
+
+func (b *Backup) remove() error {
+	return os.RemoveAll(b.dir())
+}
@@ -0,0 +1,276 @@
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Block is a fenced code block from a model response.
+type Block struct {
+	Lang string // Language from the opening fence, e.g. "go" or "diff"
+	Path string // File the block is for, if one was named
+	Code string // Contents of the block
+}
+
+// Hunk is a single hunk of a unified diff. Lines keep their " ", "+" or
+// "-" prefix.
+type Hunk struct {
+	OldStart int // First line of the hunk in the original file, 0 if unknown
+	Lines    []string
+}
+
+// Change is an edit to one file: either a set of diff hunks or a
+// replacement of the whole file.
+type Change struct {
+	Path    string
+	Hunks   []Hunk
+	Content string // New file contents when Replace is set
+	Replace bool
+	Delete  bool
+}
+
+var (
+	// pathPattern matches a relative file path with an extension or a
+	// directory, e.g. "main.go" or "cmd/app/main.go"
+	pathPattern = regexp.MustCompile(`^[\w.\-/]*(?:\w\.\w+|/[\w\-]+)$`)
+	// hunkHeaderPattern matches "@@ -12,5 +12,6 @@", capturing the old start
+	hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)`)
+	// pathAttrPattern matches title="x" or file=x in a fence info string
+	pathAttrPattern = regexp.MustCompile(`(?:title|file|filename|path)=["']?([^"'\s]+)`)
+)
+
+// Blocks returns the fenced code blocks in text. A block's path is taken
+// from the fence info string ("```go title=main.go", "```go:main.go"),
+// the line just before the fence ("**main.go**", "File: main.go") or a
+// "File:" comment on the block's first line.
+func Blocks(text string) []Block {
+	var blocks []Block
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+
+		fence := trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+		info := strings.TrimSpace(strings.TrimPrefix(trimmed, fence))
+		var body []string
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == fence {
+				break
+			}
+			body = append(body, lines[j])
+		}
+
+		block := Block{Code: strings.Join(body, "\n")}
+		block.Lang, block.Path = parseInfo(info)
+		if block.Path == "" {
+			block.Path = pathBefore(lines[:i])
+		}
+		if block.Path == "" && len(body) > 0 {
+			if path, ok := pathComment(body[0]); ok {
+				block.Path = path
+				block.Code = strings.Join(body[1:], "\n")
+			}
+		}
+		blocks = append(blocks, block)
+		i = j
+	}
+	return blocks
+}
+
+// IsDiff reports whether the block holds a unified diff.
+func (b Block) IsDiff() bool {
+	if b.Lang == "diff" || b.Lang == "patch" {
+		return true
+	}
+	code := strings.TrimSpace(b.Code)
+	return strings.HasPrefix(code, "--- ") || strings.HasPrefix(code, "diff --git ")
+}
+
+// Changes converts the block into file changes. Diffs may touch several
+// files; any other block replaces the file it names.
+func (b Block) Changes() ([]Change, error) {
+	if b.IsDiff() {
+		return ParseDiff(b.Code, b.Path)
+	}
+	if b.Path == "" {
+		return nil, fmt.Errorf("code block is neither a diff nor labelled with a file name")
+	}
+
+	content := b.Code
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return []Change{{Path: b.Path, Content: content, Replace: true}}, nil
+}
+
+// Extract returns all file changes found in a response. Blocks that are
+// not diffs and name no file are ignored.
+func Extract(text string) ([]Change, error) {
+	var changes []Change
+	for _, block := range Blocks(text) {
+		if !block.IsDiff() && block.Path == "" {
+			continue
+		}
+		blockChanges, err := block.Changes()
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, blockChanges...)
+	}
+	return changes, nil
+}
+
+// ParseDiff parses a unified diff. defaultPath is used for hunks that
+// have no "---"/"+++" file headers, as models often leave them out.
+func ParseDiff(diff, defaultPath string) ([]Change, error) {
+	var changes []Change
+	var current *Change
+
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "index "),
+			strings.HasPrefix(line, "new file mode"), strings.HasPrefix(line, "deleted file mode"):
+			continue
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// A "---" line directly followed by "+++" is a file header
+			// rather than a removed line
+			oldPath := diffPath(strings.TrimPrefix(line, "--- "), "a/")
+			newPath := diffPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+			change := Change{Path: newPath}
+			if newPath == "" {
+				change = Change{Path: oldPath, Delete: true}
+			}
+			changes = append(changes, change)
+			current = &changes[len(changes)-1]
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				if defaultPath == "" {
+					return nil, fmt.Errorf("diff hunk without a file header")
+				}
+				changes = append(changes, Change{Path: defaultPath})
+				current = &changes[len(changes)-1]
+			}
+			hunk := Hunk{}
+			if match := hunkHeaderPattern.FindStringSubmatch(line); match != nil {
+				hunk.OldStart, _ = strconv.Atoi(match[1])
+			}
+			current.Hunks = append(current.Hunks, hunk)
+		case current != nil && len(current.Hunks) > 0:
+			hunk := &current.Hunks[len(current.Hunks)-1]
+			switch {
+			case line == "":
+				// Models often drop the leading space of empty context lines
+				hunk.Lines = append(hunk.Lines, " ")
+			case line[0] == ' ' || line[0] == '+' || line[0] == '-':
+				hunk.Lines = append(hunk.Lines, line)
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no file changes found in diff")
+	}
+	for _, change := range changes {
+		if change.Path == "" {
+			return nil, fmt.Errorf("diff is missing a file name")
+		}
+		if !change.Delete && len(change.Hunks) == 0 {
+			return nil, fmt.Errorf("diff for %s has no hunks", change.Path)
+		}
+	}
+	return changes, nil
+}
+
+// diffPath strips the a/ or b/ prefix and any timestamp from a file
+// header; /dev/null becomes ""
+func diffPath(header, prefix string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// parseInfo splits a fence info string into a language and a path
+func parseInfo(info string) (string, string) {
+	if info == "" {
+		return "", ""
+	}
+	if match := pathAttrPattern.FindStringSubmatch(info); match != nil {
+		return strings.Fields(info)[0], match[1]
+	}
+
+	fields := strings.Fields(info)
+	lang := fields[0]
+	if l, path, ok := strings.Cut(lang, ":"); ok && looksLikePath(path) {
+		return l, path
+	}
+	if len(fields) > 1 && looksLikePath(fields[1]) {
+		return lang, fields[1]
+	}
+	// "```main.go" names the file directly
+	if strings.Contains(lang, ".") && looksLikePath(lang) {
+		return "", lang
+	}
+	return lang, ""
+}
+
+// pathBefore looks for a file name on the last non-empty line before a
+// fence
+func pathBefore(lines []string) string {
+	for i := len(lines) - 1; i >= 0 && i >= len(lines)-2; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		line = strings.Trim(line, "#*_`: ")
+		for _, prefix := range []string{"File:", "Filename:", "Path:", "file:", "filename:", "path:"} {
+			line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+		}
+		line = strings.Trim(line, "*_`: ")
+		if looksLikePath(line) {
+			return line
+		}
+		return ""
+	}
+	return ""
+}
+
+// pathComment recognises a "File: path" comment such as "// File: main.go"
+// or "# file: app.py"
+func pathComment(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	for _, marker := range []string{"//", "#", "--", "/*", "<!--"} {
+		if strings.HasPrefix(line, marker) {
+			line = strings.TrimSpace(strings.TrimPrefix(line, marker))
+			line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(line, "*/"), "-->"))
+			lower := strings.ToLower(line)
+			for _, prefix := range []string{"file:", "filename:", "path:"} {
+				if strings.HasPrefix(lower, prefix) {
+					path := strings.TrimSpace(line[len(prefix):])
+					return path, looksLikePath(path)
+				}
+			}
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// looksLikePath reports whether s could be a relative file path
+func looksLikePath(s string) bool {
+	switch s {
+	case "Makefile", "Dockerfile":
+		return true
+	}
+	return pathPattern.MatchString(s) && !strings.Contains(s, "..")
+}
@@ -0,0 +1,118 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlockPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Block
+	}{
+		{"title attribute", "```go title=\"cmd/main.go\"\npackage main\n```", Block{Lang: "go", Path: "cmd/main.go", Code: "package main"}},
+		{"colon after language", "```go:main.go\npackage main\n```", Block{Lang: "go", Path: "main.go", Code: "package main"}},
+		{"path after language", "```python app.py\nprint()\n```", Block{Lang: "python", Path: "app.py", Code: "print()"}},
+		{"file name as info", "```main.go\npackage main\n```", Block{Path: "main.go", Code: "package main"}},
+		{"bold line before", "**main.go**\n```go\npackage main\n```", Block{Lang: "go", Path: "main.go", Code: "package main"}},
+		{"file line before", "File: `src/app.js`\n\n```js\nrun()\n```", Block{Lang: "js", Path: "src/app.js", Code: "run()"}},
+		{"file comment", "```go\n// File: main.go\npackage main\n```", Block{Lang: "go", Path: "main.go", Code: "package main"}},
+		{"hash comment", "```\n# file: app.py\nprint()\n```", Block{Path: "app.py", Code: "print()"}},
+		{"no path", "Try this:\n```sh\nls -la\n```", Block{Lang: "sh", Code: "ls -la"}},
+		{"prose before is not a path", "Here is the fix.\n```go\nx := 1\n```", Block{Lang: "go", Code: "x := 1"}},
+		{"longer fence", "````md README.md\n```sh\nmake\n```\n````", Block{Lang: "md", Path: "README.md", Code: "```sh\nmake\n```"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := Blocks(tt.text)
+			if len(blocks) != 1 {
+				t.Fatalf("Blocks() found %d blocks, want 1", len(blocks))
+			}
+			if blocks[0] != tt.want {
+				t.Errorf("Blocks() = %+v, want %+v", blocks[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		diff        string
+		defaultPath string
+		want        []Change
+		wantErr     bool
+	}{
+		{
+			name: "git diff",
+			diff: "diff --git a/main.go b/main.go\nindex 1..2 100644\n--- a/main.go\n+++ b/main.go\n@@ -3,2 +3,2 @@ func main() {\n a\n-b\n+c\n",
+			want: []Change{{Path: "main.go", Hunks: []Hunk{{OldStart: 3, Lines: []string{" a", "-b", "+c"}}}}},
+		},
+		{
+			name: "two files",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-p\n+q\n",
+			want: []Change{
+				{Path: "a.go", Hunks: []Hunk{{OldStart: 1, Lines: []string{"-x", "+y"}}}},
+				{Path: "b.go", Hunks: []Hunk{{OldStart: 1, Lines: []string{"-p", "+q"}}}},
+			},
+		},
+		{
+			name:        "no file header",
+			diff:        "@@ -1,2 +1,2 @@\n a\n\n-b\n+c",
+			defaultPath: "x.txt",
+			want:        []Change{{Path: "x.txt", Hunks: []Hunk{{OldStart: 1, Lines: []string{" a", " ", "-b", "+c"}}}}},
+		},
+		{
+			name: "hunk header without numbers",
+			diff: "--- main.go\n+++ main.go\n@@\n-a\n+b\n",
+			want: []Change{{Path: "main.go", Hunks: []Hunk{{Lines: []string{"-a", "+b"}}}}},
+		},
+		{
+			name: "deleted file",
+			diff: "--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n",
+			want: []Change{{Path: "old.go", Delete: true, Hunks: []Hunk{{OldStart: 1, Lines: []string{"-x"}}}}},
+		},
+		{
+			name: "header timestamps",
+			diff: "--- a/main.go\t2024-01-01 10:00:00\n+++ b/main.go\t2024-01-01 10:01:00\n@@ -1 +1 @@\n-a\n+b\n",
+			want: []Change{{Path: "main.go", Hunks: []Hunk{{OldStart: 1, Lines: []string{"-a", "+b"}}}}},
+		},
+		{name: "hunk without a file", diff: "@@ -1 +1 @@\n-a\n+b\n", wantErr: true},
+		{name: "file without hunks", diff: "--- a/main.go\n+++ b/main.go\n", wantErr: true},
+		{name: "not a diff", diff: "hello\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDiff(tt.diff, tt.defaultPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDiff() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDiff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	response := "Change the greeting:\n\n```diff\n--- a/hello.go\n+++ b/hello.go\n@@ -1 +1 @@\n-hi\n+hello\n```\n\n" +
+		"Run it with:\n\n```sh\ngo run .\n```\n\nAnd add a README:\n\n```md README.md\n# Hello\n```\n"
+	got, err := Extract(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "hello.go", Hunks: []Hunk{{OldStart: 1, Lines: []string{"-hi", "+hello"}}}},
+		{Path: "README.md", Content: "# Hello\n", Replace: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract() = %+v, want %+v", got, want)
+	}
+}
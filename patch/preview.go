@@ -0,0 +1,241 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewContext is the number of unchanged lines shown around changes
+const previewContext = 3
+
+// maxDiffCells caps the size of the line diff table; larger files are
+// previewed as a plain replacement
+const maxDiffCells = 4_000_000
+
+var (
+	headerStyle  = lipgloss.NewStyle().Bold(true)
+	hunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// Summary describes the plan in one line per file, e.g. "M main.go (+3 -1)".
+func (p *Plan) Summary() []string {
+	var lines []string
+	for _, edit := range p.Edits {
+		added, removed := diffStats(edit)
+		status := "M"
+		switch {
+		case edit.Delete:
+			status = "D"
+		case !edit.Existed:
+			status = "A"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (+%d -%d)", status, edit.Path, added, removed))
+	}
+	return lines
+}
+
+// Preview renders the plan as a unified diff with coloured hunks.
+func (p *Plan) Preview() string {
+	var b strings.Builder
+	for _, edit := range p.Edits {
+		oldName, newName := "a/"+edit.Path, "b/"+edit.Path
+		if !edit.Existed {
+			oldName = "/dev/null"
+		}
+		if edit.Delete {
+			newName = "/dev/null"
+		}
+		b.WriteString(headerStyle.Render("--- "+oldName) + "\n")
+		b.WriteString(headerStyle.Render("+++ "+newName) + "\n")
+
+		for _, hunk := range diffHunks(splitLines(edit.Old), splitLines(edit.New)) {
+			b.WriteString(hunkStyle.Render(hunk.header) + "\n")
+			for _, line := range hunk.lines {
+				switch line[0] {
+				case '+':
+					b.WriteString(addedStyle.Render(line))
+				case '-':
+					b.WriteString(removedStyle.Render(line))
+				default:
+					b.WriteString(line)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// diffOp is one line of a line diff: ' ', '+' or '-' and the text
+type diffOp struct {
+	kind byte
+	text string
+}
+
+type previewHunk struct {
+	header string
+	lines  []string
+}
+
+// diffStats counts the added and removed lines of an edit
+func diffStats(edit FileEdit) (int, int) {
+	added, removed := 0, 0
+	for _, op := range lineDiff(splitLines(edit.Old), splitLines(edit.New)) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// lineDiff computes a line diff from the longest common subsequence
+func lineDiff(a, b []string) []diffOp {
+	// Strip the common prefix and suffix to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, lcsDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+func lcsDiff(a, b []string) []diffOp {
+	var ops []diffOp
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// diffHunks groups a line diff into hunks with a few lines of context
+func diffHunks(a, b []string) []previewHunk {
+	ops := lineDiff(a, b)
+
+	var hunks []previewHunk
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close together
+		from := max(0, start-previewContext)
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*previewContext {
+				break
+			}
+			end = next
+		}
+		to := min(len(ops), end+previewContext)
+
+		// Line numbers of the hunk start in each file
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		var lines []string
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+			lines = append(lines, string(op.kind)+op.text)
+		}
+
+		// An empty range starts at the line before it, as in diff -u
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+		hunks = append(hunks, previewHunk{
+			header: fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldLine, oldCount, newLine, newCount),
+			lines:  lines,
+		})
+		start = to
+	}
+	return hunks
+}
@@ -0,0 +1,126 @@
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FileDiff is the part of a unified diff that touches one file.
+type FileDiff struct {
+	Path  string   // Path of the file after the change
+	Hunks []string // Hunks, each starting with its "@@" header
+}
+
+// ParseDiff splits a unified diff from git into per-file hunks. Deleted
+// and binary files are left out as there is nothing left to review.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk strings.Builder
+
+	flushHunk := func() {
+		if current != nil && hunk.Len() > 0 {
+			current.Hunks = append(current.Hunks, hunk.String())
+		}
+		hunk.Reset()
+	}
+	flushFile := func() {
+		flushHunk()
+		if current != nil && current.Path != "" && len(current.Hunks) > 0 {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			current = &FileDiff{}
+		case current == nil:
+			continue
+		case hunk.Len() == 0 && strings.HasPrefix(line, "+++ "):
+			path := strings.TrimSpace(strings.TrimPrefix(line, "+++ "))
+			if path != "/dev/null" {
+				current.Path = strings.TrimPrefix(path, "b/")
+			}
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk.WriteString(line)
+		case hunk.Len() > 0:
+			hunk.WriteString(line)
+		}
+	}
+	flushFile()
+	return files
+}
+
+// Chunks groups a file's hunks into pieces of at most limit bytes, so a
+// large file is reviewed over several requests. Every line is prefixed
+// with its line number in the new file to help the model cite lines.
+func Chunks(file FileDiff, limit int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	for _, hunk := range file.Hunks {
+		annotated := annotateHunk(hunk)
+		if len(annotated) > limit {
+			// Back up to the start of a character rather than split one
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(annotated[cut]) {
+				cut--
+			}
+			annotated = annotated[:cut] + "\n... (hunk truncated)\n"
+		}
+		if chunk.Len() > 0 && chunk.Len()+len(annotated) > limit {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+		chunk.WriteString(annotated)
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+// annotateHunk prefixes added and unchanged lines with their line number
+// in the new file; removed lines get a blank prefix.
+func annotateHunk(hunk string) string {
+	lines := strings.Split(strings.TrimRight(hunk, "\n"), "\n")
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(lines[0] + "\n")
+	line := hunkStart(lines[0])
+	for _, text := range lines[1:] {
+		switch {
+		case strings.HasPrefix(text, "-"):
+			fmt.Fprintf(&b, "%6s %s\n", "", text)
+		case strings.HasPrefix(text, `\`): // "\ No newline at end of file"
+			fmt.Fprintf(&b, "%6s %s\n", "", text)
+		default:
+			fmt.Fprintf(&b, "%6d %s\n", line, text)
+			line++
+		}
+	}
+	return b.String()
+}
+
+// hunkStart returns the first new-file line of a hunk from its header,
+// e.g. 12 for "@@ -10,4 +12,6 @@".
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	for _, field := range fields {
+		if strings.HasPrefix(field, "+") {
+			start, _, _ := strings.Cut(field[1:], ",")
+			if n, err := strconv.Atoi(start); err == nil {
+				return n
+			}
+		}
+	}
+	return 1
+}
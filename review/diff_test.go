@@ -0,0 +1,150 @@
+package review
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []FileDiff
+	}{
+		{
+			name: "modified file",
+			diff: "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n" +
+				"@@ -1,3 +1,3 @@\n package main\n-var x = 1\n+var x = 2\n",
+			want: []FileDiff{{Path: "main.go", Hunks: []string{"@@ -1,3 +1,3 @@\n package main\n-var x = 1\n+var x = 2\n"}}},
+		},
+		{
+			name: "several files and hunks",
+			diff: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+A\n@@ -10 +10 @@\n-b\n+B\n" +
+				"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -5 +5 @@\n-c\n+C\n",
+			want: []FileDiff{
+				{Path: "a.go", Hunks: []string{"@@ -1 +1 @@\n-a\n+A\n", "@@ -10 +10 @@\n-b\n+B\n"}},
+				{Path: "b.go", Hunks: []string{"@@ -5 +5 @@\n-c\n+C\n"}},
+			},
+		},
+		{
+			name: "new file",
+			diff: "diff --git a/new.go b/new.go\nnew file mode 100644\nindex 0000000..3333333\n--- /dev/null\n+++ b/new.go\n" +
+				"@@ -0,0 +1,2 @@\n+package main\n+\n",
+			want: []FileDiff{{Path: "new.go", Hunks: []string{"@@ -0,0 +1,2 @@\n+package main\n+\n"}}},
+		},
+		{
+			name: "deleted file",
+			diff: "diff --git a/old.go b/old.go\ndeleted file mode 100644\n--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package main\n",
+			want: nil,
+		},
+		{
+			name: "renamed and changed",
+			diff: "diff --git a/old.go b/pkg/new.go\nsimilarity index 90%\nrename from old.go\nrename to pkg/new.go\n" +
+				"--- a/old.go\n+++ b/pkg/new.go\n@@ -1 +1 @@\n-package main\n+package pkg\n",
+			want: []FileDiff{{Path: "pkg/new.go", Hunks: []string{"@@ -1 +1 @@\n-package main\n+package pkg\n"}}},
+		},
+		{
+			name: "pure rename",
+			diff: "diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n",
+			want: nil,
+		},
+		{
+			name: "binary file",
+			diff: "diff --git a/logo.png b/logo.png\nindex 1..2 100644\nBinary files a/logo.png and b/logo.png differ\n",
+			want: nil,
+		},
+		{
+			name: "no newline marker",
+			diff: "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new\n\\ No newline at end of file\n",
+			want: []FileDiff{{Path: "a.txt", Hunks: []string{"@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new\n\\ No newline at end of file\n"}}},
+		},
+		{
+			name: "removed line that looks like a header",
+			diff: "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1 @@\n keep\n--- dashes\n",
+			want: []FileDiff{{Path: "a.txt", Hunks: []string{"@@ -1,2 +1 @@\n keep\n--- dashes\n"}}},
+		},
+		{
+			name: "not a git diff",
+			diff: "hello\n",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseDiff(tt.diff); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotateHunk(t *testing.T) {
+	tests := []struct {
+		name string
+		hunk string
+		want string
+	}{
+		{
+			name: "numbers new lines",
+			hunk: "@@ -10,3 +12,3 @@ func main() {\n a\n-b\n+c\n d\n",
+			want: "@@ -10,3 +12,3 @@ func main() {\n" +
+				"    12  a\n" +
+				"       -b\n" +
+				"    13 +c\n" +
+				"    14  d\n",
+		},
+		{
+			name: "no newline marker takes no number",
+			hunk: "@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new\n\\ No newline at end of file\n",
+			want: "@@ -1 +1 @@\n" +
+				"       -old\n" +
+				"       \\ No newline at end of file\n" +
+				"     1 +new\n" +
+				"       \\ No newline at end of file\n",
+		},
+		{
+			name: "new file",
+			hunk: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want: "@@ -0,0 +1,2 @@\n     1 +a\n     2 +b\n",
+		},
+		{
+			name: "header without counts",
+			hunk: "@@ -3 +7 @@\n-x\n+y\n",
+			want: "@@ -3 +7 @@\n       -x\n     7 +y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := annotateHunk(tt.hunk); got != tt.want {
+				t.Errorf("annotateHunk() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	file := FileDiff{Path: "a.go", Hunks: []string{
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"@@ -5 +5 @@\n-c\n+d\n",
+		"@@ -9 +9 @@\n-e\n+" + strings.Repeat("é", 200) + "\n",
+	}}
+
+	chunks := Chunks(file, 61)
+	if len(chunks) != 3 {
+		t.Fatalf("Chunks() returned %d chunks, want 3: %q", len(chunks), chunks)
+	}
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk is not valid UTF-8: %q", chunk)
+		}
+	}
+	if !strings.HasSuffix(chunks[2], "... (hunk truncated)\n") {
+		t.Errorf("long hunk was not truncated: %q", chunks[2])
+	}
+
+	if chunks := Chunks(file, 1000); len(chunks) != 1 {
+		t.Errorf("Chunks() split hunks that fit the limit into %d chunks", len(chunks))
+	}
+}
@@ -0,0 +1,117 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Finding severities, from most to least serious.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Finding is a single issue reported by the reviewer.
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Schema is the JSON schema the model's response must follow.
+var Schema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "line": {"type": "integer"},
+          "severity": {"type": "string", "enum": ["error", "warning", "info"]},
+          "message": {"type": "string"}
+        },
+        "required": ["line", "severity", "message"]
+      }
+    }
+  },
+  "required": ["findings"]
+}`)
+
+// SystemPrompt instructs the model how to review a diff.
+const SystemPrompt = "You are an experienced code reviewer. You are given part of a diff for one file. " +
+	"Each line is prefixed with its line number in the new version of the file; lines starting with '+' were added " +
+	"and lines starting with '-' were removed. Review only the changed lines, using the unchanged lines as context. " +
+	"Report bugs, security problems, performance issues and unclear code. Use severity 'error' for bugs and " +
+	"vulnerabilities, 'warning' for likely problems and 'info' for suggestions. Cite the new-file line number of " +
+	"each finding. Keep messages short and concrete. If there is nothing worth reporting, return an empty list. " +
+	`Respond with JSON of the form {"findings": [{"line": 12, "severity": "warning", "message": "..."}]}.`
+
+// Prompt builds the user prompt for one chunk of a file's diff.
+func Prompt(path, chunk string) string {
+	return fmt.Sprintf("Review the changes to %s:\n\n%s", path, chunk)
+}
+
+// ParseFindings decodes the model's response for a file. Unknown
+// severities are treated as info and empty messages are dropped.
+func ParseFindings(path, response string) ([]Finding, error) {
+	var parsed struct {
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid review response for %s: %w", path, err)
+	}
+
+	var findings []Finding
+	for _, finding := range parsed.Findings {
+		finding.Message = strings.TrimSpace(finding.Message)
+		if finding.Message == "" {
+			continue
+		}
+		finding.File = path
+		finding.Severity = strings.ToLower(finding.Severity)
+		if severityRank(finding.Severity) < 0 {
+			finding.Severity = SeverityInfo
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// Sort orders findings by file and then by line.
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
+
+// Count returns the number of findings with each severity.
+func Count(findings []Finding) map[string]int {
+	counts := make(map[string]int)
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+	return counts
+}
+
+// severityRank returns 0 for the most serious severity, or -1 if the
+// severity is unknown.
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityInfo:
+		return 2
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/config"
+	"github.com/hariharen9/lamacli/filecontext"
+	"github.com/hariharen9/lamacli/llm"
+	"github.com/hariharen9/lamacli/templates"
+)
+
+// Commands are the prompt commands exposed over HTTP.
+var Commands = []string{"ask", "suggest", "explain"}
+
+// Options configures a Server.
+type Options struct {
+	Config  *config.Config
+	Client  *llm.OllamaClient
+	History *chathistory.ChatHistoryManager
+	Token   string // Bearer token every request except /v1/health must carry
+	Version string
+
+	// SystemPrompt returns the system prompt for a command, or custom when
+	// it is set, so the server uses the same prompts as the CLI
+	SystemPrompt func(command, custom string) string
+}
+
+// Request is the body of a prompt request.
+type Request struct {
+	Prompt     string          `json:"prompt"`
+	Model      string          `json:"model,omitempty"`
+	System     string          `json:"system,omitempty"`
+	Template   string          `json:"template,omitempty"` // Template the prompt is filled into
+	Context    *ContextRequest `json:"context,omitempty"`
+	Session    string          `json:"session,omitempty"` // Session ID or "last" to continue
+	NewSession bool            `json:"new_session,omitempty"`
+	Stream     bool            `json:"stream,omitempty"` // Stream the response as server-sent events
+}
+
+// ContextRequest selects files to attach to a prompt, like --context.
+type ContextRequest struct {
+	Path      string   `json:"path"`
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	MaxTokens int      `json:"max_tokens,omitempty"`
+	Strategy  string   `json:"strategy,omitempty"`
+}
+
+// Response is the result of a prompt request, and the final "done" event
+// when streaming.
+type Response struct {
+	Model        string   `json:"model"`
+	Response     string   `json:"response"`
+	SessionID    string   `json:"session_id,omitempty"`
+	ContextFiles []string `json:"context_files,omitempty"`
+}
+
+// SessionSummary describes a saved session without its messages.
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Summary   string    `json:"summary,omitempty"`
+	Model     string    `json:"model"`
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Server serves lamacli's prompts, sessions and templates over HTTP.
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+	mu   sync.Mutex // Serialises session writes
+}
+
+// New creates a Server.
+func New(opts Options) *Server {
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	s.mux.HandleFunc("GET /v1/templates", s.handleTemplates)
+	s.mux.HandleFunc("GET /v1/sessions", s.handleListSessions)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.handleGetSession)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.handleDeleteSession)
+	for _, command := range Commands {
+		s.mux.HandleFunc("POST /v1/"+command, s.handlePrompt(command))
+	}
+	return s
+}
+
+// ServeHTTP checks the bearer token and dispatches the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/health" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lamacli"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "version": s.opts.Version})
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	models, err := s.opts.Client.ListModels()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"templates": templates.All()})
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	var query chathistory.Query
+	for name, value := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if param := r.URL.Query().Get(name); param != "" {
+			n, err := strconv.Atoi(param)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be a non-negative number", name))
+				return
+			}
+			*value = n
+		}
+	}
+
+	page, err := s.opts.History.ListSessionInfo(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	summaries := make([]SessionSummary, 0, len(page.Sessions))
+	for _, session := range page.Sessions {
+		summaries = append(summaries, summarize(session))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": summaries, "total": page.Total})
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, status, err := s.loadSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	session, status, err := s.loadSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.opts.History.DeleteSession(session.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePrompt answers a prompt with the command's system prompt, either
+// as one JSON response or as a stream of server-sent events
+func (s *Server) handlePrompt(command string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		if strings.TrimSpace(req.Prompt) == "" {
+			writeError(w, http.StatusBadRequest, "prompt is required")
+			return
+		}
+		if req.Session != "" && req.NewSession {
+			writeError(w, http.StatusBadRequest, "session and new_session cannot be used together")
+			return
+		}
+
+		prompt := req.Prompt
+		if req.Template != "" {
+			template, ok := templates.Get(req.Template)
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown template '%s'", req.Template))
+				return
+			}
+			prompt = template.Apply(prompt)
+		}
+
+		// Load the session to continue, or start a new one
+		var session *chathistory.ChatSession
+		if req.Session != "" {
+			var status int
+			var err error
+			if session, status, err = s.loadSession(req.Session); err != nil {
+				writeError(w, status, err.Error())
+				return
+			}
+		} else if req.NewSession {
+			session = &chathistory.ChatSession{}
+		}
+
+		model, err := s.model(command, req.Model, session)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		var contextFiles []string
+		if req.Context != nil {
+			result, err := s.buildContext(req.Context)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to build context: %v", err))
+				return
+			}
+			for _, file := range result.Included {
+				contextFiles = append(contextFiles, file.Path)
+			}
+			if result.Content != "" {
+				prompt = fmt.Sprintf("%s\n\nContext:\n%s", prompt, result.Content)
+			}
+		}
+
+		history := []string{prompt}
+		if session != nil {
+			history = append(append([]string{}, session.History...), prompt)
+		}
+		systemPrompt := s.opts.SystemPrompt(command, req.System)
+		response := Response{Model: model, ContextFiles: contextFiles}
+
+		if !req.Stream {
+			text, err := s.opts.Client.Complete(r.Context(), model, systemPrompt, history)
+			if err != nil {
+				writeError(w, http.StatusBadGateway, err.Error())
+				return
+			}
+			response.Response = text
+			if response.SessionID, err = s.saveTurn(session, model, prompt, text); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+
+		events := newEventWriter(w)
+		text, err := s.opts.Client.Stream(r.Context(), model, systemPrompt, history, func(chunk string) error {
+			return events.send("chunk", map[string]string{"text": chunk})
+		})
+		if err != nil {
+			events.send("error", map[string]string{"error": err.Error()})
+			return
+		}
+		response.Response = text
+		if response.SessionID, err = s.saveTurn(session, model, prompt, text); err != nil {
+			events.send("error", map[string]string{"error": err.Error()})
+			return
+		}
+		events.send("done", response)
+	}
+}
+
+// model picks the request's model, then the session's, then the configured
+// one, then the first installed model
+func (s *Server) model(command, requested string, session *chathistory.ChatSession) (string, error) {
+	if requested != "" {
+		return requested, nil
+	}
+	if session != nil && session.Model != "" {
+		return session.Model, nil
+	}
+	if model := s.opts.Config.ModelFor(command); model != "" {
+		return model, nil
+	}
+	models, err := s.opts.Client.ListModels()
+	if err != nil {
+		return "", err
+	}
+	if len(models) == 0 {
+		return "", errors.New("no models installed")
+	}
+	return models[0], nil
+}
+
+// buildContext collects files like the CLI's --context flag
+func (s *Server) buildContext(req *ContextRequest) (*filecontext.Result, error) {
+	strategy, err := filecontext.ParseStrategy(req.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = s.opts.Config.ContextLimit / 4
+	}
+	return filecontext.Build(filecontext.Options{
+		Root:      req.Path,
+		Include:   req.Include,
+		Exclude:   req.Exclude,
+		MaxTokens: maxTokens,
+		Strategy:  strategy,
+	})
+}
+
+// loadSession loads a session by ID or "last", returning the HTTP status
+// to report on failure
+func (s *Server) loadSession(ref string) (*chathistory.ChatSession, int, error) {
+	session, err := s.opts.History.LoadSessionRef(ref)
+	if errors.Is(err, chathistory.ErrInvalidSessionID) {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusNotFound, fmt.Errorf("session '%s' not found", ref)
+	}
+	return session, http.StatusOK, nil
+}
+
+// saveTurn appends a prompt and its response to the session, if any, and
+// returns the session ID, which is a new one if the session was changed
+// elsewhere since it was loaded
+func (s *Server) saveTurn(session *chathistory.ChatSession, model, prompt, response string) (string, error) {
+	if session == nil {
+		return "", nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.Model = model
+	session.History = append(session.History, prompt, response)
+	err := s.opts.History.SaveSession(session)
+	if errors.Is(err, chathistory.ErrConflict) {
+		// Another client added turns meanwhile; the returned ID tells the
+		// caller where this turn went
+		err = s.opts.History.SaveSessionCopy(session)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+	return session.ID, nil
+}
+
+func summarize(session chathistory.SessionInfo) SessionSummary {
+	return SessionSummary{
+		ID:        session.ID,
+		Title:     session.Title,
+		Summary:   session.Summary,
+		Model:     session.Model,
+		Messages:  session.Messages,
+		CreatedAt: session.CreatedAt,
+		UpdatedAt: session.UpdatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,53 @@
+package templates
+
+import "strings"
+
+// Placeholder marks where the user's code goes in a template.
+const Placeholder = "[Paste your code here]"
+
+// Template is a reusable prompt for a common task.
+type Template struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// builtin are the templates shared by the chat and the HTTP server, in the
+// order the chat cycles through them
+var builtin = []Template{
+	{
+		Name: "Code Review",
+		Body: "### Code Review Template\nReview the code provided below thoroughly, addressing the following aspects:\n1. **Readability**: Is the code easily understandable? Provide suggestions for improving readability if necessary.\n2. **Performance**: Identify any bottlenecks or optimizations that can be applied.\n3. **Best Practices**: Ensure the code adheres to language and industry best practices.\n4. **Errors and Bugs**: Highlight potential bugs or errors with recommendations for fixes.\n5. **Security**: Check for security vulnerabilities and suggest mitigations.\n\nPlease provide detailed comments or annotations within the code:\n\n\n```\n[Paste your code here] \n```",
+	},
+	{
+		Name: "Documentation",
+		Body: "### Documentation Template\nGenerate comprehensive documentation for the following code or API, including:\n1. **Overview**: A brief description of the functionality and purpose.\n2. **Usage**: Include code snippets demonstrating how to effectively use the code/API.\n3. **Input Parameters**: List all parameters, including types and descriptions.\n4. **Return Values**: Document return types and their meanings.\n5. **Examples**: Provide example use cases.\n6. **Notes**: Any additional information or caveats.\n\n\n```\n[Paste your code here]\n```",
+	},
+	{
+		Name: "Debugging",
+		Body: "### Debugging Template\nHelp debug the code by diagnosing the issue described and suggesting solutions. Include:\n1. **Problem Description**: Elaborate on the encountered issue.\n2. **Expected Behavior**: Detail the expected outcome of the code.\n3. **Observed Behavior**: Describe what actually happens.\n4. **Error Messages**: Include any error messages or logs.\n5. **Analysis**: Provide an analysis of potential root causes.\n6. **Solutions**: Suggest fixes or alternative approaches.\n\nAdditional context or setup that may be useful:\n\n\n```\n[Paste your code here] \n```",
+	},
+}
+
+// All returns the available templates.
+func All() []Template {
+	return append([]Template{}, builtin...)
+}
+
+// Get finds a template by name, ignoring case.
+func Get(name string) (Template, bool) {
+	for _, t := range builtin {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
+
+// Apply fills the template with the given code, or appends it when the
+// template has no placeholder.
+func (t Template) Apply(code string) string {
+	if strings.Contains(t.Body, Placeholder) {
+		return strings.Replace(t.Body, Placeholder, code, 1)
+	}
+	return t.Body + "\n\n" + code
+}
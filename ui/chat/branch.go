@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/ui/styles"
+)
+
+// branching is the state of picking an earlier message to edit, or
+// switching between the branches of the conversation
+type branching struct {
+	selecting bool // Picking a user message
+	selected  int  // History index of the picked message
+	editing   bool // The picked message is in the input, to be resent
+}
+
+// userMessages returns the history indexes of the messages the user sent
+func (m *Model) userMessages() []int {
+	var indexes []int
+	for i := 0; i < len(m.History); i += 2 {
+		if m.History[i] != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// startSelecting enters message selection at the latest user message
+func (m *Model) startSelecting() bool {
+	indexes := m.userMessages()
+	if len(indexes) == 0 {
+		return false
+	}
+	m.syncTree()
+	m.branch = branching{selecting: true, selected: indexes[len(indexes)-1]}
+	m.showCodeHelp = false
+	m.renderViewport()
+	m.ScrollToMessage(m.branch.selected)
+	return true
+}
+
+// CancelEdit leaves message selection or editing, reporting whether either
+// was active.
+func (m *Model) CancelEdit() bool {
+	if !m.branch.selecting && !m.branch.editing {
+		return false
+	}
+	if m.branch.editing {
+		m.TextInput.SetValue("")
+	}
+	m.branch = branching{}
+	m.renderViewport()
+	return true
+}
+
+// handleSelectKey moves the selection between user messages, switches the
+// selected message's branch or starts editing it
+func (m *Model) handleSelectKey(key string) {
+	indexes := m.userMessages()
+	position := 0
+	for i, index := range indexes {
+		if index == m.branch.selected {
+			position = i
+		}
+	}
+
+	switch key {
+	case "k", "up":
+		position = max(0, position-1)
+	case "j", "down":
+		position = min(len(indexes)-1, position+1)
+	case "h", "left":
+		m.switchBranch(-1)
+		return
+	case "l", "right":
+		m.switchBranch(1)
+		return
+	case "enter", "e":
+		m.branch.selecting = false
+		m.branch.editing = true
+		m.TextInput.SetValue(m.History[m.branch.selected])
+		m.TextInput.CursorEnd()
+		m.renderViewport()
+		return
+	case "B":
+		m.CancelEdit()
+		return
+	default:
+		return
+	}
+	m.branch.selected = indexes[position]
+	m.renderViewport()
+	m.ScrollToMessage(m.branch.selected)
+}
+
+// switchBranch shows another version of the selected message, with the
+// conversation that followed it
+func (m *Model) switchBranch(delta int) {
+	session := m.syncTree()
+	if !session.SwitchBranch(m.branch.selected, delta) {
+		return
+	}
+	m.History = append([]string{}, session.History...)
+	m.codeBlocks = nil
+	m.selectedCode = 0
+	m.renderViewport()
+	m.ScrollToMessage(m.branch.selected)
+	m.notice = ""
+}
+
+// branchHistory returns the history to send an edited message after: the
+// conversation before the message, which is recorded in the tree first so
+// the original continuation is kept as a branch
+func (m *Model) branchHistory() []string {
+	m.syncTree()
+	history := append([]string{}, m.History[:m.branch.selected]...)
+	m.branch = branching{}
+	return history
+}
+
+// syncTree records the chat in the current session's message tree,
+// creating the session if the chat has none yet
+func (m *Model) syncTree() *chathistory.ChatSession {
+	if m.currentSession == nil {
+		m.currentSession = &chathistory.ChatSession{ID: chathistory.NewSessionID()}
+	}
+	m.currentSession.History = append([]string{}, m.History...)
+	m.currentSession.SyncTree()
+	return m.currentSession
+}
+
+// branchLabel returns the branch indicator of the message at a history
+// index, empty when it has no alternatives. Alternate replies also name
+// the model that wrote them.
+func (m *Model) branchLabel(index int) string {
+	if m.currentSession == nil {
+		return ""
+	}
+	position, count := m.currentSession.Branch(index)
+	if count < 2 {
+		return ""
+	}
+	if index%2 == 0 {
+		return fmt.Sprintf(" 🌿 %d/%d", position, count)
+	}
+	label := fmt.Sprintf(" 🔄 %d/%d", position, count)
+	if message, ok := m.currentSession.MessageAt(index); ok && message.Model != "" {
+		label += " • " + message.Model
+	}
+	return label
+}
+
+// branchHelp renders the footer shown while selecting or editing a message
+func (m Model) branchHelp() string {
+	helpStyle := lipgloss.NewStyle().
+		Foreground(styles.StatusStyle().GetForeground()).
+		Bold(true).
+		MarginTop(1)
+
+	if m.branch.editing {
+		return helpStyle.Render("✏️ Editing message • Enter: Send as a new branch • Esc: Cancel")
+	}
+
+	position := 0
+	indexes := m.userMessages()
+	for i, index := range indexes {
+		if index == m.branch.selected {
+			position = i + 1
+		}
+	}
+	text := fmt.Sprintf("🌿 Message %d/%d • ↑/↓ or j/k: Select • Enter: Edit and resend • Esc: Cancel", position, len(indexes))
+	if label := strings.TrimSpace(m.branchLabel(m.branch.selected)); label != "" {
+		text += "\n" + label + " versions of this message • ←/→ or h/l: Switch branch"
+	}
+	return helpStyle.Render(text)
+}
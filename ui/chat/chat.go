@@ -1,9 +1,9 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
 	os "os"
-	"regexp"
 	"runtime"
 	"strings"
 
@@ -15,7 +15,12 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/config"
+	"github.com/hariharen9/lamacli/export"
 	"github.com/hariharen9/lamacli/llm"
+	"github.com/hariharen9/lamacli/mcp"
+	"github.com/hariharen9/lamacli/patch"
+	"github.com/hariharen9/lamacli/templates"
 	"github.com/hariharen9/lamacli/ui/styles"
 )
 
@@ -40,6 +45,8 @@ type Model struct {
 	TextInput       textinput.Model
 	llmClient       *llm.OllamaClient
 	SelectedModel   string
+	Profile         string // Active config profile, shown in the header
+	TitleModel      string // Model writing session titles, empty for SelectedModel
 	History         []string
 	streaming       bool
 	ready           bool
@@ -48,19 +55,39 @@ type Model struct {
 	width           int
 	height          int
 	renderer        *glamour.TermRenderer
-	codeBlocks      []string                 // Store extracted code blocks
+	codeBlocks      []patch.Block            // Store extracted code blocks
 	selectedCode    int                      // Currently selected code block for copying
 	showCodeHelp    bool                     // Show code copy help
+	pendingPatch    *patch.Plan              // Patch previewed and awaiting confirmation
+	notice          string                   // Result of the last applied patch
+	exporting       bool                     // Waiting for the export format key
+	branch          branching                // Editing an earlier message or switching branches
+	regen           regenerating             // Choosing how to regenerate the last reply
+	replyClient     *llm.OllamaClient        // Client writing the current reply
+	replyModel      string                   // Model writing the current reply
 	ContextFileName string                   // Name of the file added to context
 	currentSession  *chathistory.ChatSession // Current chat session for auto-saving
+	summarizing     string                   // Session whose summary was requested
+	messageLines    []int                    // Viewport line where each message starts
+	systemPrompt    string                   // System prompt sent with every request
+	glamourStyle    string                   // Glamour style matching the configured theme
+	renderWidth     int                      // Maximum word wrap width for responses
+
+	// MCP tools the model may call while replying
+	mcp          *mcp.Manager
+	eventChan    chan llm.StreamEvent // Stream of a reply generated with tools
+	toolSteps    []llm.ToolStep       // Tool rounds completed in the current reply
+	stepStart    int                  // Offset in the reply where the current round's text starts
+	pendingCalls []llm.ToolCall       // Tool calls awaiting approval
+	allowedTools map[string]bool      // Tools the user allowed for the rest of the session
 
 	// New field for chat templates
-	chatTemplates    map[string]string
+	chatTemplates    []templates.Template
 	selectedTemplate string
 }
 
 // New creates a new chat model.
-func New(llmClient *llm.OllamaClient, selectedModel string) Model {
+func New(llmClient *llm.OllamaClient, selectedModel string, cfg *config.Config) Model {
 	ti := textinput.New()
 	ti.Placeholder = "Type your message here..."
 	ti.Focus()
@@ -82,8 +109,8 @@ func New(llmClient *llm.OllamaClient, selectedModel string) Model {
 
 	// Initialize markdown renderer with custom styling
 	renderer, _ := glamour.NewTermRenderer(
-		glamour.WithStylePath("dark"),
-		glamour.WithWordWrap(80),
+		glamour.WithStylePath(cfg.Theme),
+		glamour.WithWordWrap(min(80, cfg.RenderWidth)),
 	)
 
 	// Add welcome message to history
@@ -94,42 +121,69 @@ func New(llmClient *llm.OllamaClient, selectedModel string) Model {
 		TextInput:     ti,
 		llmClient:     llmClient,
 		SelectedModel: selectedModel,
+		Profile:       cfg.ActiveProfile(),
+		TitleModel:    cfg.ModelFor("titles"),
 		History:       []string{"", welcomeMessage}, // Empty user message, then welcome
 		renderer:      renderer,
-		codeBlocks:    []string{},
+		systemPrompt:  cfg.DefaultPrompt(),
+		glamourStyle:  cfg.Theme,
+		renderWidth:   cfg.RenderWidth,
+		codeBlocks:    []patch.Block{},
 		selectedCode:  0,
 		showCodeHelp:  false,
 
 		// Initialize chat templates
-		chatTemplates: map[string]string{
-			"Code Review":   "### Code Review Template\nReview the code provided below thoroughly, addressing the following aspects:\n1. **Readability**: Is the code easily understandable? Provide suggestions for improving readability if necessary.\n2. **Performance**: Identify any bottlenecks or optimizations that can be applied.\n3. **Best Practices**: Ensure the code adheres to language and industry best practices.\n4. **Errors and Bugs**: Highlight potential bugs or errors with recommendations for fixes.\n5. **Security**: Check for security vulnerabilities and suggest mitigations.\n\nPlease provide detailed comments or annotations within the code:\n\n\n```\n[Paste your code here] \n```",
-			"Documentation": "### Documentation Template\nGenerate comprehensive documentation for the following code or API, including:\n1. **Overview**: A brief description of the functionality and purpose.\n2. **Usage**: Include code snippets demonstrating how to effectively use the code/API.\n3. **Input Parameters**: List all parameters, including types and descriptions.\n4. **Return Values**: Document return types and their meanings.\n5. **Examples**: Provide example use cases.\n6. **Notes**: Any additional information or caveats.\n\n\n```\n[Paste your code here]\n```",
-			"Debugging":     "### Debugging Template\nHelp debug the code by diagnosing the issue described and suggesting solutions. Include:\n1. **Problem Description**: Elaborate on the encountered issue.\n2. **Expected Behavior**: Detail the expected outcome of the code.\n3. **Observed Behavior**: Describe what actually happens.\n4. **Error Messages**: Include any error messages or logs.\n5. **Analysis**: Provide an analysis of potential root causes.\n6. **Solutions**: Suggest fixes or alternative approaches.\n\nAdditional context or setup that may be useful:\n\n\n```\n[Paste your code here] \n```",
-		},
+		chatTemplates:    templates.All(),
 		selectedTemplate: "",
 	}
 }
 
-// extractCodeBlocks extracts code blocks from markdown text
-func extractCodeBlocks(text string) []string {
-	codeBlockRegex := regexp.MustCompile("```(?:[a-zA-Z0-9_+-]*\\n)?([\\s\\S]*?)```")
-	matches := codeBlockRegex.FindAllStringSubmatch(text, -1)
-
-	var codeBlocks []string
-	for _, match := range matches {
-		if len(match) > 1 {
-			codeBlocks = append(codeBlocks, strings.TrimSpace(match[1]))
-		}
+// copySelectedCodeBlock copies the selected code block to clipboard
+func (m *Model) copySelectedCodeBlock() error {
+	if len(m.codeBlocks) == 0 || m.selectedCode >= len(m.codeBlocks) {
+		return fmt.Errorf("no code block selected")
 	}
-	return codeBlocks
+	return clipboard.WriteAll(strings.TrimSpace(m.codeBlocks[m.selectedCode].Code))
 }
 
-// copySelectedCodeBlock copies the selected code block to clipboard
-func (m *Model) copySelectedCodeBlock() error {
+// previewSelectedCodeBlock prepares the selected code block as a patch
+// to the files in the current directory and shows it for confirmation
+func (m *Model) previewSelectedCodeBlock() error {
 	if len(m.codeBlocks) == 0 || m.selectedCode >= len(m.codeBlocks) {
 		return fmt.Errorf("no code block selected")
 	}
-	return clipboard.WriteAll(m.codeBlocks[m.selectedCode])
+	changes, err := m.codeBlocks[m.selectedCode].Changes()
+	if err != nil {
+		return err
+	}
+	plan, err := patch.Prepare(".", changes)
+	if err != nil {
+		return err
+	}
+	m.pendingPatch = plan
+	return nil
+}
+
+// applyPendingPatch writes the previewed patch
+func (m *Model) applyPendingPatch() {
+	plan := m.pendingPatch
+	m.pendingPatch = nil
+	backup, err := plan.Apply()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.notice = fmt.Sprintf("✅ Applied to %d file(s) • Undo with 'lamacli undo %s'", len(plan.Edits), backup.ID)
+}
+
+// CancelPendingPatch discards a previewed patch, reporting whether there
+// was one
+func (m *Model) CancelPendingPatch() bool {
+	if m.pendingPatch == nil {
+		return false
+	}
+	m.pendingPatch = nil
+	return true
 }
 
 // SetModel updates the selected model without recreating the entire chat
@@ -138,22 +192,30 @@ func (m *Model) SetModel(selectedModel string) {
 	// Keep existing history and UI state
 }
 
+// SetProfile switches the chat to a config profile's client, model and
+// system prompt, keeping the existing history
+func (m *Model) SetProfile(profile string, llmClient *llm.OllamaClient, selectedModel, systemPrompt string) {
+	m.Profile = profile
+	m.llmClient = llmClient
+	m.SelectedModel = selectedModel
+	m.systemPrompt = systemPrompt
+}
+
 // cycleTemplate cycles through the available chat templates.
 func (m *Model) cycleTemplate() {
-	templates := []string{"Code Review", "Documentation", "Debugging"}
 	idx := -1
-	for i, template := range templates {
-		if template == m.selectedTemplate {
+	for i, template := range m.chatTemplates {
+		if template.Name == m.selectedTemplate {
 			idx = i
 			break
 		}
 	}
 
-	idx = (idx + 1) % len(templates)
-	m.selectedTemplate = templates[idx]
+	idx = (idx + 1) % len(m.chatTemplates)
+	m.selectedTemplate = m.chatTemplates[idx].Name
 
 	// Update the text input with the selected template
-	m.TextInput.SetValue(m.chatTemplates[m.selectedTemplate])
+	m.TextInput.SetValue(m.chatTemplates[idx].Body)
 	m.TextInput.CursorEnd()
 }
 
@@ -162,11 +224,18 @@ func (m *Model) Reset() {
 	// Clear history but keep welcome message
 	welcomeMessage := "Welcome to LamaCLI! 🦙✨\n\nI'm ready to help you with your questions. You can:\n• Ask me anything about programming, writing, or general topics\n• Use 'Alt+T' to switch between templates\n• Use 'F' to browse files and 'M' to switch AI models\n• Use 'C' to copy code blocks when available\n• Press 'H' for detailed help and instructions\n• Press Ctrl+C to exit\n\nWhat would you like to know?"
 	m.History = []string{"", welcomeMessage}
-	m.codeBlocks = []string{}
+	m.currentSession = nil
+	m.codeBlocks = []patch.Block{}
 	m.selectedCode = 0
 	m.showCodeHelp = false
+	m.pendingPatch = nil
+	m.notice = ""
+	m.exporting = false
+	m.branch = branching{}
+	m.regen = regenerating{}
 	m.streaming = false
 	m.err = nil
+	m.resetTools()
 	m.renderViewport()
 }
 
@@ -182,6 +251,40 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds []tea.Cmd
 	)
 
+	// Tool calls wait for approval before anything else
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && len(m.pendingCalls) > 0 {
+		return m, m.approveToolCalls(keyMsg.String())
+	}
+
+	// So does picking the export format
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.exporting {
+		m.handleExportKey(keyMsg.String())
+		return m, nil
+	}
+
+	// And picking a message to edit
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.branch.selecting {
+		m.handleSelectKey(keyMsg.String())
+		return m, nil
+	}
+
+	// And choosing how to regenerate
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.regen.active {
+		return m, m.handleRegenerateKey(keyMsg.String())
+	}
+
+	// A previewed patch waits for confirmation before anything else
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.pendingPatch != nil {
+		switch keyMsg.String() {
+		case "y", "Y", "enter":
+			m.applyPendingPatch()
+			m.showCodeHelp = false
+		case "n", "N":
+			m.pendingPatch = nil
+		}
+		return m, nil
+	}
+
 	// Handle special keys BEFORE text input updates
 	if keyMsg, ok := msg.(tea.KeyMsg); ok {
 		switch keyMsg.String() {
@@ -191,6 +294,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		default:
 			if m.TextInput.Value() == "" {
 				switch keyMsg.String() {
+				case "E":
+					if !m.streaming && len(export.Messages(&chathistory.ChatSession{History: m.History})) > 0 {
+						m.startExport()
+						return m, nil
+					}
+				case "B":
+					if !m.streaming && m.startSelecting() {
+						return m, nil
+					}
+				case "G":
+					if m.canRegenerate() {
+						return m, m.startRegenerate()
+					}
+				case "[":
+					if m.hasAlternates() {
+						return m, m.cycleAlternate(-1)
+					}
+				case "]":
+					if m.hasAlternates() {
+						return m, m.cycleAlternate(1)
+					}
 				case "C":
 					if len(m.codeBlocks) > 0 {
 						m.showCodeHelp = !m.showCodeHelp
@@ -206,6 +330,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.selectedCode = (m.selectedCode - 1 + len(m.codeBlocks)) % len(m.codeBlocks)
 						return m, nil
 					}
+				case "A":
+					if m.showCodeHelp && len(m.codeBlocks) > 0 {
+						m.err = nil
+						m.notice = ""
+						if err := m.previewSelectedCodeBlock(); err != nil {
+							m.err = err
+						}
+						return m, nil
+					}
 				}
 			}
 		}
@@ -254,8 +387,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Update renderer width
 		if m.renderer != nil {
 			m.renderer, _ = glamour.NewTermRenderer(
-				glamour.WithStylePath("dark"),
-				glamour.WithWordWrap(m.viewport.Width-4),
+				glamour.WithStylePath(m.glamourStyle),
+				glamour.WithWordWrap(min(m.viewport.Width-4, m.renderWidth)),
 			)
 		}
 
@@ -275,21 +408,46 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.History[len(m.History)-1] += string(msg)
 			m.renderViewport()
 			m.viewport.GotoBottom()
+			if m.eventChan != nil {
+				return m, readEventCmd(m.eventChan)
+			}
 			return m, readStreamCmd(m.responseChan)
 		}
 
+	case toolCallsMsg:
+		if m.streaming {
+			return m, m.handleToolCalls(msg)
+		}
+
+	case toolResultsMsg:
+		if m.streaming {
+			return m, m.handleToolResults(msg)
+		}
+
 	case streamCompleteMsg:
 		m.streaming = false
 		m.responseChan = nil
+		m.resetTools()
 		m.renderViewport()
 		m.viewport.GotoBottom()
+		m.recordReplyModel()
 		// Auto-save session after response completion
-		m.AutoSaveSession()
+		cmds = append(cmds, m.AutoSaveSession())
+
+	case sessionSavedMsg:
+		m.handleSessionSaved(msg)
+		cmds = append(cmds, m.summarizeSession())
+
+	case modelsListedMsg:
+		if m.regen.active && len(msg) > 0 {
+			m.regen.models = msg
+		}
 
 	case errMsg:
 		m.err = msg.err
 		m.streaming = false
 		m.responseChan = nil
+		m.resetTools()
 
 	case tea.KeyMsg:
 		// Handle message sending
@@ -303,16 +461,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+			edited := m.branch.editing
+			if edited {
+				m.History = m.branchHistory()
+			}
 			m.History = append(m.History, question)
 			m.History = append(m.History, "") // Placeholder for LLM response
+			if edited {
+				m.syncTree() // Show the new branch
+			}
 			m.TextInput.SetValue("")
 			m.ContextFileName = "" // Clear the context file name
 			m.renderViewport()
 			m.viewport.GotoBottom()
 
-			m.streaming = true
-			m.err = nil // Clear previous errors
-			m.responseChan = make(chan string)
 			f, err := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
 				// Handle error if log file can't be opened
@@ -320,8 +482,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			defer f.Close()
 			f.WriteString(fmt.Sprintf("DEBUG: Calling GenerateResponseStream with model: %s\n", m.SelectedModel))
-			go m.llmClient.GenerateResponseStream(m.SelectedModel, "You are a helpful assistant.", m.History[:len(m.History)-1], m.responseChan)
-			return m, readStreamCmd(m.responseChan)
+			return m, m.startReply(m.llmClient, m.SelectedModel)
 
 		case tea.KeyRunes:
 			// Check for "@" to trigger file context selection
@@ -377,41 +538,48 @@ func (m *Model) renderViewport() {
 	var content strings.Builder
 
 	// Clear existing code blocks
-	m.codeBlocks = []string{}
+	m.codeBlocks = []patch.Block{}
+	m.messageLines = make([]int, len(m.History))
+	lines := 0
 
 	for i, line := range m.History {
+		m.messageLines[i] = lines
 		var styledLine string
 		if i%2 == 0 {
 			// User messages
 			if line != "" { // Skip empty user messages (like welcome message prefix)
 				userIcon := "👤"
-				styledLine = styles.UserPromptStyle().Render(userIcon + " You: " + line)
+				if m.branch.selecting && i == m.branch.selected {
+					userIcon = "▶"
+				}
+				styledLine = styles.UserPromptStyle().Render(userIcon + " You" + m.branchLabel(i) + ": " + line)
 			}
 		} else {
 			// LLM responses - render as markdown
-			llmIcon := "🤖"
+			llmLabel := "🤖 LLM" + m.branchLabel(i)
 			if line != "" {
 				// Extract code blocks before rendering
-				codeBlocks := extractCodeBlocks(line)
+				codeBlocks := patch.Blocks(line)
 				m.codeBlocks = append(m.codeBlocks, codeBlocks...)
 
 				// Render markdown
 				if m.renderer != nil {
 					rendered, err := m.renderer.Render(line)
 					if err == nil {
-						styledLine = llmIcon + " LLM:\n" + rendered
+						styledLine = llmLabel + ":\n" + rendered
 					} else {
 						// Fallback to plain text if markdown rendering fails
-						styledLine = styles.LLMResponseStyle().Render(llmIcon + " LLM: " + line)
+						styledLine = styles.LLMResponseStyle().Render(llmLabel + ": " + line)
 					}
 				} else {
-					styledLine = styles.LLMResponseStyle().Render(llmIcon + " LLM: " + line)
+					styledLine = styles.LLMResponseStyle().Render(llmLabel + ": " + line)
 				}
 			}
 		}
 		if styledLine != "" {
 			content.WriteString(styledLine)
 			content.WriteString("\n\n") // Add extra spacing between messages
+			lines += strings.Count(styledLine, "\n") + 2
 		}
 	}
 
@@ -455,11 +623,18 @@ func (m Model) View() string {
 		Bold(true).
 		Render(fmt.Sprintf("%s %s", modelIcon, m.SelectedModel))
 
+	profileText := ""
+	if m.Profile != "" {
+		profileText = lipgloss.NewStyle().
+			Foreground(styles.TitleStyle().GetForeground()).
+			Render(" • 👤 " + m.Profile)
+	}
+
 	statusText := lipgloss.NewStyle().
 		Foreground(styles.SubtleStyle().GetForeground()).
 		Render(statusIcon)
 
-	headerContent := lipgloss.JoinHorizontal(lipgloss.Left, modelLabel, modelName, statusText)
+	headerContent := lipgloss.JoinHorizontal(lipgloss.Left, modelLabel, modelName, profileText, statusText)
 	header := headerStyle.Render(headerContent) + "\n"
 
 	view.WriteString(header)
@@ -503,6 +678,33 @@ func (m Model) View() string {
 		return lipgloss.JoinVertical(lipgloss.Left, view.String(), errorFooter)
 	}
 
+	// Show tool calls while they await approval
+	if len(m.pendingCalls) > 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, view.String(), m.toolApproval())
+	}
+
+	// Show the patch preview while it awaits confirmation
+	if m.pendingPatch != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, view.String(), m.patchPreview())
+	}
+
+	if m.regen.active {
+		return lipgloss.JoinVertical(lipgloss.Left, view.String(), m.regenerateHelp())
+	}
+
+	if m.branch.selecting || m.branch.editing {
+		return lipgloss.JoinVertical(lipgloss.Left, view.String(), m.branchHelp())
+	}
+
+	if m.notice != "" {
+		noticeFooter := lipgloss.NewStyle().
+			Foreground(styles.StatusStyle().GetForeground()).
+			Bold(true).
+			MarginTop(1).
+			Render(m.notice)
+		return lipgloss.JoinVertical(lipgloss.Left, view.String(), noticeFooter)
+	}
+
 	// Show code block help if active
 	if m.showCodeHelp && len(m.codeBlocks) > 0 {
 		codeHelpStyle := lipgloss.NewStyle().
@@ -514,13 +716,17 @@ func (m Model) View() string {
 			BorderForeground(styles.TitleStyle().GetForeground())
 
 		codeHelpText := fmt.Sprintf(
-			"📎 Code Blocks (%d/%d)\n↑/↓ or j/k: Navigate • Enter: Copy • C: Close",
+			"📎 Code Blocks (%d/%d)\n↑/↓ or j/k: Navigate • Enter: Copy • A: Apply to files • C: Close",
 			m.selectedCode+1, len(m.codeBlocks),
 		)
 
 		// Show preview of selected code block
 		if m.selectedCode < len(m.codeBlocks) {
-			preview := m.codeBlocks[m.selectedCode]
+			block := m.codeBlocks[m.selectedCode]
+			preview := strings.TrimSpace(block.Code)
+			if block.Path != "" {
+				preview = block.Path + ": " + preview
+			}
 			if len(preview) > 100 {
 				preview = preview[:100] + "..."
 			}
@@ -558,55 +764,130 @@ func (m Model) View() string {
 	return view.String()
 }
 
+// patchPreview renders the pending patch, trimmed to fit the screen
+func (m Model) patchPreview() string {
+	previewStyle := lipgloss.NewStyle().
+		MarginTop(1).
+		Padding(0, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(styles.TitleStyle().GetForeground())
+
+	title := lipgloss.NewStyle().
+		Foreground(styles.StatusStyle().GetForeground()).
+		Bold(true).
+		Render("🩹 Apply patch? " + strings.Join(m.pendingPatch.Summary(), ", "))
+
+	lines := strings.Split(strings.TrimRight(m.pendingPatch.Preview(), "\n"), "\n")
+	maxLines := max(5, m.height/2)
+	if len(lines) > maxLines {
+		more := len(lines) - maxLines
+		lines = append(lines[:maxLines], styles.SubtleStyle().Render(fmt.Sprintf("... %d more line(s)", more)))
+	}
+
+	help := styles.SubtleStyle().Render("y/Enter: Apply • n/Esc: Cancel")
+	return previewStyle.Render(title + "\n\n" + strings.Join(lines, "\n") + "\n\n" + help)
+}
+
 // LoadFromSession loads a chat session into the current model
 func (m *Model) LoadFromSession(session *chathistory.ChatSession) {
 	m.History = make([]string, len(session.History))
 	copy(m.History, session.History)
-	m.SelectedModel = session.Model
+	if session.Model != "" {
+		m.SelectedModel = session.Model
+	}
 	m.currentSession = session
-	m.codeBlocks = []string{}
+	m.codeBlocks = []patch.Block{}
 	m.selectedCode = 0
 	m.showCodeHelp = false
+	m.pendingPatch = nil
+	m.notice = ""
+	m.exporting = false
+	m.branch = branching{}
+	m.regen = regenerating{}
 	m.streaming = false
 	m.err = nil
+	m.resetTools()
 	m.renderViewport()
 }
 
+// sessionSavedMsg reports the result of saving the chat in the background
+type sessionSavedMsg struct {
+	from    *chathistory.ChatSession // Session the save started from
+	session *chathistory.ChatSession // Session as saved
+	copied  bool                     // Saved under a new ID after a conflict
+	err     error
+}
+
+// ScrollToMessage scrolls the viewport to a message of the history
+func (m *Model) ScrollToMessage(index int) {
+	if index >= 0 && index < len(m.messageLines) {
+		m.viewport.SetYOffset(m.messageLines[index])
+	}
+}
+
 // SaveToSession saves the current chat to a session
 func (m *Model) SaveToSession() (*chathistory.ChatSession, error) {
-	historyManager, err := chathistory.NewChatHistoryManager()
-	if err != nil {
-		return nil, err
+	msg := m.saveSessionCmd()()
+	saved := msg.(sessionSavedMsg)
+	if saved.err != nil {
+		return nil, saved.err
 	}
+	m.currentSession = saved.session
+	return saved.session, nil
+}
 
-	if m.currentSession == nil {
-		// Create new session
-		m.currentSession = &chathistory.ChatSession{
-			Model:   m.SelectedModel,
-			History: make([]string, len(m.History)),
-		}
-		copy(m.currentSession.History, m.History)
-	} else {
-		// Update existing session
-		m.currentSession.Model = m.SelectedModel
-		m.currentSession.History = make([]string, len(m.History))
-		copy(m.currentSession.History, m.History)
+// AutoSaveSession saves the session after each response, unless the chat
+// holds only the welcome message
+func (m *Model) AutoSaveSession() tea.Cmd {
+	if len(m.History) <= 2 {
+		return nil
 	}
+	return m.saveSessionCmd()
+}
 
-	if err := historyManager.SaveSession(m.currentSession); err != nil {
-		return nil, err
+// saveSessionCmd saves a snapshot of the chat. If another lamacli instance
+// changed the session meanwhile, the chat is saved as a copy instead so
+// neither loses turns.
+func (m *Model) saveSessionCmd() tea.Cmd {
+	if m.currentSession == nil {
+		// Pick the ID now so overlapping saves update the same session
+		m.currentSession = &chathistory.ChatSession{ID: chathistory.NewSessionID()}
 	}
+	from := m.currentSession
+	snapshot := *from
+	snapshot.Model = m.SelectedModel
+	snapshot.History = append([]string{}, m.History...)
+	snapshot.Messages = append([]chathistory.Message{}, from.Messages...)
 
-	return m.currentSession, nil
+	return func() tea.Msg {
+		historyManager, err := chathistory.NewChatHistoryManager()
+		if err != nil {
+			return sessionSavedMsg{from: from, err: err}
+		}
+		err = historyManager.SaveSession(&snapshot)
+		copied := false
+		if errors.Is(err, chathistory.ErrConflict) {
+			err = historyManager.SaveSessionCopy(&snapshot)
+			copied = err == nil
+		}
+		return sessionSavedMsg{from: from, session: &snapshot, copied: copied, err: err}
+	}
 }
 
-// AutoSaveSession automatically saves the session after each response
-func (m *Model) AutoSaveSession() {
-	// Only auto-save if we have meaningful conversation (more than just welcome message)
-	if len(m.History) > 2 {
-		go func() {
-			m.SaveToSession()
-		}()
+// handleSessionSaved tracks the saved session, unless the chat moved on to
+// another session while saving
+func (m *Model) handleSessionSaved(msg sessionSavedMsg) {
+	if m.currentSession != msg.from {
+		return
+	}
+	switch {
+	case msg.err != nil:
+		m.notice = fmt.Sprintf("⚠️ Failed to save session: %v", msg.err)
+	case msg.copied:
+		m.currentSession = msg.session
+		m.notice = "⚠️ This session was changed in another window, so it was saved as a copy"
+	default:
+		m.currentSession = msg.session
 	}
 }
 
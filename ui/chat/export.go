@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/export"
+)
+
+// startExport asks for the format to export the conversation in
+func (m *Model) startExport() {
+	m.exporting = true
+	m.notice = "📤 Export as " + export.KeyHint() + " • Esc: Cancel"
+}
+
+// CancelExport cancels picking an export format, reporting whether one was
+// being picked.
+func (m *Model) CancelExport() bool {
+	if !m.exporting {
+		return false
+	}
+	m.exporting = false
+	m.notice = ""
+	return true
+}
+
+// handleExportKey exports the conversation in the format picked by a key,
+// into the current directory
+func (m *Model) handleExportKey(key string) {
+	format, ok := export.FormatForKey(key)
+	if !ok {
+		return
+	}
+	m.exporting = false
+
+	session := &chathistory.ChatSession{Model: m.SelectedModel, UpdatedAt: time.Now()}
+	if m.currentSession != nil {
+		session.ID = m.currentSession.ID
+		session.Title = m.currentSession.Title
+		session.CreatedAt = m.currentSession.CreatedAt
+		session.Version = m.currentSession.Version
+	}
+	session.History = append([]string{}, m.History...)
+
+	path, err := export.WriteFile(session, format, ".")
+	if err != nil {
+		m.notice = fmt.Sprintf("❌ Export failed: %v", err)
+		return
+	}
+	m.notice = fmt.Sprintf("📤 Exported to %s", path)
+}
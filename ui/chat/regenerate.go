@@ -0,0 +1,187 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hariharen9/lamacli/llm"
+	"github.com/hariharen9/lamacli/ui/styles"
+)
+
+// temperatures are the temperatures a reply can be regenerated with, on
+// top of the configured one
+var temperatures = []float64{0, 0.4, 0.8, 1.2}
+
+// regenerating is the state of choosing how to regenerate the last reply
+type regenerating struct {
+	active      bool
+	models      []string // Installed models, once listed
+	model       string   // Model to regenerate with
+	temperature int      // Index in temperatures, or -1 for the configured one
+}
+
+// modelsListedMsg carries the installed models for regenerating
+type modelsListedMsg []string
+
+// canRegenerate reports whether the last message is a reply that can be
+// regenerated; the welcome message answers no prompt and cannot
+func (m *Model) canRegenerate() bool {
+	n := len(m.History)
+	return !m.streaming && n >= 2 && n%2 == 0 && m.History[n-2] != "" && m.History[n-1] != ""
+}
+
+// startRegenerate asks how to regenerate the last reply, defaulting to the
+// current model and temperature
+func (m *Model) startRegenerate() tea.Cmd {
+	m.regen = regenerating{active: true, models: []string{m.SelectedModel}, model: m.SelectedModel, temperature: -1}
+	m.showCodeHelp = false
+	client := m.llmClient
+	return func() tea.Msg {
+		models, err := client.ListModels()
+		if err != nil {
+			return nil // Keep offering the current model only
+		}
+		return modelsListedMsg(models)
+	}
+}
+
+// CancelRegenerate stops choosing how to regenerate, reporting whether
+// that was in progress.
+func (m *Model) CancelRegenerate() bool {
+	if !m.regen.active {
+		return false
+	}
+	m.regen = regenerating{}
+	return true
+}
+
+// handleRegenerateKey changes the model or temperature to regenerate with,
+// or regenerates
+func (m *Model) handleRegenerateKey(key string) tea.Cmd {
+	switch key {
+	case "m", "tab":
+		m.regen.model = next(m.regen.models, m.regen.model, 1)
+	case "shift+tab":
+		m.regen.model = next(m.regen.models, m.regen.model, -1)
+	case "t":
+		m.regen.temperature++
+		if m.regen.temperature == len(temperatures) {
+			m.regen.temperature = -1
+		}
+	case "enter", "G":
+		return m.regenerate()
+	}
+	return nil
+}
+
+// next returns the item delta places from current in a list, wrapping
+func next(items []string, current string, delta int) string {
+	if len(items) == 0 {
+		return current
+	}
+	i := 0
+	for j, item := range items {
+		if item == current {
+			i = j
+		}
+	}
+	return items[((i+delta)%len(items)+len(items))%len(items)]
+}
+
+// regenerate replaces the last reply with a new one as its alternate,
+// keeping the others in the session's message tree
+func (m *Model) regenerate() tea.Cmd {
+	options := m.regen
+	m.regen = regenerating{}
+	if !m.canRegenerate() {
+		return nil
+	}
+
+	m.syncTree() // Keep the current reply as an alternate
+	m.History[len(m.History)-1] = ""
+	m.syncTree() // Add the new reply's place, showing it as the latest
+	m.codeBlocks = nil
+	m.selectedCode = 0
+	m.renderViewport()
+	m.viewport.GotoBottom()
+
+	client := m.llmClient
+	if options.temperature >= 0 {
+		client = client.WithOptions(map[string]any{"temperature": temperatures[options.temperature]})
+	}
+	return m.startReply(client, options.model)
+}
+
+// cycleAlternate shows the next or previous alternate of the last reply,
+// and saves it as the one chosen
+func (m *Model) cycleAlternate(delta int) tea.Cmd {
+	index := len(m.History) - 1
+	session := m.syncTree()
+	position, count := session.Branch(index)
+	if count < 2 {
+		return nil
+	}
+	target := ((position-1+delta)%count+count)%count + 1
+	if !session.SwitchBranch(index, target-position) {
+		return nil
+	}
+	m.History = append([]string{}, session.History...)
+	m.codeBlocks = nil
+	m.selectedCode = 0
+	m.renderViewport()
+	m.viewport.GotoBottom()
+	return m.AutoSaveSession()
+}
+
+// hasAlternates reports whether the last reply has alternates to cycle
+func (m *Model) hasAlternates() bool {
+	if m.streaming || m.currentSession == nil || len(m.History) == 0 {
+		return false
+	}
+	_, count := m.currentSession.Branch(len(m.History) - 1)
+	return count > 1
+}
+
+// startReply streams a reply to the history, which ends with the reply's
+// empty placeholder
+func (m *Model) startReply(client *llm.OllamaClient, model string) tea.Cmd {
+	m.replyClient, m.replyModel = client, model
+	m.streaming = true
+	m.err = nil // Clear previous errors
+	m.notice = ""
+	if m.hasTools() {
+		m.resetTools()
+		return m.streamWithTools()
+	}
+	m.responseChan = make(chan string)
+	go client.GenerateResponseStream(model, m.systemPrompt, m.History[:len(m.History)-1], m.responseChan)
+	return readStreamCmd(m.responseChan)
+}
+
+// recordReplyModel notes in the session which model wrote the finished
+// reply, so alternates from different models can be told apart
+func (m *Model) recordReplyModel() {
+	if m.replyModel == "" || len(m.History) < 2 {
+		return
+	}
+	m.syncTree().SetMessageModel(len(m.History)-1, m.replyModel)
+}
+
+// regenerateHelp renders the footer shown while choosing how to regenerate
+func (m Model) regenerateHelp() string {
+	temperature := "configured"
+	if m.regen.temperature >= 0 {
+		temperature = fmt.Sprintf("%.1f", temperatures[m.regen.temperature])
+	}
+	lines := []string{
+		fmt.Sprintf("🔄 Regenerate with %s • temperature %s", m.regen.model, temperature),
+		strings.Join([]string{"m/tab: Model", "t: Temperature", "Enter: Regenerate", "Esc: Cancel"}, " • "),
+	}
+	return lipgloss.NewStyle().
+		Foreground(styles.StatusStyle().GetForeground()).
+		Bold(true).
+		MarginTop(1).
+		Render(strings.Join(lines, "\n"))
+}
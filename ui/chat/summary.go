@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/hariharen9/lamacli/chathistory"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SessionSummarizedMsg reports the title and summary written for a session
+type SessionSummarizedMsg struct {
+	SessionID string
+	Session   *chathistory.ChatSession // Session as updated, nil on error
+	Err       error
+}
+
+// Summarize asks the titles model for a title and summary of a saved
+// session in the background
+func (m *Model) Summarize(sessionID string) tea.Cmd {
+	client, model := m.llmClient, m.TitleModel
+	if model == "" {
+		model = m.SelectedModel
+	}
+	return func() tea.Msg {
+		historyManager, err := chathistory.NewChatHistoryManager()
+		if err != nil {
+			return SessionSummarizedMsg{SessionID: sessionID, Err: err}
+		}
+		session, err := historyManager.SummarizeSession(context.Background(), client, model, sessionID)
+		return SessionSummarizedMsg{SessionID: sessionID, Session: session, Err: err}
+	}
+}
+
+// summarizeSession summarizes the current session once, after its first
+// save
+func (m *Model) summarizeSession() tea.Cmd {
+	session := m.currentSession
+	if session == nil || session.Summary != "" || session.UpdatedAt.IsZero() || m.summarizing == session.ID {
+		return nil
+	}
+	m.summarizing = session.ID
+	return m.Summarize(session.ID)
+}
+
+// HandleSessionSummarized shows the new title on the current session. A
+// failure is left for the history browser's regenerate action, as the
+// session keeps its title from the first prompt.
+func (m *Model) HandleSessionSummarized(msg SessionSummarizedMsg) {
+	if msg.Err != nil || m.currentSession == nil || m.currentSession.ID != msg.SessionID {
+		return
+	}
+	m.currentSession.Title = msg.Session.Title
+	m.currentSession.Summary = msg.Session.Summary
+}
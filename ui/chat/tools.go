@@ -0,0 +1,263 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hariharen9/lamacli/llm"
+	"github.com/hariharen9/lamacli/mcp"
+	"github.com/hariharen9/lamacli/ui/styles"
+)
+
+const (
+	// maxToolSteps limits the rounds of tool calls in one reply, after
+	// which the model must answer without tools
+	maxToolSteps = 8
+
+	// toolTimeout limits how long a single tool call may run
+	toolTimeout = 60 * time.Second
+
+	// maxToolOutput caps the tool output sent back to the model
+	maxToolOutput = 20000
+)
+
+// toolCallsMsg carries the tool calls the model made at the end of a
+// response.
+type toolCallsMsg []llm.ToolCall
+
+// toolResultsMsg carries the results of the approved tool calls.
+type toolResultsMsg []string
+
+// SetMCP offers the tools of connected MCP servers to the model and
+// reports which servers are available.
+func (m *Model) SetMCP(manager *mcp.Manager) {
+	m.mcp = manager
+	m.allowedTools = make(map[string]bool)
+
+	var connected, failed []string
+	for _, server := range manager.Servers {
+		if server.Err != nil {
+			failed = append(failed, server.Name)
+		} else {
+			connected = append(connected, server.Name)
+		}
+	}
+	m.notice = fmt.Sprintf("🔌 MCP: %d tool(s) from %s", len(manager.Tools()), strings.Join(connected, ", "))
+	if len(connected) == 0 {
+		m.notice = "🔌 MCP: no servers connected"
+	}
+	if len(failed) > 0 {
+		m.notice += fmt.Sprintf(" • failed: %s (see 'lamacli mcp list')", strings.Join(failed, ", "))
+	}
+}
+
+// hasTools reports whether there are tools to offer the model
+func (m *Model) hasTools() bool {
+	return m.mcp != nil && len(m.mcp.Tools()) > 0
+}
+
+// streamWithTools generates the current reply with the MCP tools on offer,
+// continuing after any tool rounds already completed
+func (m *Model) streamWithTools() tea.Cmd {
+	ch := make(chan llm.StreamEvent)
+	m.eventChan = ch
+
+	client, model, systemPrompt := m.replyClient, m.replyModel, m.systemPrompt
+	history := append([]string{}, m.History[:len(m.History)-1]...)
+	steps := append([]llm.ToolStep{}, m.toolSteps...)
+	var tools []llm.Tool
+	if len(steps) < maxToolSteps {
+		for _, tool := range m.mcp.Tools() {
+			tools = append(tools, llm.Tool{Name: tool.Name, Description: tool.Description(), Parameters: tool.Schema()})
+		}
+	}
+
+	go func() {
+		ctx := context.Background()
+		if len(tools) > 0 && !client.SupportsTools(ctx, model) {
+			tools = nil
+		}
+		client.StreamWithTools(ctx, model, systemPrompt, history, steps, tools, ch)
+	}()
+	return readEventCmd(ch)
+}
+
+// readEventCmd waits for the next event from a tool-enabled stream.
+func readEventCmd(ch <-chan llm.StreamEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-ch
+		switch {
+		case !ok:
+			return streamCompleteMsg{}
+		case event.Err != nil:
+			return errMsg{err: event.Err}
+		case len(event.ToolCalls) > 0:
+			return toolCallsMsg(event.ToolCalls)
+		default:
+			return llmResponseChunkMsg(event.Text)
+		}
+	}
+}
+
+// handleToolCalls records the model's tool calls and runs them, asking
+// for approval first unless every tool is allowed
+func (m *Model) handleToolCalls(calls []llm.ToolCall) tea.Cmd {
+	reply := m.History[len(m.History)-1]
+	m.toolSteps = append(m.toolSteps, llm.ToolStep{Content: reply[m.stepStart:], Calls: calls})
+	m.eventChan = nil
+	m.pendingCalls = calls
+
+	for _, call := range calls {
+		if m.needsApproval(call.Name) {
+			return nil // Wait for the user
+		}
+	}
+	return m.runToolCalls(true)
+}
+
+// needsApproval reports whether the user must approve a tool call. Calls
+// to unknown tools fail without running anything, so need no approval.
+func (m *Model) needsApproval(name string) bool {
+	if _, ok := m.mcp.Lookup(name); !ok {
+		return false
+	}
+	return m.mcp.NeedsApproval(name) && !m.allowedTools[name]
+}
+
+// runToolCalls runs the pending tool calls, or declines them, and notes
+// them in the reply
+func (m *Model) runToolCalls(allowed bool) tea.Cmd {
+	calls := m.pendingCalls
+	m.pendingCalls = nil
+
+	reply := &m.History[len(m.History)-1]
+	*reply = strings.TrimRight(*reply, "\n")
+	for _, call := range calls {
+		if *reply != "" {
+			*reply += "\n\n"
+		}
+		if allowed {
+			*reply += fmt.Sprintf("> 🔧 Called `%s`", call.Name)
+		} else {
+			*reply += fmt.Sprintf("> 🚫 Declined `%s`", call.Name)
+		}
+	}
+	*reply += "\n\n"
+	m.stepStart = len(*reply)
+	m.renderViewport()
+	m.viewport.GotoBottom()
+
+	manager := m.mcp
+	return func() tea.Msg {
+		results := make([]string, len(calls))
+		for i, call := range calls {
+			if !allowed {
+				results[i] = "The user declined this tool call."
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+			output, err := manager.Call(ctx, call.Name, call.Arguments)
+			cancel()
+			if err != nil {
+				output = fmt.Sprintf("Error: %v", err)
+			}
+			if len(output) > maxToolOutput {
+				// Back up to the start of a character rather than split one
+				limit := maxToolOutput
+				for limit > 0 && !utf8.RuneStart(output[limit]) {
+					limit--
+				}
+				output = output[:limit] + "\n... (output truncated)"
+			}
+			results[i] = output
+		}
+		return toolResultsMsg(results)
+	}
+}
+
+// handleToolResults continues the reply with the tool results
+func (m *Model) handleToolResults(results []string) tea.Cmd {
+	m.toolSteps[len(m.toolSteps)-1].Results = results
+	return m.streamWithTools()
+}
+
+// approveToolCalls handles a key press while tool calls await approval
+func (m *Model) approveToolCalls(key string) tea.Cmd {
+	switch key {
+	case "y", "Y", "enter":
+		return m.runToolCalls(true)
+	case "a", "A":
+		for _, call := range m.pendingCalls {
+			m.allowedTools[call.Name] = true
+		}
+		return m.runToolCalls(true)
+	case "n", "N":
+		return m.runToolCalls(false)
+	}
+	return nil
+}
+
+// DeclineToolCalls declines tool calls awaiting approval, reporting
+// whether there were any. The returned command continues the reply.
+func (m *Model) DeclineToolCalls() (tea.Cmd, bool) {
+	if len(m.pendingCalls) == 0 {
+		return nil, false
+	}
+	return m.runToolCalls(false), true
+}
+
+// resetTools clears the tool state of the reply in progress
+func (m *Model) resetTools() {
+	m.toolSteps = nil
+	m.stepStart = 0
+	m.pendingCalls = nil
+	m.eventChan = nil
+}
+
+// toolApproval renders the tool calls awaiting approval
+func (m Model) toolApproval() string {
+	boxStyle := lipgloss.NewStyle().
+		MarginTop(1).
+		Padding(0, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(styles.TitleStyle().GetForeground())
+
+	title := lipgloss.NewStyle().
+		Foreground(styles.StatusStyle().GetForeground()).
+		Bold(true).
+		Render(fmt.Sprintf("🔧 Allow %d tool call(s)?", len(m.pendingCalls)))
+
+	var lines []string
+	for _, call := range m.pendingCalls {
+		label := call.Name
+		if tool, ok := m.mcp.Lookup(call.Name); ok {
+			label = tool.Server.Name + " › " + tool.Tool.Name
+		}
+		args, _ := json.Marshal(call.Arguments)
+		lines = append(lines, "• "+label+" "+styles.SubtleStyle().Render(truncate(string(args), max(20, m.width-20))))
+	}
+
+	help := styles.SubtleStyle().Render("y/Enter: Allow • a: Always allow these tools • n/Esc: Decline")
+	return boxStyle.Render(title + "\n\n" + strings.Join(lines, "\n") + "\n\n" + help)
+}
+
+// truncate shortens s to at most n cells of display width
+func truncate(s string, n int) string {
+	if lipgloss.Width(s) <= n {
+		return s
+	}
+	width := 0
+	for i, r := range s {
+		width += lipgloss.Width(string(r))
+		if width > n-3 {
+			return s[:i] + "..."
+		}
+	}
+	return s
+}
@@ -13,27 +13,47 @@ import (
 
 // SessionItem represents a chat session in the list
 type SessionItem struct {
-	Session *chathistory.ChatSession
+	Session chathistory.SessionInfo
 }
 
-// FilterValue returns the value to filter by
+// FilterValue returns the value to filter by: the title, summary, tags and
+// folder
 func (s SessionItem) FilterValue() string {
-	return s.Session.Title
+	value := s.Session.Title + " " + s.Session.Summary + " " + s.Session.Folder
+	for _, tag := range s.Session.Tags {
+		value += " #" + tag
+	}
+	return value
 }
 
 // Title returns the title of the session
 func (s SessionItem) Title() string {
+	if s.Session.Pinned {
+		return "📌 " + s.Session.Title
+	}
 	return s.Session.Title
 }
 
-// Description returns the description of the session
+// Description returns the description of the session, with its summary
+// on a second line
 func (s SessionItem) Description() string {
-	return s.Session.GetSessionSummary()
+	description := s.Session.GetSessionSummary()
+	if s.Session.Folder != "" {
+		description = "📁 " + s.Session.Folder + " • " + description
+	}
+	if len(s.Session.Tags) > 0 {
+		description += " • " + tagList(s.Session.Tags)
+	}
+	if s.Session.Summary != "" {
+		description += "\n" + s.Session.Summary
+	}
+	return description
 }
 
 // SessionSelectedMsg is sent when a session is selected
 type SessionSelectedMsg struct {
-	Session *chathistory.ChatSession
+	Session      *chathistory.ChatSession
+	MessageIndex int // Message to scroll to, 0 for the start
 }
 
 // SessionDeletedMsg is sent when a session is deleted
@@ -48,6 +68,14 @@ type Model struct {
 	width          int
 	height         int
 	err            error
+	warning        string                   // Files that could not be loaded
+	notice         string                   // Export prompt or result
+	exporting      *chathistory.ChatSession // Session awaiting an export format
+	search         search
+	prompt         prompt
+	sessions       []chathistory.SessionInfo // All sessions, before filtering
+	filters        filters
+	summarizing    string // Session whose summary was asked for
 }
 
 // New creates a new chat history browser
@@ -69,6 +97,7 @@ func New() (*Model, error) {
 	m := &Model{
 		list:           l,
 		historyManager: historyManager,
+		search:         newSearch(),
 	}
 
 	// Load sessions
@@ -91,19 +120,22 @@ func NewItemDelegate() list.DefaultDelegate {
 	return d
 }
 
-// loadSessions loads all chat sessions from disk
+// loadSessions loads the metadata of all chat sessions, pinned first
 func (m *Model) loadSessions() error {
-	sessions, err := m.historyManager.ListSessions()
+	page, err := m.historyManager.ListSessionInfo(chathistory.Query{PinnedFirst: true})
 	if err != nil {
 		return err
 	}
-
-	items := make([]list.Item, len(sessions))
-	for i, session := range sessions {
-		items[i] = SessionItem{Session: session}
+	m.sessions = page.Sessions
+	m.applyFilters()
+
+	m.warning = ""
+	if issues := m.historyManager.Issues(); len(issues) > 0 {
+		m.warning = fmt.Sprintf("⚠️  %d session files could not be loaded (run 'lamacli history doctor')", len(issues))
+		if len(issues) == 1 {
+			m.warning = fmt.Sprintf("⚠️  %s could not be loaded: %s (run 'lamacli history doctor')", issues[0].File, issues[0].Problem)
+		}
 	}
-
-	m.list.SetItems(items)
 	return nil
 }
 
@@ -120,13 +152,28 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.list.SetWidth(msg.Width - styles.AppStyle().GetHorizontalFrameSize())
 		m.list.SetHeight(msg.Height - styles.AppStyle().GetVerticalFrameSize() - 4)
+		m.search.input.Width = msg.Width - styles.AppStyle().GetHorizontalFrameSize() - 6
 
 	case tea.KeyMsg:
+		if m.search.active {
+			return m, m.updateSearch(msg)
+		}
+		if m.prompt.active {
+			return m, m.updatePrompt(msg)
+		}
+		if m.exporting != nil {
+			m.handleExportKey(msg.String())
+			return m, nil
+		}
+		if m.list.SettingFilter() {
+			break // Keys go to the title filter
+		}
+		m.notice = ""
 		switch msg.String() {
 		case "enter":
-			if selectedItem, ok := m.list.SelectedItem().(SessionItem); ok {
+			if session := m.GetSelectedSession(); session != nil {
 				return m, func() tea.Msg {
-					return SessionSelectedMsg{Session: selectedItem.Session}
+					return SessionSelectedMsg{Session: session}
 				}
 			}
 		case "delete", "d":
@@ -146,9 +193,40 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.err = err
 			}
 			return m, nil
+		case "s":
+			return m, m.startSearch()
+		case "e":
+			if session := m.GetSelectedSession(); session != nil {
+				m.startExport(session)
+				return m, nil
+			}
+		case "n":
+			return m, m.startPrompt(renamePrompt)
+		case "#":
+			return m, m.startPrompt(tagsPrompt)
+		case "m":
+			return m, m.startPrompt(folderPrompt)
+		case "p":
+			m.togglePin()
+			return m, nil
+		case "g":
+			return m, m.startSummarize()
+		case "t":
+			m.cycleFilter(true)
+			return m, nil
+		case "f":
+			m.cycleFilter(false)
+			return m, nil
 		}
 	}
 
+	if m.search.active {
+		return m, m.updateSearch(msg)
+	}
+	if m.prompt.active {
+		return m, m.updatePrompt(msg)
+	}
+
 	var cmd tea.Cmd
 	m.list, cmd = m.list.Update(msg)
 	return m, cmd
@@ -160,25 +238,47 @@ func (m *Model) View() string {
 		return styles.ErrorStyle().Render(fmt.Sprintf("Error: %v", m.err))
 	}
 
+	if m.search.active {
+		return m.searchView()
+	}
+
+	listView := m.list.View()
+	if m.prompt.active {
+		listView = lipgloss.JoinVertical(lipgloss.Left, m.prompt.input.View(), styles.SubtleStyle().Render("Enter: Save • Esc: Cancel"), listView)
+	}
+	if m.notice != "" {
+		listView = lipgloss.JoinVertical(lipgloss.Left, styles.StatusStyle().Render(m.notice), listView)
+	}
+	if m.warning != "" {
+		listView = lipgloss.JoinVertical(lipgloss.Left, styles.StatusStyle().Render(m.warning), listView)
+	}
+
 	if len(m.list.Items()) == 0 {
 		emptyMessage := styles.SubtleStyle().Render("No chat history found.\nStart a new conversation to create your first session!")
 		return lipgloss.JoinVertical(
 			lipgloss.Center,
-			m.list.View(),
+			listView,
 			"\n",
 			emptyMessage,
 		)
 	}
 
-	return m.list.View()
+	return listView
 }
 
-// GetSelectedSession returns the currently selected session
+// GetSelectedSession loads the currently selected session, showing why
+// if it cannot be loaded
 func (m *Model) GetSelectedSession() *chathistory.ChatSession {
-	if selectedItem, ok := m.list.SelectedItem().(SessionItem); ok {
-		return selectedItem.Session
+	selectedItem, ok := m.list.SelectedItem().(SessionItem)
+	if !ok {
+		return nil
 	}
-	return nil
+	session, err := m.historyManager.LoadSession(selectedItem.Session.ID)
+	if err != nil {
+		m.notice = fmt.Sprintf("❌ %v", err)
+		return nil
+	}
+	return session
 }
 
 // SaveCurrentSession saves the current chat session
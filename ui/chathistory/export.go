@@ -0,0 +1,43 @@
+package chathistory
+
+import (
+	"fmt"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/export"
+)
+
+// startExport asks for the format to export a session in
+func (m *Model) startExport(session *chathistory.ChatSession) {
+	m.exporting = session
+	m.notice = "📤 Export as " + export.KeyHint() + " • Esc: Cancel"
+}
+
+// CancelExport cancels picking an export format, reporting whether one was
+// being picked
+func (m *Model) CancelExport() bool {
+	if m.exporting == nil {
+		return false
+	}
+	m.exporting = nil
+	m.notice = ""
+	return true
+}
+
+// handleExportKey exports the session in the format picked by a key, into
+// the current directory
+func (m *Model) handleExportKey(key string) {
+	format, ok := export.FormatForKey(key)
+	if !ok {
+		return
+	}
+	session := m.exporting
+	m.exporting = nil
+
+	path, err := export.WriteFile(session, format, ".")
+	if err != nil {
+		m.notice = fmt.Sprintf("❌ Export failed: %v", err)
+		return
+	}
+	m.notice = fmt.Sprintf("📤 Exported to %s", path)
+}
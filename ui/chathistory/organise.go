@@ -0,0 +1,269 @@
+package chathistory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/ui/styles"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptKind is what an inline prompt of the history browser edits
+type promptKind int
+
+const (
+	renamePrompt promptKind = iota
+	tagsPrompt
+	folderPrompt
+)
+
+// prompt is an inline text input editing the selected session's title,
+// tags or folder
+type prompt struct {
+	active  bool
+	kind    promptKind
+	session chathistory.SessionInfo
+	input   textinput.Model
+}
+
+// filters narrow the listed sessions to a tag or folder
+type filters struct {
+	tag    string
+	folder string
+}
+
+// startPrompt edits the title, tags or folder of the selected session
+func (m *Model) startPrompt(kind promptKind) tea.Cmd {
+	selectedItem, ok := m.list.SelectedItem().(SessionItem)
+	if !ok {
+		return nil
+	}
+	session := selectedItem.Session
+
+	input := textinput.New()
+	input.PromptStyle = styles.PromptStyle()
+	input.CharLimit = 200
+	input.Width = max(20, m.width-styles.AppStyle().GetHorizontalFrameSize()-20)
+	switch kind {
+	case renamePrompt:
+		input.Prompt = "✏️  Rename: "
+		input.SetValue(session.Title)
+	case tagsPrompt:
+		input.Prompt = "🏷️  Tags: "
+		input.Placeholder = "comma or space separated"
+		input.SetValue(strings.Join(session.Tags, ", "))
+	case folderPrompt:
+		input.Prompt = "📁 Folder: "
+		input.Placeholder = "e.g. work/api, empty for none"
+		input.SetValue(session.Folder)
+	}
+	input.CursorEnd()
+	cmd := input.Focus()
+
+	m.prompt = prompt{active: true, kind: kind, session: session, input: input}
+	return cmd
+}
+
+// CancelPrompt stops editing a session's details, reporting whether that
+// was in progress
+func (m *Model) CancelPrompt() bool {
+	if !m.prompt.active {
+		return false
+	}
+	m.prompt = prompt{}
+	return true
+}
+
+// updatePrompt handles a message while editing a session's details
+func (m *Model) updatePrompt(msg tea.Msg) tea.Cmd {
+	if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEnter {
+		m.savePrompt()
+		return nil
+	}
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	return cmd
+}
+
+// savePrompt saves the edited title, tags or folder
+func (m *Model) savePrompt() {
+	p := m.prompt
+	m.prompt = prompt{}
+	value := strings.TrimSpace(p.input.Value())
+
+	var err error
+	switch p.kind {
+	case renamePrompt:
+		if value == p.session.Title {
+			return
+		}
+		if err = m.historyManager.RenameSession(p.session.ID, value); err == nil {
+			m.notice = fmt.Sprintf("✏️  Renamed to %q", value)
+		}
+	case tagsPrompt:
+		var tags []string
+		if tags, err = m.historyManager.SetSessionTags(p.session.ID, chathistory.ParseTags(value)); err == nil {
+			m.notice = "🏷️  Tags: " + tagList(tags)
+		}
+	case folderPrompt:
+		var folder string
+		if folder, err = m.historyManager.MoveSession(p.session.ID, value); err == nil {
+			m.notice = "📁 Moved to " + folder
+			if folder == "" {
+				m.notice = "📁 Moved out of its folder"
+			}
+		}
+	}
+	if err != nil {
+		m.notice = fmt.Sprintf("❌ %v", err)
+		return
+	}
+	m.reload()
+}
+
+// togglePin pins or unpins the selected session
+func (m *Model) togglePin() {
+	selectedItem, ok := m.list.SelectedItem().(SessionItem)
+	if !ok {
+		return
+	}
+	session := selectedItem.Session
+	if err := m.historyManager.PinSession(session.ID, !session.Pinned); err != nil {
+		m.notice = fmt.Sprintf("❌ %v", err)
+		return
+	}
+	m.notice = "📌 Pinned " + session.Title
+	if session.Pinned {
+		m.notice = "Unpinned " + session.Title
+	}
+	m.reload()
+}
+
+// reload reloads the sessions, keeping the changed session selected
+func (m *Model) reload() {
+	var selectedID string
+	if selectedItem, ok := m.list.SelectedItem().(SessionItem); ok {
+		selectedID = selectedItem.Session.ID
+	}
+	if err := m.loadSessions(); err != nil {
+		m.err = err
+		return
+	}
+	for i, item := range m.list.Items() {
+		if item.(SessionItem).Session.ID == selectedID {
+			m.list.Select(i)
+		}
+	}
+}
+
+// cycleFilter moves the tag or folder filter to the next tag or folder in
+// use, and back to none after the last
+func (m *Model) cycleFilter(byTag bool) {
+	if byTag {
+		m.filters.tag = nextFilter(chathistory.AllTags(m.sessions), m.filters.tag)
+	} else {
+		m.filters.folder = nextFilter(chathistory.AllFolders(m.sessions), m.filters.folder)
+	}
+	m.applyFilters()
+	m.list.Select(0)
+}
+
+// nextFilter returns the value after current, or none after the last
+func nextFilter(values []string, current string) string {
+	if current == "" {
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+	for i, value := range values {
+		if value == current && i+1 < len(values) {
+			return values[i+1]
+		}
+	}
+	return ""
+}
+
+// ClearFilters drops the tag and folder filters, reporting whether any
+// was set
+func (m *Model) ClearFilters() bool {
+	if m.filters == (filters{}) {
+		return false
+	}
+	m.filters = filters{}
+	m.applyFilters()
+	return true
+}
+
+// applyFilters lists the sessions matching the filters
+func (m *Model) applyFilters() {
+	var sessions []chathistory.SessionInfo
+	for _, session := range m.sessions {
+		if (m.filters.tag == "" || session.HasTag(m.filters.tag)) &&
+			(m.filters.folder == "" || session.InFolder(m.filters.folder)) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	items := make([]list.Item, len(sessions))
+	for i, session := range sessions {
+		items[i] = SessionItem{Session: session}
+	}
+	m.list.SetItems(items)
+
+	m.list.Title = "📚 Chat History"
+	if m.filters.tag != "" {
+		m.list.Title += " • #" + m.filters.tag
+	}
+	if m.filters.folder != "" {
+		m.list.Title += " • 📁 " + m.filters.folder
+	}
+}
+
+// tagList formats tags for display
+func tagList(tags []string) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+// SummarizeSessionMsg asks for a new title and summary of a session, which
+// the chat writes with its titles model
+type SummarizeSessionMsg struct {
+	SessionID string
+}
+
+// startSummarize regenerates the title and summary of the selected session
+func (m *Model) startSummarize() tea.Cmd {
+	selectedItem, ok := m.list.SelectedItem().(SessionItem)
+	if !ok {
+		return nil
+	}
+	session := selectedItem.Session
+	m.summarizing = session.ID
+	m.notice = "✨ Writing a title and summary for " + session.Title + "..."
+	return func() tea.Msg {
+		return SummarizeSessionMsg{SessionID: session.ID}
+	}
+}
+
+// HandleSessionSummarized shows a session's new title and summary, and the
+// outcome if it was asked for here
+func (m *Model) HandleSessionSummarized(sessionID string, err error) {
+	requested := sessionID == m.summarizing
+	if requested {
+		m.summarizing = ""
+		m.notice = "✨ Updated the title and summary"
+		if err != nil {
+			m.notice = fmt.Sprintf("❌ Summary failed: %v", err)
+		}
+	}
+	if err == nil {
+		m.reload()
+	}
+}
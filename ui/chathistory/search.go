@@ -0,0 +1,154 @@
+package chathistory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hariharen9/lamacli/chathistory"
+	"github.com/hariharen9/lamacli/ui/styles"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// search is the full-text search mode of the history browser, which
+// searches message contents rather than just titles
+type search struct {
+	active   bool
+	input    textinput.Model
+	results  []chathistory.SearchResult
+	selected int
+	err      error
+}
+
+func newSearch() search {
+	input := textinput.New()
+	input.Placeholder = "Search all messages..."
+	input.Prompt = "🔎 "
+	input.PromptStyle = styles.PromptStyle()
+	input.CharLimit = 200
+	return search{input: input}
+}
+
+// startSearch enters search mode, keeping the last query
+func (m *Model) startSearch() tea.Cmd {
+	m.search.active = true
+	m.search.input.CursorEnd()
+	return m.search.input.Focus()
+}
+
+// Typing reports whether keys go to a text input, the search query, an
+// inline prompt or the title filter, rather than acting as shortcuts
+func (m *Model) Typing() bool {
+	return m.search.active || m.prompt.active || m.list.SettingFilter()
+}
+
+// CancelSearch leaves search mode, reporting whether it was active
+func (m *Model) CancelSearch() bool {
+	if !m.search.active {
+		return false
+	}
+	m.search.active = false
+	m.search.input.Blur()
+	return true
+}
+
+// updateSearch handles a message while in search mode
+func (m *Model) updateSearch(msg tea.Msg) tea.Cmd {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "up", "ctrl+p":
+			m.search.selected = max(0, m.search.selected-1)
+			return nil
+		case "down", "ctrl+n":
+			m.search.selected = min(len(m.search.results)-1, m.search.selected+1)
+			return nil
+		case "enter":
+			if m.search.selected >= len(m.search.results) {
+				return nil
+			}
+			result := m.search.results[m.search.selected]
+			return func() tea.Msg {
+				return SessionSelectedMsg{Session: result.Session, MessageIndex: max(0, result.MessageIndex)}
+			}
+		}
+	}
+
+	query := m.search.input.Value()
+	var cmd tea.Cmd
+	m.search.input, cmd = m.search.input.Update(msg)
+	if m.search.input.Value() != query {
+		m.runSearch()
+	}
+	return cmd
+}
+
+// runSearch searches for the current query
+func (m *Model) runSearch() {
+	m.search.results, m.search.err, m.search.selected = nil, nil, 0
+	if strings.TrimSpace(m.search.input.Value()) == "" {
+		return
+	}
+	m.search.results, m.search.err = m.historyManager.SearchSessions(m.search.input.Value())
+	if errors.Is(m.search.err, chathistory.ErrEmptyQuery) {
+		m.search.err = nil // Keep typing
+	}
+}
+
+// searchView renders the query and the results around the selected one
+func (m *Model) searchView() string {
+	var b strings.Builder
+	b.WriteString(styles.TitleStyle().Render("🔎 Search Messages"))
+	b.WriteString("\n\n")
+	b.WriteString(m.search.input.View())
+	b.WriteString("\n\n")
+
+	switch {
+	case m.search.err != nil:
+		b.WriteString(styles.ErrorStyle().Render(fmt.Sprintf("Error: %v", m.search.err)))
+		return b.String()
+	case strings.TrimSpace(m.search.input.Value()) == "":
+		b.WriteString(styles.SubtleStyle().Render("Type to search the titles and messages of all sessions."))
+		return b.String()
+	case len(m.search.results) == 0:
+		b.WriteString(styles.SubtleStyle().Render("No matches found."))
+		return b.String()
+	}
+
+	// Each result takes three lines: title, snippet and a blank line
+	visible := max(1, (m.height-styles.AppStyle().GetVerticalFrameSize()-10)/3)
+	first := max(0, min(m.search.selected-visible/2, len(m.search.results)-visible))
+	last := min(len(m.search.results), first+visible)
+	width := max(20, m.width-styles.AppStyle().GetHorizontalFrameSize()-4)
+
+	b.WriteString(styles.SubtleStyle().Render(fmt.Sprintf("%d match(es)", len(m.search.results))))
+	b.WriteString("\n\n")
+	for i := first; i < last; i++ {
+		result := m.search.results[i]
+		where := "title"
+		if result.MessageIndex >= 0 {
+			role := "you"
+			if result.MessageIndex%2 == 1 {
+				role = "assistant"
+			}
+			where = fmt.Sprintf("turn %d, %s", result.MessageIndex/2+1, role)
+		}
+		title := fmt.Sprintf("%s • %s", result.Session.Title, where)
+		snippet := result.Highlight(func(s string) string { return styles.HighlightStyle().Render(s) })
+		snippet = lipgloss.NewStyle().MaxWidth(width).Render(snippet)
+
+		if i == m.search.selected {
+			b.WriteString(styles.SelectedItemStyle().Render("│ " + title))
+			b.WriteString("\n")
+			b.WriteString(styles.SelectedItemStyle().Render("│ ") + snippet)
+		} else {
+			b.WriteString(styles.ItemStyle().Render(title))
+			b.WriteString("\n")
+			b.WriteString(styles.ItemStyle().Render(snippet))
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
@@ -1,19 +1,24 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/hariharen9/lamacli/config"
 	"github.com/hariharen9/lamacli/fileops"
 	"github.com/hariharen9/lamacli/llm"
+	"github.com/hariharen9/lamacli/mcp"
 	"github.com/hariharen9/lamacli/ui/chat"
 	"github.com/hariharen9/lamacli/ui/chathistory"
 	"github.com/hariharen9/lamacli/ui/filetree"
 	"github.com/hariharen9/lamacli/ui/fileviewer"
 	"github.com/hariharen9/lamacli/ui/modelselect"
+	"github.com/hariharen9/lamacli/ui/profileselect"
 	"github.com/hariharen9/lamacli/ui/styles"
 	"github.com/hariharen9/lamacli/ui/theme"
 
@@ -35,6 +40,7 @@ const (
 	fileTreeView
 	fileViewerView
 	modelSelectView
+	profileSelectView
 	chatHistoryView
 	helpView
 )
@@ -47,9 +53,12 @@ type Model struct {
 	filetree         *filetree.Model
 	fileviewer       fileviewer.Model
 	modelselect      *modelselect.Model
+	profileselect    *profileselect.Model
 	chat             chat.Model
 	chatHistory      *chathistory.Model
 	llmClient        *llm.OllamaClient
+	mcp              *mcp.Manager // Connected MCP servers, if any are configured
+	config           *config.Config
 	viewMode         viewMode
 	width            int
 	height           int
@@ -59,18 +68,29 @@ type Model struct {
 	Err              error // Stores errors to display to the user
 }
 
-// InitialModel returns an initialized Model.
-func InitialModel() Model {
+// InitialModel returns an initialized Model. version identifies lamacli to
+// MCP servers; theme is the theme in use, which the --theme flag may have
+// chosen over the config's.
+func InitialModel(version, theme string) Model {
 	ft, err := filetree.New(".")
 	if err != nil {
 		panic(err)
 	}
 
-	llmClient, err := llm.NewOllamaClient()
 	var initialErr error
+	cfg, err := config.Load()
 	if err != nil {
+		initialErr = err
+	}
+	cfg.Theme = theme
+
+	llmClient, err := llm.NewOllamaClientWithHost(cfg.OllamaHost())
+	if err != nil && initialErr == nil {
 		initialErr = fmt.Errorf("Ollama client initialization failed: %w", err)
 	}
+	if llmClient != nil {
+		llmClient.SetOptions(cfg.GenerationOptions())
+	}
 
 	ms, err := modelselect.New(llmClient)
 	if err != nil && initialErr == nil {
@@ -81,7 +101,11 @@ func InitialModel() Model {
 	if llmClient != nil {
 		models, err := llmClient.ListModels()
 		if err == nil && len(models) > 0 {
-			defaultModel = models[0] // Set first available model as default
+			// Prefer the configured chat model, else the first available one
+			defaultModel = cfg.ModelFor("chat")
+			if defaultModel == "" {
+				defaultModel = models[0]
+			}
 		} else if initialErr == nil {
 			initialErr = fmt.Errorf("No Ollama models found. Please pull a model (e.g., 'ollama pull llama2')")
 		}
@@ -93,19 +117,39 @@ func InitialModel() Model {
 		initialErr = fmt.Errorf("Chat history initialization failed: %w", err)
 	}
 
+	chatModel := chat.New(llmClient, defaultModel, cfg)
+
+	// Start the configured MCP servers so the chat can offer their tools
+	var mcpManager *mcp.Manager
+	if len(cfg.MCPServers) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		mcpManager = mcp.Connect(ctx, cfg.MCPServers, version)
+		cancel()
+		chatModel.SetMCP(mcpManager)
+	}
+
 	return Model{
 		filetree:      ft,
 		fileviewer:    fileviewer.New(),
 		modelselect:   ms,
-		chat:          chat.New(llmClient, defaultModel),
+		chat:          chatModel,
 		chatHistory:   chatHistoryModel,
 		llmClient:     llmClient,
+		mcp:           mcpManager,
+		config:        cfg,
 		viewMode:      chatView, // Start with chat view
 		selectedModel: defaultModel,
 		Err:           initialErr,
 	}
 }
 
+// Close stops the MCP servers started for the chat.
+func (m Model) Close() {
+	if m.mcp != nil {
+		m.mcp.Close()
+	}
+}
+
 // Init is a command that can be run when the program starts.
 func (m Model) Init() tea.Cmd {
 	// Initialize the chat view since it's the default
@@ -140,10 +184,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case chathistory.SessionSelectedMsg:
 		// Load selected session into chat
 		m.chat.LoadFromSession(msg.Session)
-		m.selectedModel = msg.Session.Model
+		if msg.MessageIndex > 0 {
+			m.chat.ScrollToMessage(msg.MessageIndex)
+		}
+		if msg.Session.Model != "" { // Imported sessions may have none
+			m.selectedModel = msg.Session.Model
+		}
 		m.viewMode = chatView
 		return m, nil
 
+	case chathistory.SummarizeSessionMsg:
+		return m, m.chat.Summarize(msg.SessionID)
+
+	case chat.SessionSummarizedMsg:
+		// Handled here, as the chat and history may not be in view
+		m.chat.HandleSessionSummarized(msg)
+		if m.chatHistory != nil {
+			m.chatHistory.HandleSessionSummarized(msg.SessionID, msg.Err)
+		}
+		return m, nil
+
 	case chathistory.SessionDeletedMsg:
 		// Session was deleted, just stay in history view
 		return m, nil
@@ -159,6 +219,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.exitConfirmation = false
 				return m, nil
 			}
+			if m.viewMode == chatView && m.chat.CancelPendingPatch() {
+				return m, nil
+			}
+			if m.viewMode == chatView {
+				if cmd, ok := m.chat.DeclineToolCalls(); ok {
+					return m, cmd
+				}
+			}
+			if m.viewMode == chatView && (m.chat.CancelExport() || m.chat.CancelEdit() || m.chat.CancelRegenerate()) {
+				return m, nil
+			}
+			if m.viewMode == chatHistoryView && m.chatHistory != nil && (m.chatHistory.CancelSearch() || m.chatHistory.CancelExport() || m.chatHistory.CancelPrompt() || m.chatHistory.ClearFilters()) {
+				return m, nil
+			}
 			if m.fileContextMode {
 				m.viewMode = chatView
 				m.fileContextMode = false
@@ -208,6 +282,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Keys typed into the history search or filter are not shortcuts
+		typing := m.viewMode == chatHistoryView && m.chatHistory != nil && m.chatHistory.Typing()
+
 		// Global shortcuts that are not escape
 		switch msg.String() {
 		case "ctrl+c":
@@ -218,13 +295,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		case "F":
-			if m.viewMode != chatView || m.chat.TextInput.Value() == "" {
+			if !typing && (m.viewMode != chatView || m.chat.TextInput.Value() == "") {
 				m.viewMode = fileTreeView
 				return m, nil
 			}
 		case "M":
 			// Only allow model switching when not in chat view OR when chat input is empty
-			if m.viewMode != chatView || m.chat.TextInput.Value() == "" {
+			if !typing && (m.viewMode != chatView || m.chat.TextInput.Value() == "") {
 				m.viewMode = modelSelectView
 				if m.modelselect == nil {
 					return m, func() tea.Msg {
@@ -238,18 +315,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return tea.WindowSizeMsg{Width: m.width, Height: m.height}
 				})
 			}
+		case "P":
+			if !typing && (m.viewMode != chatView || m.chat.TextInput.Value() == "") {
+				profiles := m.config.ProfileNames()
+				if len(profiles) == 0 {
+					return m, func() tea.Msg {
+						return errMsg{fmt.Errorf("No profiles configured. Add one with 'lamacli config set profiles.<name>.host <host>'.")}
+					}
+				}
+				m.profileselect = profileselect.New(profiles, m.config.ActiveProfile())
+				m.viewMode = profileSelectView
+				return m, tea.Batch(m.profileselect.Init(), func() tea.Msg {
+					return tea.WindowSizeMsg{Width: m.width, Height: m.height}
+				})
+			}
 		case "R":
 			if m.viewMode == chatView && m.chat.TextInput.Value() == "" {
 				m.chat.Reset()
 				return m, nil
 			}
 		case "ctrl+h":
-			if m.viewMode != chatView || m.chat.TextInput.Value() == "" {
+			if !typing && (m.viewMode != chatView || m.chat.TextInput.Value() == "") {
 				m.viewMode = helpView
 				return m, nil
 			}
 		case "L":
-			if m.viewMode != chatView || m.chat.TextInput.Value() == "" {
+			if !typing && (m.viewMode != chatView || m.chat.TextInput.Value() == "") {
 				if m.chatHistory != nil {
 					m.viewMode = chatHistoryView
 					return m, tea.Batch(m.chatHistory.Init(), func() tea.Msg {
@@ -298,6 +389,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			cmd = updateCmd
 		}
+	case profileSelectView:
+		if m.profileselect != nil {
+			updatedProfile, updateCmd := m.profileselect.Update(msg)
+			if ps, ok := updatedProfile.(*profileselect.Model); ok {
+				m.profileselect = ps
+				if m.profileselect.FormCompleted() {
+					m.viewMode = chatView
+					if err := m.switchProfile(m.profileselect.SelectedProfile); err != nil {
+						return m, func() tea.Msg { return errMsg{err} }
+					}
+					return m, nil
+				}
+			}
+			cmd = updateCmd
+		}
 	case chatView:
 		updatedChat, newCmd := m.chat.Update(msg)
 		m.chat = updatedChat.(chat.Model)
@@ -317,6 +423,46 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// switchProfile activates a config profile, reconnecting to the profile's
+// host and picking its model. The previous profile stays active on failure.
+func (m *Model) switchProfile(name string) error {
+	previous := m.config.ActiveProfile()
+	if err := m.config.UseProfile(name); err != nil {
+		return err
+	}
+
+	llmClient, err := llm.NewOllamaClientWithHost(m.config.OllamaHost())
+	if err == nil {
+		llmClient.SetOptions(m.config.GenerationOptions())
+	}
+	var models []string
+	if err == nil {
+		models, err = llmClient.ListModels()
+	}
+	if err == nil && len(models) == 0 {
+		err = fmt.Errorf("No Ollama models found on %s", m.config.OllamaHost())
+	}
+	if err != nil {
+		m.config.UseProfile(previous)
+		return fmt.Errorf("Switching to profile '%s' failed: %w", name, err)
+	}
+
+	model := m.config.ModelFor("chat")
+	if model == "" {
+		model = models[0]
+	}
+
+	if ms, err := modelselect.New(llmClient); err == nil {
+		m.modelselect = ms
+	}
+	m.llmClient = llmClient
+	m.selectedModel = model
+	m.chat.SetProfile(name, llmClient, model, m.config.DefaultPrompt())
+	m.chat.TitleModel = m.config.ModelFor("titles")
+	m.Err = nil
+	return nil
+}
+
 // helpView returns the help text for the current view with enhanced styling.
 func (m Model) helpView() string {
 	var helpItems []string
@@ -330,14 +476,22 @@ func (m Model) helpView() string {
 			modelName = m.selectedModel
 		}
 		title = fmt.Sprintf("💬 Chat • 🤖 %s", modelName)
+		if profile := m.config.ActiveProfile(); profile != "" {
+			title += fmt.Sprintf(" • 👤 %s", profile)
+		}
 		helpItems = []string{
 			"↑/↓: scroll history",
 			"enter: send message",
 			"F: file explorer",
 			"M: switch model",
+			"P: switch profile",
 			"alt+t: use templates",
 			"L: load history",
 			"S: save session",
+			"E: export chat",
+			"B: edit & branch",
+			"G: regenerate",
+			"[/]: alternates",
 			"R: reset chat",
 			"C: copy code blocks",
 			"ctrl+h: help",
@@ -370,12 +524,28 @@ func (m Model) helpView() string {
 			"esc: back to chat",
 			"ctrl+c: exit",
 		}
+	case profileSelectView:
+		title = "👤 Profile Selection"
+		helpItems = []string{
+			"↑/↓: navigate profiles",
+			"enter: select profile",
+			"esc: back to chat",
+			"ctrl+c: exit",
+		}
 	case chatHistoryView:
 		title = "📚 Chat History"
 		helpItems = []string{
 			"↑/↓: navigate sessions",
 			"enter: load session",
 			"d/del: delete session",
+			"s: search messages",
+			"e: export session",
+			"n: rename",
+			"#: tags",
+			"m: move to folder",
+			"p: pin",
+			"g: regenerate title and summary",
+			"t/f: filter by tag/folder",
 			"r: refresh",
 			"esc: back to chat",
 			"ctrl+c: exit",
@@ -479,6 +649,14 @@ func (m Model) renderHelpContent() string {
 	content.WriteString(itemStyle.Render("• Use " + keyStyle.Render("↑/↓") + " to scroll through chat history"))
 	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• Chat supports full markdown rendering with syntax highlighting"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("B") + " - Select an earlier message; " + keyStyle.Render("Enter") + " edits and resends it as a new branch"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("←/→") + " while selecting - Switch between the branches of a message (🌿 2/3)"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("G") + " - Regenerate the last reply, optionally with another model or temperature"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("[") + "/" + keyStyle.Render("]") + " - Cycle through the alternates of the last reply (🔄 2/3); the one shown is kept"))
 	content.WriteString("\n\n")
 
 	// Navigation Commands
@@ -488,6 +666,8 @@ func (m Model) renderHelpContent() string {
 	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• " + keyStyle.Render("M") + " - Switch between different AI models"))
 	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("P") + " - Switch config profile (host, model, system prompt)"))
+	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• " + keyStyle.Render("Alt+T") + " - Use pre-defined templates (Code Review, Documentation, Debugging)"))
 	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• " + keyStyle.Render("R") + " - Reset chat history (clears conversation)"))
@@ -508,9 +688,41 @@ func (m Model) renderHelpContent() string {
 	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• " + keyStyle.Render("Enter") + " - Copy selected code block to clipboard"))
 	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("A") + " - Preview the selected diff or file block and apply it (undo with 'lamacli undo')"))
+	content.WriteString("\n")
 	content.WriteString(itemStyle.Render("• Code blocks are automatically extracted from AI responses"))
 	content.WriteString("\n\n")
 
+	// MCP Tools
+	content.WriteString(headerStyle.Render("🔧 MCP Tools"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• Tools from MCP servers in the config are offered to tool-capable models"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("y/Enter") + " - Allow the requested tool calls once"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("a") + " - Always allow these tools for the rest of the session"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("n/Esc") + " - Decline the tool calls; the model answers without them"))
+	content.WriteString("\n\n")
+
+	// Chat History
+	content.WriteString(headerStyle.Render("📚 Chat History"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("L") + " - Browse saved sessions; " + keyStyle.Render("/") + " filters them by title"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("s") + " - Search the messages of all sessions, best matches first"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("Enter") + " - Open the selected session at the matching message"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("E") + " in chat, " + keyStyle.Render("e") + " in history - Export as Markdown, HTML, JSON or text"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("n") + " rename, " + keyStyle.Render("#") + " tags, " + keyStyle.Render("m") + " folder, " + keyStyle.Render("p") + " pin - Organise the selected session; pinned sessions come first"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("g") + " - Regenerate the selected session's title and summary with the titles model"))
+	content.WriteString("\n")
+	content.WriteString(itemStyle.Render("• " + keyStyle.Render("t") + "/" + keyStyle.Render("f") + " - Show only sessions with a tag or in a folder; press again for the next, " + keyStyle.Render("Esc") + " clears"))
+	content.WriteString("\n\n")
+
 	// File Explorer
 	content.WriteString(headerStyle.Render("📁 File Explorer"))
 	content.WriteString("\n")
@@ -563,6 +775,8 @@ func (m Model) View() string {
 		s = m.fileviewer.Viewport.View()
 	case modelSelectView:
 		s = m.modelselect.View()
+	case profileSelectView:
+		s = m.profileselect.View()
 	case chatView:
 		s = m.chat.View()
 	case chatHistoryView:
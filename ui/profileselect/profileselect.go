@@ -0,0 +1,61 @@
+package profileselect
+
+import (
+	"github.com/hariharen9/lamacli/ui/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// defaultOption is the label for running without a profile.
+const defaultOption = "(none)"
+
+// Model represents the state of the profile selection UI.
+type Model struct {
+	SelectedProfile string
+	form            *huh.Form
+}
+
+// New creates a new profile selection model listing the given profiles,
+// with the active one preselected.
+func New(profiles []string, active string) *Model {
+	options := []huh.Option[string]{huh.NewOption(defaultOption, "")}
+	for _, profile := range profiles {
+		options = append(options, huh.NewOption(profile, profile))
+	}
+
+	ps := &Model{SelectedProfile: active}
+	ps.form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Key("selectedProfile").
+				Title("Select a Profile").
+				Options(options...).
+				Value(&ps.SelectedProfile),
+		),
+	).WithTheme(huh.ThemeBase16())
+
+	return ps
+}
+
+// Init is a command that can be run when the program starts.
+func (m Model) Init() tea.Cmd {
+	return m.form.Init()
+}
+
+// Update handles messages and updates the model accordingly.
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	form, cmd := m.form.Update(msg)
+	m.form = form.(*huh.Form)
+	return m, cmd
+}
+
+// FormCompleted returns true if the user has submitted the form.
+func (m *Model) FormCompleted() bool {
+	return m.form.State == huh.StateCompleted
+}
+
+// View returns the string representation of the UI.
+func (m Model) View() string {
+	return styles.AppStyle().Render(m.form.View())
+}
@@ -73,4 +73,9 @@ func StatusStyle() lipgloss.Style {
 	return lipgloss.NewStyle().
 		Foreground(theme.CurrentTheme.Success).
 		Bold(true)
-}
\ No newline at end of file
+}
+func HighlightStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(theme.CurrentTheme.Warning).
+		Bold(true)
+}